@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "github.com/offbeat-studio/allama/internal/config"
+	_ "github.com/offbeat-studio/allama/internal/middleware"
+	_ "github.com/offbeat-studio/allama/internal/models"
+	_ "github.com/offbeat-studio/allama/internal/provider"
+	_ "github.com/offbeat-studio/allama/internal/router"
+	_ "github.com/offbeat-studio/allama/internal/storage"
+	_ "github.com/offbeat-studio/allama/utils"
+)
+
+// TestImportsUseModulePath fails when a source file imports one of our packages
+// through a path other than the module path declared in go.mod
+func TestImportsUseModulePath(t *testing.T) {
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatalf("Failed to read go.mod: %v", err)
+	}
+	var modulePath string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "module ") {
+			modulePath = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+			break
+		}
+	}
+	if modulePath == "" {
+		t.Fatal("No module directive in go.mod")
+	}
+	repoName := modulePath[strings.LastIndex(modulePath, "/")+1:]
+
+	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, imp := range file.Imports {
+			importPath, _ := strconv.Unquote(imp.Path.Value)
+			if strings.Contains(importPath, "/"+repoName+"/") && !strings.HasPrefix(importPath, modulePath+"/") {
+				t.Errorf("%s imports %s, expected the %s module path", path, importPath, modulePath)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk source tree: %v", err)
+	}
+}
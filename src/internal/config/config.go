@@ -3,14 +3,105 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Version is the gateway's build version, set at build time with
+// -ldflags "-X github.com/offbeat-studio/allama/internal/config.Version=..."
+var Version = "dev"
+
+// DefaultOllamaVersion is reported by /api/version unless OLLAMA_REPORTED_VERSION is set. Some
+// Ollama clients refuse to work with, or disable features for, servers older than they expect.
+const DefaultOllamaVersion = "0.9.0"
+
 // Config holds the application configuration
 type Config struct {
-	Port         string
-	DatabasePath string
+	Port           string
+	DatabaseDriver string
+	DatabasePath   string
+	DatabaseURL    string
+	ResetDBOnStart bool
+	AdminToken     string
+	GatewayAPIKeys []string
+	// LogMaxBodyBytes caps how much of a request or response body is written to the logs
+	LogMaxBodyBytes int
+	LogLevel        string
+	LogOutput       string
+	// LogDir is the directory of the daily log files
+	LogDir string
+	// LogSkipPaths are request paths that are not logged; empty means the health check and metrics
+	LogSkipPaths []string
+	// LogOmitBodies logs the request line and status without request or response bodies
+	LogOmitBodies bool
+	// ShutdownTimeout is how long in-flight requests may take to finish on shutdown
+	ShutdownTimeout time.Duration
+	// ProviderQueueTimeout is how long a request waits for a provider at its concurrency limit
+	ProviderQueueTimeout time.Duration
+	// UpstreamConnectTimeout bounds connecting to a provider, including the TLS handshake, and
+	// UpstreamTimeout bounds a whole non-streaming upstream request or the wait for a stream to start
+	UpstreamConnectTimeout time.Duration
+	UpstreamTimeout        time.Duration
+	// UpstreamMaxIdleConns and UpstreamMaxIdleConnsPerHost cap the idle upstream connections kept
+	// for reuse in total and per host, for up to UpstreamIdleConnTimeout
+	UpstreamMaxIdleConns        int
+	UpstreamMaxIdleConnsPerHost int
+	UpstreamIdleConnTimeout     time.Duration
+	// IdempotencyTTL is how long responses to requests with an Idempotency-Key header are kept
+	// for replay; zero disables idempotency keys
+	IdempotencyTTL time.Duration
+	// MaxRequestBytes caps the size of request bodies; zero or less disables the cap
+	MaxRequestBytes int
+	// TrustedProxies lists the IPs or CIDRs of the proxies whose X-Forwarded-For header is
+	// believed when deriving the client IP; empty trusts none and uses the connection's address
+	TrustedProxies []string
+	// CORSAllowedOrigins lists the browser origins allowed to call the API, or "*" for any
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+
+	// RoutePrefix is prepended to every API route, e.g. "/allama" behind a reverse proxy subpath
+	RoutePrefix string
+	// HealthPath is where the health check is served; it is not affected by RoutePrefix
+	HealthPath string
+
+	// ModelPrices lists "provider/model=input:output" prices in USD per million tokens
+	ModelPrices []string
+
+	// ModelListTimeout is how long listing models waits for each provider before using its stored models
+	ModelListTimeout time.Duration
+	// ModelCacheTTL is how long provider model lists are served from memory; zero disables the cache
+	ModelCacheTTL time.Duration
+	// ModelFetchTimeout bounds each attempt to fetch a provider's models at startup, and
+	// ModelFetchRetries is how many times a failed fetch is retried
+	ModelFetchTimeout time.Duration
+	ModelFetchRetries int
+
+	// LoadBalancing spreads requests over providers of equal priority serving the same model:
+	// "weighted" picks one at random by weight, "round_robin" takes turns, and empty always
+	// uses the first configured provider
+	LoadBalancing string
+
+	// OllamaKeepAlive is the keep_alive sent to Ollama when a client does not set one, e.g.
+	// "30m"; empty leaves it to Ollama
+	OllamaKeepAlive string
+
+	// ContextStrategy handles chat histories longer than the model's context length:
+	// "drop_oldest" drops the oldest messages but keeps system messages, "error" rejects them,
+	// and empty sends them as they are
+	ContextStrategy string
+
+	// DefaultProvider and DefaultModel route chat and completion requests for models no
+	// provider serves: to the default provider, and as the default model when that is set.
+	// Both empty rejects unknown models.
+	DefaultProvider string
+	DefaultModel    string
+
+	// OllamaReportedVersion is the Ollama version /api/version reports to clients
+	OllamaReportedVersion string
 }
 
 // LoadConfig loads configuration from environment variables or .env file
@@ -21,13 +112,72 @@ func LoadConfig() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Port:         getEnv("PORT", "8080"),
-		DatabasePath: getEnv("DATABASE_PATH", "./allama.db"),
+		Port:            getEnv("PORT", "8080"),
+		DatabaseDriver:  getEnv("DATABASE_DRIVER", "sqlite3"),
+		DatabasePath:    getEnv("DATABASE_PATH", "./allama.db"),
+		DatabaseURL:     getEnv("DATABASE_URL", ""),
+		ResetDBOnStart:  getEnvBool("RESET_DB_ON_START", false),
+		AdminToken:      getEnv("ADMIN_TOKEN", ""),
+		GatewayAPIKeys:  getEnvList("GATEWAY_API_KEYS"),
+		LogMaxBodyBytes: getEnvInt("LOG_MAX_BODY_BYTES", 64*1024),
+		LogLevel:        getEnv("LOG_LEVEL", "INFO"),
+		LogOutput:       getEnv("LOG_OUTPUT", "file"),
+		LogDir:          getEnv("LOG_DIR", "logs"),
+		LogSkipPaths:    getEnvList("LOG_SKIP_PATHS"),
+		LogOmitBodies:   !getEnvBool("LOG_BODIES", true),
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		MaxRequestBytes: getEnvInt("MAX_REQUEST_BYTES", 32*1024*1024),
+		TrustedProxies:  getEnvList("TRUSTED_PROXIES"),
+
+		ProviderQueueTimeout: getEnvDuration("PROVIDER_QUEUE_TIMEOUT", 10*time.Second),
+
+		UpstreamConnectTimeout: getEnvDuration("UPSTREAM_CONNECT_TIMEOUT", 10*time.Second),
+		UpstreamTimeout:        getEnvDuration("UPSTREAM_TIMEOUT", 5*time.Minute),
+		IdempotencyTTL:         getEnvDuration("IDEMPOTENCY_TTL", 0),
+
+		UpstreamMaxIdleConns:        getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 100),
+		UpstreamMaxIdleConnsPerHost: getEnvInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 32),
+		UpstreamIdleConnTimeout:     getEnvDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second),
+
+		CORSAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods: getEnvListDefault("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+		CORSAllowedHeaders: getEnvListDefault("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-Request-ID", "X-Admin-Token", "Idempotency-Key"}),
+
+		RoutePrefix: normalizeRoutePrefix(getEnv("ROUTE_PREFIX", "")),
+		HealthPath:  getEnv("HEALTH_PATH", "/health"),
+
+		ModelPrices: getEnvList("MODEL_PRICES"),
+
+		ModelListTimeout: getEnvDuration("MODEL_LIST_TIMEOUT", 5*time.Second),
+		ModelCacheTTL:    getEnvDuration("MODEL_CACHE_TTL", time.Minute),
+
+		ModelFetchTimeout: getEnvDuration("MODEL_FETCH_TIMEOUT", 10*time.Second),
+		ModelFetchRetries: getEnvInt("MODEL_FETCH_RETRIES", 2),
+
+		LoadBalancing: strings.ToLower(getEnv("LOAD_BALANCING", "")),
+
+		OllamaKeepAlive: strings.TrimSpace(getEnv("OLLAMA_DEFAULT_KEEP_ALIVE", "")),
+		ContextStrategy: strings.ToLower(getEnv("CONTEXT_STRATEGY", "")),
+
+		DefaultProvider: strings.TrimSpace(getEnv("DEFAULT_PROVIDER", "")),
+		DefaultModel:    strings.TrimSpace(getEnv("DEFAULT_MODEL", "")),
+
+		OllamaReportedVersion: strings.TrimSpace(getEnv("OLLAMA_REPORTED_VERSION", DefaultOllamaVersion)),
 	}
 
 	return cfg, nil
 }
 
+// normalizeRoutePrefix turns a configured prefix such as "allama/" into "/allama", or
+// an empty string when no prefix is set
+func normalizeRoutePrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
 // getEnv retrieves an environment variable or returns a default value if not set
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -35,3 +185,64 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvBool retrieves a boolean environment variable or returns a default value if not set or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean value for %s: %q, using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value if not set or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer value for %s: %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration retrieves a duration environment variable such as "30s" or returns a default value if not set or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		log.Printf("Invalid duration value for %s: %q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvList retrieves a comma-separated environment variable as a list, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvListDefault retrieves a comma-separated environment variable as a list, or returns a default list if it is empty
+func getEnvListDefault(key string, defaultValue []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return defaultValue
+}
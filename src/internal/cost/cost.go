@@ -0,0 +1,180 @@
+// Package cost estimates the spend of requests from their token usage.
+package cost
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Price is the USD cost of a single input and output token
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Table maps "provider/model" keys, or bare model IDs that apply to any provider, to prices
+type Table map[string]Price
+
+// ParseTable parses price entries of the form "provider/model=input:output" or
+// "model=input:output", with prices in USD per million tokens
+func ParseTable(entries []string) (Table, error) {
+	table := make(Table)
+	for _, entry := range entries {
+		key, prices, ok := strings.Cut(entry, "=")
+		input, output, ok2 := strings.Cut(prices, ":")
+		key = strings.TrimSpace(key)
+		if !ok || !ok2 || key == "" {
+			return nil, fmt.Errorf("invalid price %q, expected model=input:output", entry)
+		}
+		in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		if err != nil || in < 0 {
+			return nil, fmt.Errorf("invalid input price in %q", entry)
+		}
+		out, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil || out < 0 {
+			return nil, fmt.Errorf("invalid output price in %q", entry)
+		}
+		table[key] = Price{Input: in / 1e6, Output: out / 1e6}
+	}
+	return table, nil
+}
+
+// Lookup returns the price of a model at a provider, preferring a provider-specific entry
+func (t Table) Lookup(providerName, modelID string) (Price, bool) {
+	if price, ok := t[providerName+"/"+modelID]; ok {
+		return price, true
+	}
+	price, ok := t[modelID]
+	return price, ok
+}
+
+// EstimateTokens approximates the token count of text at about four characters per token,
+// for providers that do not report usage
+func EstimateTokens(text string) int {
+	chars := utf8.RuneCountInString(text)
+	if chars == 0 {
+		return 0
+	}
+	return (chars + 3) / 4
+}
+
+// Usage is the token usage of a single request
+type Usage struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	// Estimated is set when the token counts were approximated from text lengths
+	Estimated bool `json:"estimated"`
+}
+
+// Totals accumulates the usage and estimated cost of a provider and model
+type Totals struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	// EstimatedRequests counts requests whose usage was approximated
+	EstimatedRequests int `json:"estimated_requests"`
+	// Unpriced is set when the price table has no entry for the model
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// Tracker prices requests and keeps running totals per provider and model
+type Tracker struct {
+	table Table
+
+	mu     sync.Mutex
+	totals map[string]*Totals
+}
+
+// NewTracker creates a tracker pricing requests with table
+func NewTracker(table Table) *Tracker {
+	return &Tracker{table: table, totals: make(map[string]*Totals)}
+}
+
+// Record adds a request's usage to the totals and returns its estimated cost, which is
+// zero when the model has no price
+func (t *Tracker) Record(usage Usage) float64 {
+	price, priced := t.table.Lookup(usage.Provider, usage.Model)
+	cost := float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := usage.Provider + "/" + usage.Model
+	totals, ok := t.totals[key]
+	if !ok {
+		totals = &Totals{Provider: usage.Provider, Model: usage.Model}
+		t.totals[key] = totals
+	}
+	totals.Requests++
+	totals.PromptTokens += usage.PromptTokens
+	totals.CompletionTokens += usage.CompletionTokens
+	totals.Cost += cost
+	totals.Unpriced = !priced
+	if usage.Estimated {
+		totals.EstimatedRequests++
+	}
+	return cost
+}
+
+// Totals returns the running totals ordered by provider and model
+func (t *Tracker) Totals() []Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := make([]Totals, 0, len(t.totals))
+	for _, entry := range t.totals {
+		totals = append(totals, *entry)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Provider != totals[j].Provider {
+			return totals[i].Provider < totals[j].Provider
+		}
+		return totals[i].Model < totals[j].Model
+	})
+	return totals
+}
+
+// WriteMetrics writes the totals as Prometheus counters in the text exposition format
+func (t *Tracker) WriteMetrics(w io.Writer) {
+	totals := t.Totals()
+
+	fmt.Fprintln(w, "# HELP allama_estimated_cost_usd_total Estimated spend in USD.")
+	fmt.Fprintln(w, "# TYPE allama_estimated_cost_usd_total counter")
+	for _, entry := range totals {
+		fmt.Fprintf(w, "allama_estimated_cost_usd_total{%s} %s\n", labels(entry), strconv.FormatFloat(entry.Cost, 'g', -1, 64))
+	}
+
+	fmt.Fprintln(w, "# HELP allama_tokens_total Tokens sent to and received from providers.")
+	fmt.Fprintln(w, "# TYPE allama_tokens_total counter")
+	for _, entry := range totals {
+		fmt.Fprintf(w, "allama_tokens_total{%s,type=\"prompt\"} %d\n", labels(entry), entry.PromptTokens)
+		fmt.Fprintf(w, "allama_tokens_total{%s,type=\"completion\"} %d\n", labels(entry), entry.CompletionTokens)
+	}
+
+	fmt.Fprintln(w, "# HELP allama_requests_total Completed requests whose cost was tracked.")
+	fmt.Fprintln(w, "# TYPE allama_requests_total counter")
+	for _, entry := range totals {
+		fmt.Fprintf(w, "allama_requests_total{%s} %d\n", labels(entry), entry.Requests)
+	}
+}
+
+// labels formats the provider and model labels of a series
+func labels(entry Totals) string {
+	return fmt.Sprintf("provider=%s,model=%s", quoteLabel(entry.Provider), quoteLabel(entry.Model))
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel quotes a label value
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
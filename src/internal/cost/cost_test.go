@@ -0,0 +1,77 @@
+package cost
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	table, err := ParseTable([]string{"openai/gpt-4o=2.5:10", "gpt-4o=5:15", "claude-3-haiku = 0.25 : 1.25"})
+	if err != nil {
+		t.Fatalf("ParseTable failed: %v", err)
+	}
+
+	price, ok := table.Lookup("openai", "gpt-4o")
+	if !ok || price.Input != 2.5e-6 || price.Output != 10e-6 {
+		t.Errorf("Expected the provider-specific price per token, got %+v", price)
+	}
+	if price, _ := table.Lookup("azure", "gpt-4o"); price.Input != 5e-6 {
+		t.Errorf("Expected the model price for other providers, got %+v", price)
+	}
+	if price, ok := table.Lookup("anthropic", "claude-3-haiku"); !ok || price.Output != 1.25e-6 {
+		t.Errorf("Expected whitespace to be ignored, got %+v", price)
+	}
+	if _, ok := table.Lookup("openai", "gpt-3.5-turbo"); ok {
+		t.Error("Expected no price for an unlisted model")
+	}
+
+	for _, invalid := range []string{"gpt-4o", "gpt-4o=1", "=1:2", "gpt-4o=a:2", "gpt-4o=1:-2"} {
+		if _, err := ParseTable([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	for text, want := range map[string]int{"": 0, "Hi": 1, "Hello world!": 3, "héllo": 2} {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Table{"openai/gpt-4o": {Input: 2e-6, Output: 8e-6}})
+
+	cost := tracker.Record(Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 500})
+	if math.Abs(cost-0.006) > 1e-12 {
+		t.Errorf("Expected a cost of 0.006, got %v", cost)
+	}
+	tracker.Record(Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5, Estimated: true})
+	if cost := tracker.Record(Usage{Provider: "anthropic", Model: "claude-3-haiku", PromptTokens: 10}); cost != 0 {
+		t.Errorf("Expected unpriced models to cost nothing, got %v", cost)
+	}
+
+	totals := tracker.Totals()
+	if len(totals) != 2 || totals[0].Provider != "anthropic" || !totals[0].Unpriced {
+		t.Fatalf("Expected totals ordered by provider, got %+v", totals)
+	}
+	openai := totals[1]
+	if openai.Requests != 2 || openai.PromptTokens != 1010 || openai.CompletionTokens != 505 || openai.EstimatedRequests != 1 {
+		t.Errorf("Unexpected openai totals: %+v", openai)
+	}
+
+	var metrics bytes.Buffer
+	tracker.WriteMetrics(&metrics)
+	for _, line := range []string{
+		"# TYPE allama_estimated_cost_usd_total counter",
+		`allama_tokens_total{provider="openai",model="gpt-4o",type="prompt"} 1010`,
+		`allama_requests_total{provider="anthropic",model="claude-3-haiku"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, metrics.String())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader is the request header carrying the admin token
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth protects admin routes with the configured admin token.
+// When no token is configured the admin routes are disabled entirely.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			RespondError(c, http.StatusForbidden, "Admin API is disabled, set ADMIN_TOKEN to enable it")
+			return
+		}
+
+		if !HasAdminToken(c, token) {
+			RespondError(c, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasAdminToken reports whether the request carries the configured admin token, which is
+// never the case when none is configured
+func HasAdminToken(c *gin.Context, token string) bool {
+	provided := c.GetHeader(AdminTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// APIKeyAuth requires a bearer token matching one of the configured gateway API keys.
+// When no keys are configured every request is allowed. Keys are kept only as SHA-256
+// digests and compared in constant time; the health check at healthPath is always left open.
+func APIKeyAuth(keys []string, healthPath string) gin.HandlerFunc {
+	var digests [][sha256.Size]byte
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			digests = append(digests, sha256.Sum256([]byte(key)))
+		}
+	}
+
+	return func(c *gin.Context) {
+		if len(digests) == 0 || c.Request.URL.Path == healthPath {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			RespondErrorCode(c, http.StatusUnauthorized, "missing_api_key", "Missing API key")
+			return
+		}
+
+		provided := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		matched := 0
+		for _, digest := range digests {
+			matched |= subtle.ConstantTimeCompare(provided[:], digest[:])
+		}
+		if matched != 1 {
+			RespondErrorCode(c, http.StatusUnauthorized, "invalid_api_key", "Invalid API key")
+			return
+		}
+
+		c.Next()
+	}
+}
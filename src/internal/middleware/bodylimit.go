@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit rejects requests whose body exceeds maxBytes with 413. Bodies are read through
+// http.MaxBytesReader so an oversized upload is cut off without being buffered in full;
+// it must run before any middleware that reads the body. Zero or less disables the limit.
+func BodyLimit(maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		tooLarge := fmt.Sprintf("Request body exceeds the %d byte limit", maxBytes)
+		if c.Request.ContentLength > int64(maxBytes) {
+			RespondError(c, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes)))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				RespondError(c, http.StatusRequestEntityTooLarge, tooLarge)
+				return
+			}
+			RespondError(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(BodyLimit(16))
+	engine.POST("/api/v1/chat/completions", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	post := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(httptest.NewRequest("POST", "/api/v1/chat/completions", strings.NewReader(`{"model":"x"}`))); w.Code != http.StatusOK || w.Body.String() != `{"model":"x"}` {
+		t.Errorf("Expected small body to pass through, got %d: %s", w.Code, w.Body.String())
+	}
+
+	oversized := strings.Repeat("x", 17)
+	w := post(httptest.NewRequest("POST", "/api/v1/chat/completions", strings.NewReader(oversized)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for oversized body, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "invalid_request_error") {
+		t.Errorf("Expected an OpenAI error envelope, got %s", w.Body.String())
+	}
+
+	// Without a Content-Length the limit is enforced while reading
+	req := httptest.NewRequest("POST", "/api/v1/chat/completions", io.NopCloser(strings.NewReader(oversized)))
+	req.ContentLength = -1
+	if w := post(req); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for oversized chunked body, got %d", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSEngine(origins ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(CORS(origins, []string{"GET", "POST"}, []string{"Authorization", "Content-Type"}))
+	engine.GET("/api/tags", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func preflight(engine *gin.Engine, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", "/api/tags", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	w := preflight(newCORSEngine("http://localhost:3000"), "http://localhost:3000")
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Expected the origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("Expected configured methods, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Authorization, Content-Type" {
+		t.Errorf("Expected configured headers, got %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	w := preflight(newCORSEngine("*"), "http://anything.example")
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected wildcard preflight to succeed, got %d with origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSRejectsUnknownOrigin(t *testing.T) {
+	engine := newCORSEngine("http://localhost:3000")
+
+	if w := preflight(engine, "http://evil.example"); w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected preflight from unknown origin to be refused, got %d", w.Code)
+	}
+
+	// Simple requests still reach the handler but carry no CORS headers
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for unknown origin, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	w := preflight(newCORSEngine(), "http://localhost:3000")
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers without configured origins, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAIRoutePrefix is the path prefix of the OpenAI-compatible route group
+const openAIRoutePrefix = "/api/v1/"
+
+// routePrefixKey is the gin context key holding the configured route prefix
+const routePrefixKey = "route_prefix"
+
+// RoutePrefix records the prefix all API routes are mounted under, so helpers such as
+// IsOpenAIRoute recognize prefixed paths
+func RoutePrefix(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(routePrefixKey, prefix)
+		c.Next()
+	}
+}
+
+// IsOpenAIRoute reports whether the request targets the OpenAI-compatible route group
+func IsOpenAIRoute(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, c.GetString(routePrefixKey)+openAIRoutePrefix)
+}
+
+// RespondError aborts the request with an error shaped for its route group
+func RespondError(c *gin.Context, status int, message string) {
+	RespondErrorCode(c, status, "", message)
+}
+
+// RespondErrorCode aborts the request with an error shaped for its route group. OpenAI routes
+// get the {"error": {"message", "type", "code"}} envelope the SDKs parse, while Ollama routes
+// get Ollama's plain {"error": "..."}, which has no room for the code.
+func RespondErrorCode(c *gin.Context, status int, code, message string) {
+	RespondErrorParam(c, status, code, "", message)
+}
+
+// RespondErrorParam aborts the request like RespondErrorCode, naming the offending request
+// field in the param of the OpenAI envelope
+func RespondErrorParam(c *gin.Context, status int, code, param, message string) {
+	if !IsOpenAIRoute(c) {
+		c.AbortWithStatusJSON(status, gin.H{"error": message})
+		return
+	}
+
+	var errorCode, errorParam interface{}
+	if code != "" {
+		errorCode = code
+	}
+	if param != "" {
+		errorParam = param
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": gin.H{
+		"message": message,
+		"type":    openAIErrorType(status),
+		"param":   errorParam,
+		"code":    errorCode,
+	}})
+}
+
+// openAIErrorType maps a status code to the error type OpenAI reports for it
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "api_error"
+	default:
+		return "invalid_request_error"
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondErrorShapesByRouteGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := func(c *gin.Context) {
+		RespondErrorCode(c, http.StatusNotFound, "model_not_found", "model 'x' not found")
+	}
+	engine.GET("/api/v1/models", handler)
+	engine.GET("/api/tags", handler)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/models", nil))
+	var openAI struct {
+		Error struct {
+			Message string      `json:"message"`
+			Type    string      `json:"type"`
+			Param   interface{} `json:"param"`
+			Code    string      `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &openAI); err != nil {
+		t.Fatalf("Expected OpenAI error envelope, got %s", w.Body.String())
+	}
+	if w.Code != http.StatusNotFound || openAI.Error.Message != "model 'x' not found" ||
+		openAI.Error.Type != "not_found_error" || openAI.Error.Code != "model_not_found" {
+		t.Errorf("Unexpected OpenAI error %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/api/tags", nil))
+	var ollama struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &ollama); err != nil {
+		t.Fatalf("Expected plain Ollama error, got %s", w.Body.String())
+	}
+	if w.Code != http.StatusNotFound || ollama.Error != "model 'x' not found" {
+		t.Errorf("Unexpected Ollama error %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOpenAIErrorType(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:          "invalid_request_error",
+		http.StatusUnauthorized:        "authentication_error",
+		http.StatusForbidden:           "permission_error",
+		http.StatusNotFound:            "not_found_error",
+		http.StatusTooManyRequests:     "rate_limit_error",
+		http.StatusBadGateway:          "api_error",
+		http.StatusInternalServerError: "api_error",
+	}
+	for status, want := range tests {
+		if got := openAIErrorType(status); got != want {
+			t.Errorf("openAIErrorType(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
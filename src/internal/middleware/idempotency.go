@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the request header naming a retry-safe request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from an earlier request with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength caps the length of idempotency keys
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotencyEntries caps the number of requests with an idempotency key that are kept
+const maxIdempotencyEntries = 10000
+
+// Idempotency replays the response of a completed POST request to later requests with the
+// same Idempotency-Key header, for ttl, without running the handler again. Keys are scoped
+// to the caller's Authorization header, or its IP address when it sends none, and the request
+// path. A duplicate arriving while the first request is still running waits for it. Streamed
+// responses and server errors are not stored, so a retry of those runs again. At most
+// maxIdempotencyEntries requests are kept; the oldest stored responses make room for new ones.
+// A zero ttl disables idempotency keys.
+func Idempotency(ttl time.Duration) gin.HandlerFunc {
+	return newIdempotency(ttl, maxIdempotencyEntries)
+}
+
+// newIdempotency returns the Idempotency middleware keeping at most maxEntries requests
+func newIdempotency(ttl time.Duration, maxEntries int) gin.HandlerFunc {
+	store := &idempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]*idempotencyEntry),
+		stored:     list.New(),
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if ttl <= 0 || key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			RespondErrorCode(c, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				RespondErrorCode(c, http.StatusBadRequest, "invalid_request", "Failed to read request body")
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		// Callers without credentials are told apart by address, so they cannot replay each
+		// other's responses
+		caller := c.GetHeader("Authorization")
+		if caller == "" {
+			caller = "ip:" + c.ClientIP()
+		}
+		scope := idempotencyScope(caller, c.Request.URL.Path, key)
+		digest := sha256.Sum256(body)
+
+		for {
+			entry, first := store.claim(scope, digest)
+			if entry == nil {
+				// The store is full of running requests, so this one runs without a key
+				c.Next()
+				return
+			}
+			if entry.bodyDigest != digest {
+				RespondErrorCode(c, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used with a different request body")
+				return
+			}
+			if first {
+				store.run(c, scope, entry)
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if entry.response != nil {
+				entry.response.replay(c)
+				return
+			}
+			// The first request was not stored, so this one runs in its place
+		}
+	}
+}
+
+// idempotencyScope identifies a key of a caller on a path
+func idempotencyScope(caller, path, key string) [sha256.Size]byte {
+	h := sha256.New()
+	for _, part := range []string{caller, path, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	var scope [sha256.Size]byte
+	copy(scope[:], h.Sum(nil))
+	return scope
+}
+
+// idempotencyStore holds the requests with an idempotency key that are running or stored
+type idempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*idempotencyEntry
+	// stored lists the entries with a stored response, oldest first
+	stored *list.List
+}
+
+// idempotencyEntry is a request with an idempotency key
+type idempotencyEntry struct {
+	scope      [sha256.Size]byte
+	bodyDigest [sha256.Size]byte
+	// done is closed when the request completed; response is then set if it was stored
+	done     chan struct{}
+	response *storedResponse
+	// element is the entry's place in stored once its response is stored
+	element *list.Element
+}
+
+// storedResponse is a completed response kept for replay
+type storedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// claim returns the entry of scope, creating it when there is none. first reports whether
+// the caller created it and must run the request. When the store is full the oldest stored
+// responses are dropped to make room; entry is nil when all kept requests are still running.
+func (s *idempotencyStore) claim(scope, digest [sha256.Size]byte) (entry *idempotencyEntry, first bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[scope]; ok {
+		return entry, false
+	}
+	for len(s.entries) >= s.maxEntries {
+		oldest := s.stored.Front()
+		if oldest == nil {
+			return nil, false
+		}
+		s.remove(oldest.Value.(*idempotencyEntry))
+	}
+	entry = &idempotencyEntry{scope: scope, bodyDigest: digest, done: make(chan struct{})}
+	s.entries[scope] = entry
+	return entry, true
+}
+
+// remove drops an entry with a stored response; the caller holds s.mu
+func (s *idempotencyStore) remove(entry *idempotencyEntry) {
+	s.stored.Remove(entry.element)
+	entry.element = nil
+	if s.entries[entry.scope] == entry {
+		delete(s.entries, entry.scope)
+	}
+}
+
+// run handles the request of a new entry and stores its response for the TTL when it can be
+// replayed. Otherwise, including when the handler panics, the entry is dropped so waiting
+// duplicates run themselves.
+func (s *idempotencyStore) run(c *gin.Context, scope [sha256.Size]byte, entry *idempotencyEntry) {
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+
+	completed := false
+	defer func() {
+		s.mu.Lock()
+		if completed && !w.streamed && w.Status() < http.StatusInternalServerError {
+			entry.response = &storedResponse{status: w.Status(), header: w.Header().Clone(), body: w.body.Bytes()}
+			entry.element = s.stored.PushBack(entry)
+			time.AfterFunc(s.ttl, func() { s.forget(entry) })
+		} else {
+			delete(s.entries, scope)
+		}
+		s.mu.Unlock()
+		close(entry.done)
+	}()
+
+	c.Next()
+	completed = true
+}
+
+// forget drops an expired entry unless it was already dropped to make room
+func (s *idempotencyStore) forget(entry *idempotencyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.element != nil {
+		s.remove(entry)
+	}
+}
+
+// replay writes the stored response, keeping the request ID of the current request
+func (r *storedResponse) replay(c *gin.Context) {
+	for name, values := range r.header {
+		if name == http.CanonicalHeaderKey(RequestIDHeader) {
+			continue
+		}
+		c.Writer.Header()[name] = values
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Status(r.status)
+	c.Writer.Write(r.body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the whole response body, unless the response is streamed
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	streamed bool
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// record copies a write into the body, dropping the copy once the response turns out to be streamed
+func (w *recordingWriter) record(b []byte) {
+	if w.streamed || isStreamingContentType(w.Header().Get("Content-Type")) {
+		w.streamed = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(b)
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyReplaysCompletedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestID(), Idempotency(time.Minute))
+	var calls int32
+	engine.POST("/api/v1/chat/completions", func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusOK, gin.H{"id": n})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"model":"gpt-4o"}`)
+	replayed := send("key-1", `{"model":"gpt-4o"}`)
+	if calls != 1 {
+		t.Fatalf("Expected the handler to run once, ran %d times", calls)
+	}
+	if replayed.Code != http.StatusOK || replayed.Body.String() != first.Body.String() {
+		t.Errorf("Expected the stored response %q, got %d %q", first.Body.String(), replayed.Code, replayed.Body.String())
+	}
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("Expected only the replay to be marked")
+	}
+	if replayed.Header().Get(RequestIDHeader) == first.Header().Get(RequestIDHeader) {
+		t.Errorf("Expected the replay to keep its own request ID")
+	}
+
+	if w := send("key-1", `{"model":"claude"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a reused key with another body to be rejected, got %d", w.Code)
+	}
+	if send("key-2", `{"model":"gpt-4o"}`); calls != 2 {
+		t.Errorf("Expected another key to run the handler, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicatesWaitForFirst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Idempotency(time.Minute))
+	var calls int32
+	release := make(chan struct{})
+	engine.POST("/api/chat", func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		c.JSON(http.StatusOK, gin.H{"message": "done"})
+	})
+
+	const duplicates = 5
+	codes := make([]int, duplicates)
+	bodies := make([]string, duplicates)
+	var wg sync.WaitGroup
+	for i := 0; i < duplicates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(`{"model":"llama3"}`))
+			req.Header.Set(IdempotencyKeyHeader, "same")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			codes[i], bodies[i] = w.Code, w.Body.String()
+		}(i)
+	}
+	// Give the duplicates time to arrive while the first is still running
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("Expected the handler to run once, ran %d times", calls)
+	}
+	for i := range codes {
+		if codes[i] != http.StatusOK || bodies[i] != `{"message":"done"}` {
+			t.Errorf("Expected every duplicate to get the response, got %d %q", codes[i], bodies[i])
+		}
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Idempotency(time.Minute))
+	var calls int32
+	engine.POST("/api/chat", func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "upstream failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "done"})
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "retry")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if i == 1 && w.Code != http.StatusOK {
+			t.Errorf("Expected the retry to run again, got %d", w.Code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the handler to run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyScopesAnonymousCallersByAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Idempotency(time.Minute))
+	var calls int32
+	engine.POST("/api/chat", func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusOK, gin.H{"id": n})
+	})
+
+	send := func(remoteAddr string) string {
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "shared")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	first := send("10.0.0.1:1234")
+	if other := send("10.0.0.2:1234"); other == first {
+		t.Errorf("Expected another client's request to run, got the replay %q", other)
+	}
+	if again := send("10.0.0.1:5678"); again != first {
+		t.Errorf("Expected the same client to get its replay %q, got %q", first, again)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the handler to run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyStoreIsBounded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(newIdempotency(time.Minute, 2))
+	var calls int32
+	release := make(chan struct{})
+	engine.POST("/api/chat", func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if c.Query("wait") != "" {
+			<-release
+		}
+		c.JSON(http.StatusOK, gin.H{"id": n})
+	})
+
+	send := func(key, query string) string {
+		req, _ := http.NewRequest("POST", "/api/chat"+query, strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	oldest := send("key-1", "")
+	send("key-2", "")
+	send("key-3", "")
+	// key-1 made room for key-3, so it runs again and in turn evicts key-2
+	if again := send("key-1", ""); again == oldest || calls != 4 {
+		t.Errorf("Expected the oldest response to be dropped, got %q after %d calls", again, calls)
+	}
+
+	// With every kept request still running, new keys run without being kept
+	var wg sync.WaitGroup
+	for _, key := range []string{"running-1", "running-2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			send(key, "?wait=1")
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&calls) != 6 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if first, second := send("overflow", ""), send("overflow", ""); first == second {
+		t.Errorf("Expected a key over the cap not to be kept, got the replay %q", second)
+	}
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InFlight counts the requests currently being handled, so shutdown can report how many it drained
+type InFlight struct {
+	count atomic.Int64
+}
+
+// NewInFlight creates a new in-flight request counter
+func NewInFlight() *InFlight {
+	return &InFlight{}
+}
+
+// Middleware tracks each request from the moment it is received until its handler returns
+func (f *InFlight) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f.count.Add(1)
+		defer f.count.Add(-1)
+		c.Next()
+	}
+}
+
+// Count returns the number of requests currently being handled
+func (f *InFlight) Count() int64 {
+	return f.count.Load()
+}
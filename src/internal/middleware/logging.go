@@ -6,43 +6,79 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	dbutils "github.com/offbeat-studio/allama/utils"
 )
 
-// LoggingMiddleware logs all API requests and responses
-func LoggingMiddleware(logDir string) gin.HandlerFunc {
-	logger := dbutils.NewLogger(logDir)
-	dbutils.EnsureLogDirExists(logDir)
+// streamingContentTypes are response types whose bodies are never captured for logging
+var streamingContentTypes = []string{"text/event-stream", "application/x-ndjson"}
+
+// redactedHeaders are the canonical names of request headers carrying credentials, whose
+// values are replaced by redactedValue in the log
+var redactedHeaders = map[string]bool{
+	"Authorization": true,
+	"X-Admin-Token": true,
+}
+
+// redactedValue replaces the values of redacted headers
+const redactedValue = "[REDACTED]"
+
+// LoggingOptions configures what LoggingMiddleware writes
+type LoggingOptions struct {
+	// MaxBodyBytes replaces larger bodies with a truncation marker; zero or less disables the cap
+	MaxBodyBytes int
+	// SkipPaths are request paths, such as health checks, that are not logged at all
+	SkipPaths []string
+	// OmitBodies logs the request line and response status without any bodies
+	OmitBodies bool
+}
+
+// LoggingMiddleware logs API requests and responses as configured by opts
+func LoggingMiddleware(logger *dbutils.Logger, opts LoggingOptions) gin.HandlerFunc {
+	maxBodyBytes := opts.MaxBodyBytes
+	skip := make(map[string]bool, len(opts.SkipPaths))
+	for _, path := range opts.SkipPaths {
+		skip[path] = true
+	}
 
 	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		requestID := GetRequestID(c)
+
 		// Read request body
 		var body interface{}
-		if c.Request.Body != nil {
+		if c.Request.Body != nil && !opts.OmitBodies {
 			requestBody, err := io.ReadAll(c.Request.Body)
 			if err != nil {
-				logger.LogError("Failed to read request body", err)
+				logger.LogError(requestID, "Failed to read request body", err)
 			} else {
 				if len(requestBody) > 0 {
-					if err := json.Unmarshal(requestBody, &body); err != nil {
-						body = string(requestBody)
-					}
+					body = bodyForLog(requestBody, len(requestBody), maxBodyBytes)
 					c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 				}
 			}
 		}
 
-		// Log request
+		// Log request, without credentials
 		headers := make(map[string][]string)
 		for k, v := range c.Request.Header {
+			if redactedHeaders[k] {
+				v = []string{redactedValue}
+			}
 			headers[k] = v
 		}
-		logger.LogRequest(c.Request.Method, c.Request.URL.Path, headers, body)
+		logger.LogRequest(requestID, c.Request.Method, c.Request.URL.Path, c.ClientIP(), headers, body)
 
 		// Capture response
-		w := &responseBodyWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
-		c.Writer = w
+		w := &responseBodyWriter{body: &bytes.Buffer{}, limit: maxBodyBytes, ResponseWriter: c.Writer}
+		if !opts.OmitBodies {
+			c.Writer = w
+		}
 
 		// Process request
 		c.Next()
@@ -50,28 +86,82 @@ func LoggingMiddleware(logDir string) gin.HandlerFunc {
 		// Log response only if status code is not 200
 		statusCode := c.Writer.Status()
 		if statusCode != 200 {
-			responseBody := w.body.String()
 			var respBody interface{}
-			if len(responseBody) > 0 {
-				if err := json.Unmarshal([]byte(responseBody), &respBody); err != nil {
-					respBody = responseBody
-				}
+			if w.skipped {
+				respBody = "<streamed response not captured>"
+			} else if w.size > 0 {
+				respBody = bodyForLog(w.body.Bytes(), w.size, maxBodyBytes)
 			}
-			logger.LogResponse(statusCode, respBody)
+			logger.LogResponse(requestID, statusCode, respBody)
 		}
 	}
 }
 
+// bodyForLog returns a body as decoded JSON, or as a string when it is not JSON.
+// Bodies whose total size exceeds the limit are replaced with a marker.
+func bodyForLog(data []byte, size, limit int) interface{} {
+	if limit > 0 && size > limit {
+		return fmt.Sprintf("<truncated %d bytes>", size)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return string(data)
+	}
+	return decoded
+}
+
+// responseBodyWriter tees the response into a buffer of at most limit bytes,
+// and skips capturing streaming responses altogether
 type responseBodyWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body    *bytes.Buffer
+	limit   int
+	size    int
+	skipped bool
 }
 
-func (w responseBodyWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+func (w *responseBodyWriter) Write(b []byte) (int, error) {
+	w.capture(b)
 	return w.ResponseWriter.Write(b)
 }
 
+func (w *responseBodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture records the size of every write but only buffers up to the limit
+func (w *responseBodyWriter) capture(b []byte) {
+	if w.skipped || isStreamingContentType(w.Header().Get("Content-Type")) {
+		w.skipped = true
+		w.body.Reset()
+		return
+	}
+
+	w.size += len(b)
+	if w.limit > 0 {
+		room := w.limit - w.body.Len()
+		if room <= 0 {
+			return
+		}
+		if len(b) > room {
+			b = b[:room]
+		}
+	}
+	w.body.Write(b)
+}
+
+// isStreamingContentType reports whether a content type carries a streamed response
+func isStreamingContentType(contentType string) bool {
+	for _, streaming := range streamingContentTypes {
+		if strings.HasPrefix(contentType, streaming) {
+			return true
+		}
+	}
+	return false
+}
+
 // EnsureLogDirExists checks if the log directory exists and creates it if not
 func EnsureLogDirExists(logDir string) error {
 	if _, err := os.Stat(logDir); os.IsNotExist(err) {
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	dbutils "github.com/offbeat-studio/allama/utils"
+)
+
+func TestBodyForLogTruncationBoundary(t *testing.T) {
+	body := []byte(`"` + strings.Repeat("a", 8) + `"`) // 10 bytes
+
+	if got := bodyForLog(body, len(body), 10); got != strings.Repeat("a", 8) {
+		t.Errorf("Expected body at the limit to be kept, got %v", got)
+	}
+	if got := bodyForLog(body, len(body), 9); got != "<truncated 10 bytes>" {
+		t.Errorf("Expected body over the limit to be truncated, got %v", got)
+	}
+	if got := bodyForLog(body, len(body), 0); got != strings.Repeat("a", 8) {
+		t.Errorf("Expected no cap when the limit is zero, got %v", got)
+	}
+}
+
+func TestResponseBodyWriterCapsBuffer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	w := &responseBodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}, limit: 4}
+
+	w.Write([]byte("abc"))
+	w.Write([]byte("defgh"))
+
+	if w.body.String() != "abcd" {
+		t.Errorf("Expected buffer capped at 4 bytes, got %q", w.body.String())
+	}
+	if w.size != 8 {
+		t.Errorf("Expected total size 8, got %d", w.size)
+	}
+	if recorder.Body.String() != "abcdefgh" {
+		t.Errorf("Expected the client to receive the full body, got %q", recorder.Body.String())
+	}
+}
+
+func TestResponseBodyWriterSkipsStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	w := &responseBodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Write([]byte("data: {}\n\n"))
+
+	if !w.skipped || w.body.Len() != 0 {
+		t.Errorf("Expected streaming response not to be captured, got %q", w.body.String())
+	}
+}
+
+func TestLoggingMiddlewareTruncatesLargeBodies(t *testing.T) {
+	logDir := t.TempDir()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	logger := dbutils.NewLogger(logDir)
+	engine.Use(LoggingMiddleware(logger, LoggingOptions{MaxBodyBytes: 16}))
+	engine.POST("/echo", func(c *gin.Context) {
+		c.String(http.StatusBadRequest, strings.Repeat("x", 32))
+	})
+
+	req, _ := http.NewRequest("POST", "/echo", strings.NewReader(`{"prompt":"`+strings.Repeat("y", 32)+`"}`))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	logger.Close()
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+
+	logged := string(data)
+	if strings.Contains(logged, strings.Repeat("y", 32)) || strings.Contains(logged, strings.Repeat("x", 32)) {
+		t.Errorf("Expected large bodies to be truncated, got %s", logged)
+	}
+	if !strings.Contains(logged, "truncated 45 bytes") || !strings.Contains(logged, "truncated 32 bytes") {
+		t.Errorf("Expected truncation markers, got %s", logged)
+	}
+}
+
+func TestLoggingMiddlewareSkipsPathsAndBodies(t *testing.T) {
+	logDir := t.TempDir()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	logger := dbutils.NewLogger(logDir)
+	engine.Use(LoggingMiddleware(logger, LoggingOptions{SkipPaths: []string{"/health"}, OmitBodies: true}))
+	engine.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusServiceUnavailable, "unhealthy")
+	})
+	engine.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusBadRequest, "rejected "+string(body))
+	})
+
+	req, _ := http.NewRequest("GET", "/health", nil)
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	logger.Flush()
+	if files, _ := filepath.Glob(filepath.Join(logDir, "*.log")); len(files) != 0 {
+		t.Fatalf("Expected no log entry for a skipped path, got %v", files)
+	}
+
+	req, _ = http.NewRequest("POST", "/echo", strings.NewReader(`{"prompt":"secret"}`))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Body.String() != `rejected {"prompt":"secret"}` {
+		t.Errorf("Expected the handler to still read the body, got %q", w.Body.String())
+	}
+	logger.Close()
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	logged := string(data)
+	if !strings.Contains(logged, "/echo") || !strings.Contains(logged, "400") {
+		t.Errorf("Expected the request line and status to be logged, got %s", logged)
+	}
+	if strings.Contains(logged, "secret") || strings.Contains(logged, "/health") {
+		t.Errorf("Expected no bodies and no skipped paths in the log, got %s", logged)
+	}
+}
+
+func TestLoggingMiddlewareRedactsCredentials(t *testing.T) {
+	logDir := t.TempDir()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	logger := dbutils.NewLogger(logDir)
+	engine.Use(LoggingMiddleware(logger, LoggingOptions{}))
+	engine.POST("/echo", func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer sk-gateway-key" {
+			t.Errorf("Expected the handler to see the real header, got %q", c.GetHeader("Authorization"))
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("POST", "/echo", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer sk-gateway-key")
+	req.Header.Set("X-Admin-Token", "admin-secret")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	logger.Close()
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	logged := string(data)
+	if strings.Contains(logged, "sk-gateway-key") || strings.Contains(logged, "admin-secret") {
+		t.Errorf("Expected no credentials to be logged, got %s", logged)
+	}
+	if !strings.Contains(logged, `"Authorization":["[REDACTED]"]`) {
+		t.Errorf("Expected the header to be logged as redacted, got %s", logged)
+	}
+}
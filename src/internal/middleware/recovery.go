@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	dbutils "github.com/offbeat-studio/allama/utils"
+)
+
+// Recovery recovers from panics in the handlers after it. The panic and its stack are logged
+// with the request ID, and the client gets a JSON 500 error naming the request ID instead of
+// the panic, unless the response had already started.
+func Recovery(logger *dbutils.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler deliberately aborts the response and is left to net/http
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			requestID := GetRequestID(c)
+			logger.Log(dbutils.ERROR, requestID, "Recovered from panic", map[string]interface{}{
+				"panic":  fmt.Sprint(rec),
+				"method": c.Request.Method,
+				"path":   c.Request.URL.Path,
+				"stack":  string(debug.Stack()),
+			})
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			RespondErrorCode(c, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Internal server error (request ID %s)", requestID))
+		}()
+		c.Next()
+	}
+}
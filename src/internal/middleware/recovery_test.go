@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	dbutils "github.com/offbeat-studio/allama/utils"
+)
+
+func TestRecoveryRespondsWithJSONAndLogsStack(t *testing.T) {
+	logDir := t.TempDir()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	logger := dbutils.NewLogger(logDir)
+	engine.Use(RequestID(), Recovery(logger))
+	engine.GET("/api/v1/models", func(c *gin.Context) {
+		var providers map[string]string
+		providers["openai"] = "boom" // assignment to a nil map panics
+	})
+
+	req, _ := http.NewRequest("GET", "/api/v1/models", nil)
+	req.Header.Set(RequestIDHeader, "req-panic")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	logger.Close()
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+	}
+	if response.Error.Code != "internal_error" || !strings.Contains(response.Error.Message, "req-panic") {
+		t.Errorf("Expected an internal_error naming the request ID, got %+v", response.Error)
+	}
+	if strings.Contains(w.Body.String(), "nil map") {
+		t.Errorf("Expected the panic not to be exposed, got %s", w.Body.String())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	logged := string(data)
+	if !strings.Contains(logged, `"request_id":"req-panic"`) || !strings.Contains(logged, "assignment to entry in nil map") || !strings.Contains(logged, "recovery_test.go") {
+		t.Errorf("Expected the panic, its stack and the request ID to be logged, got %s", logged)
+	}
+}
@@ -1,12 +1,61 @@
 package models
 
-// Provider represents an AI service provider configuration
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Provider represents an AI service provider configuration. Name uniquely labels the
+// instance, while Type selects the implementation (openai, anthropic, ollama, ...).
 type Provider struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
+	Type     string `json:"type"`
 	APIKey   string `json:"api_key"`
 	Host     string `json:"host"`
 	IsActive bool   `json:"is_active"`
+
+	// Headers are added to every upstream request, e.g. HTTP-Referer and X-Title for OpenRouter
+	Headers map[string]string `json:"headers,omitempty"`
+	// DefaultMaxTokens is sent as max_tokens when a client does not set it; zero leaves it to the provider
+	DefaultMaxTokens int `json:"default_max_tokens,omitempty"`
+	// SystemPrompt is added to every chat request, merged with the client's system messages
+	// according to SystemPromptMode: prepend (the default), append or override
+	SystemPrompt     string `json:"system_prompt,omitempty"`
+	SystemPromptMode string `json:"system_prompt_mode,omitempty"`
+
+	// Priority orders the providers serving a model: requests go to the highest priority first
+	// and lower priorities act as fallbacks
+	Priority int `json:"priority"`
+	// Weight is the provider's share of load among providers of equal priority; values below 1 count as 1
+	Weight int `json:"weight"`
+
+	// Proxy is the URL of the HTTP(S) proxy upstream requests go through; empty uses the
+	// proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	Proxy string `json:"proxy,omitempty"`
+	// TLS customizes how the provider's certificate is checked; nil uses the system's roots
+	TLS *ProviderTLS `json:"tls,omitempty"`
+}
+
+// ProviderTLS holds the TLS settings of a provider with a self-signed certificate or mutual TLS
+type ProviderTLS struct {
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to the system's
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the PEM client certificate and key presented for mutual TLS
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// InsecureSkipVerify disables certificate verification altogether
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// ProviderType returns the provider's implementation type, falling back to its name
+// when no type is set
+func (p *Provider) ProviderType() string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return p.Name
 }
 
 // Model represents a specific AI model offered by a provider
@@ -16,4 +65,245 @@ type Model struct {
 	Name       string `json:"name"`
 	ModelID    string `json:"model_id"`
 	IsActive   bool   `json:"is_active"`
+	// Unlisted marks a model deactivated because its provider stopped listing it, which is
+	// reactivated when the provider lists it again
+	Unlisted bool `json:"unlisted,omitempty"`
+
+	// CreatedAt is when the model was first stored and UpdatedAt when its row last changed;
+	// both are zero for models fetched live that have not been stored
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Metadata overrides what is known about the model by default; nil uses the defaults
+	Metadata *ModelMetadata `json:"metadata,omitempty"`
+}
+
+// ModelMetadata describes what a model can do, as reported by /api/show. Zero fields fall back
+// to the defaults known for the model.
+type ModelMetadata struct {
+	// Capabilities uses Ollama's names: completion, tools, vision, thinking and embedding
+	Capabilities  []string `json:"capabilities,omitempty"`
+	ContextLength int      `json:"context_length,omitempty"`
+	Family        string   `json:"family,omitempty"`
+	// ParameterSize and QuantizationLevel use Ollama's notation, e.g. "8.0B" and "Q4_K_M"
+	ParameterSize     string `json:"parameter_size,omitempty"`
+	QuantizationLevel string `json:"quantization_level,omitempty"`
+}
+
+// Alias routes requests for a model name to a target model. When Provider is set the
+// request goes to that provider instance instead of the providers serving the target model.
+type Alias struct {
+	ID       int    `json:"id"`
+	Alias    string `json:"alias"`
+	ModelID  string `json:"model_id"`
+	Provider string `json:"provider,omitempty"`
+	// Copied marks an alias created by /api/copy, which clients may remove through /api/delete;
+	// other aliases are managed through the admin API only
+	Copied bool `json:"copied,omitempty"`
+}
+
+// Usage represents the token accounting reported for a single request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Message represents a single chat message exchanged with a provider.
+// Content always holds the plain text; Parts is set when the client sent multimodal content.
+type Message struct {
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// ContentPart represents a single text or image part of a multimodal message
+type ContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// HasImages reports whether the message carries any image parts
+func (m Message) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == "image_url" {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON accepts content as a plain string or as an OpenAI content-part array,
+// and images as Ollama's list of base64 strings
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type alias Message
+	var raw struct {
+		alias
+		Content json.RawMessage `json:"content"`
+		Images  []string        `json:"images"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = Message(raw.alias)
+	m.Content = ""
+	m.Parts = nil
+
+	if len(raw.Content) > 0 && string(raw.Content) != "null" {
+		if raw.Content[0] == '"' {
+			if err := json.Unmarshal(raw.Content, &m.Content); err != nil {
+				return err
+			}
+		} else {
+			var parts []struct {
+				Type     string          `json:"type"`
+				Text     string          `json:"text"`
+				ImageURL json.RawMessage `json:"image_url"`
+			}
+			if err := json.Unmarshal(raw.Content, &parts); err != nil {
+				return err
+			}
+			var texts []string
+			for _, part := range parts {
+				switch part.Type {
+				case "text":
+					texts = append(texts, part.Text)
+					m.Parts = append(m.Parts, ContentPart{Type: "text", Text: part.Text})
+				case "image_url":
+					// image_url is either a string or an object with a url field
+					var url string
+					if err := json.Unmarshal(part.ImageURL, &url); err != nil {
+						var obj struct {
+							URL string `json:"url"`
+						}
+						if err := json.Unmarshal(part.ImageURL, &obj); err != nil {
+							return err
+						}
+						url = obj.URL
+					}
+					m.Parts = append(m.Parts, ContentPart{Type: "image_url", ImageURL: url})
+				}
+			}
+			m.Content = strings.Join(texts, "\n")
+		}
+	}
+
+	if len(raw.Images) > 0 {
+		if len(m.Parts) == 0 && m.Content != "" {
+			m.Parts = append(m.Parts, ContentPart{Type: "text", Text: m.Content})
+		}
+		for _, image := range raw.Images {
+			m.Parts = append(m.Parts, ContentPart{
+				Type:     "image_url",
+				ImageURL: "data:" + detectImageMediaType(image) + ";base64," + image,
+			})
+		}
+	}
+	return nil
+}
+
+// MarshalJSON emits the OpenAI message shape, using a content-part array when images are present
+func (m Message) MarshalJSON() ([]byte, error) {
+	type alias Message
+	if !m.HasImages() {
+		return json.Marshal(alias(m))
+	}
+
+	parts := make([]map[string]interface{}, 0, len(m.Parts))
+	for _, part := range m.Parts {
+		if part.Type == "image_url" {
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]string{"url": part.ImageURL},
+			})
+		} else {
+			parts = append(parts, map[string]interface{}{
+				"type": "text",
+				"text": part.Text,
+			})
+		}
+	}
+	return json.Marshal(struct {
+		alias
+		Content []map[string]interface{} `json:"content"`
+	}{alias(m), parts})
+}
+
+// DataURI splits a base64 data URI image into its media type and payload
+func (p ContentPart) DataURI() (mediaType string, data string, ok bool) {
+	rest, found := strings.CutPrefix(p.ImageURL, "data:")
+	if !found {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(header, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// detectImageMediaType guesses the media type of a base64 encoded image from its leading bytes
+func detectImageMediaType(image string) string {
+	switch {
+	case strings.HasPrefix(image, "iVBOR"):
+		return "image/png"
+	case strings.HasPrefix(image, "R0lGOD"):
+		return "image/gif"
+	case strings.HasPrefix(image, "UklGR"):
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// ToolCall represents a function call requested by the model
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction holds the function name and its JSON-encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// UnmarshalJSON accepts arguments either as a JSON-encoded string (OpenAI) or as an object (Ollama)
+func (f *ToolCallFunction) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	f.Name = raw.Name
+	f.Arguments = ""
+	if len(raw.Arguments) == 0 || string(raw.Arguments) == "null" {
+		return nil
+	}
+	if raw.Arguments[0] == '"' {
+		return json.Unmarshal(raw.Arguments, &f.Arguments)
+	}
+	f.Arguments = string(raw.Arguments)
+	return nil
+}
+
+// ArgumentsObject decodes the arguments into a generic object, as expected by Ollama and Anthropic
+func (f ToolCallFunction) ArgumentsObject() map[string]interface{} {
+	args := make(map[string]interface{})
+	if f.Arguments != "" {
+		json.Unmarshal([]byte(f.Arguments), &args)
+	}
+	return args
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMessageUnmarshalPlainString(t *testing.T) {
+	var msg Message
+	if err := json.Unmarshal([]byte(`{"role":"user","content":"Hello"}`), &msg); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if msg.Content != "Hello" || msg.HasImages() {
+		t.Errorf("Expected plain text message, got %+v", msg)
+	}
+}
+
+func TestMessageUnmarshalContentParts(t *testing.T) {
+	var msg Message
+	body := `{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}`
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if msg.Content != "What is this?" {
+		t.Errorf("Expected text content to be extracted, got %q", msg.Content)
+	}
+	if !msg.HasImages() || len(msg.Parts) != 2 {
+		t.Fatalf("Expected text and image parts, got %+v", msg.Parts)
+	}
+
+	mediaType, data, ok := msg.Parts[1].DataURI()
+	if !ok || mediaType != "image/png" || data != "iVBORw0KGgo=" {
+		t.Errorf("Expected png data URI, got %q %q %v", mediaType, data, ok)
+	}
+
+	// Images are marshaled back as an OpenAI content array
+	out, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	if !strings.Contains(string(out), `"image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}`) {
+		t.Errorf("Expected OpenAI image_url part, got %s", out)
+	}
+}
+
+func TestMessageUnmarshalOllamaImages(t *testing.T) {
+	var msg Message
+	if err := json.Unmarshal([]byte(`{"role":"user","content":"Describe","images":["/9j/4AAQ"]}`), &msg); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if !msg.HasImages() || len(msg.Parts) != 2 {
+		t.Fatalf("Expected text and image parts, got %+v", msg.Parts)
+	}
+	if mediaType, _, _ := msg.Parts[1].DataURI(); mediaType != "image/jpeg" {
+		t.Errorf("Expected image/jpeg, got %s", mediaType)
+	}
+}
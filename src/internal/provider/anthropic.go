@@ -2,51 +2,88 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/offbeat-studio/allama/internal/models"
 )
 
+// defaultAnthropicHost is used when no host is configured
+const defaultAnthropicHost = "https://api.anthropic.com"
+
 // AnthropicProvider handles interactions with the Anthropic API
 type AnthropicProvider struct {
 	APIKey string
 	Host   string
 	client *http.Client
+	// streamClient sends streamed requests, which have no overall timeout
+	streamClient *http.Client
+
+	// requestID is forwarded upstream as X-Request-ID
+	requestID string
+	// headers are the provider's custom headers, added to every upstream request
+	headers map[string]string
 }
 
 // NewAnthropicProvider creates a new instance of AnthropicProvider
 func NewAnthropicProvider(apiKey string, host string) *AnthropicProvider {
+	if host == "" {
+		host = defaultAnthropicHost
+	}
+	client, streamClient := newClients()
 	return &AnthropicProvider{
-		APIKey: apiKey,
-		Host:   host,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		APIKey:       apiKey,
+		Host:         strings.TrimSuffix(host, "/"),
+		client:       client,
+		streamClient: streamClient,
 	}
 }
 
+// Ping checks that the provider is reachable by listing its models
+func (p *AnthropicProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
+
+// SetRequestID sets the correlation ID forwarded with every upstream request
+func (p *AnthropicProvider) SetRequestID(id string) {
+	p.requestID = id
+}
+
+// SetHeaders sets the custom headers added to every upstream request
+func (p *AnthropicProvider) SetHeaders(headers map[string]string) {
+	p.headers = headers
+}
+
+// SetTransport sets the transport of upstream requests, e.g. to go through a proxy
+func (p *AnthropicProvider) SetTransport(transport http.RoundTripper) {
+	p.client.Transport = transport
+	p.streamClient.Transport = transport
+}
+
 // GetModels retrieves the list of available models from Anthropic
-func (p *AnthropicProvider) GetModels() ([]models.Model, error) {
+func (p *AnthropicProvider) GetModels(ctx context.Context) ([]models.Model, error) {
 	url := fmt.Sprintf("%s/v1/models", p.Host)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
 	req.Header.Set("x-api-key", p.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
 	var modelsResp struct {
@@ -55,7 +92,7 @@ func (p *AnthropicProvider) GetModels() ([]models.Model, error) {
 			Name string `json:"name"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+	if err := decodeResponse(resp, &modelsResp); err != nil {
 		return nil, err
 	}
 
@@ -71,76 +108,408 @@ func (p *AnthropicProvider) GetModels() ([]models.Model, error) {
 	return modelList, nil
 }
 
-// Chat sends a chat request to Anthropic and returns the response
-func (p *AnthropicProvider) Chat(modelID string, messages []map[string]string) (string, error) {
-	url := fmt.Sprintf("%s/v1/messages", p.Host)
+// anthropicDefaultMaxTokens is used when neither the client nor the provider's configured default
+// sets max_tokens, which Anthropic requires
+const anthropicDefaultMaxTokens = 1024
 
+// anthropicOptionFields maps recognized sampling options to Anthropic request fields
+var anthropicOptionFields = map[string]string{
+	"temperature": "temperature",
+	"top_p":       "top_p",
+	"top_k":       "top_k",
+	"max_tokens":  "max_tokens",
+}
+
+// buildChatPayload builds the Anthropic messages request body
+func (p *AnthropicProvider) buildChatPayload(modelID string, messages []models.Message, opts map[string]interface{}, stream bool) map[string]interface{} {
 	// Convert messages to Anthropic format
-	var anthropicMessages []map[string]interface{}
-	var systemMessage string
-	for _, msg := range messages {
-		role := msg["role"]
-		content := msg["content"]
-		if role == "system" {
-			systemMessage = content
-		} else {
-			// Ensure role is compatible with Anthropic API (e.g., 'user' or 'assistant')
-			anthropicRole := role
-			if role == "user" || role == "assistant" {
-				anthropicRole = role
-			} else {
-				// Default to 'user' for unknown roles to maintain compatibility
-				anthropicRole = "user"
-			}
-			anthropicMessages = append(anthropicMessages, map[string]interface{}{
-				"role":    anthropicRole,
-				"content": content,
-			})
-		}
+	anthropicMessages, systemMessage := convertAnthropicMessages(messages)
+	// Anthropic has no JSON mode, so it is requested through the system prompt
+	if wantsJSONInstruction(opts) {
+		systemMessage = withInstruction(systemMessage, jsonModeInstruction)
 	}
 
 	payload := map[string]interface{}{
 		"model":      modelID,
-		"max_tokens": 1024,
+		"max_tokens": anthropicDefaultMaxTokens,
 		"messages":   anthropicMessages,
 		"system":     systemMessage,
 	}
+	applyOptions(payload, opts, anthropicOptionFields)
+	if stop, ok := opts["stop"]; ok {
+		if sequences := stopSequences(stop); len(sequences) > 0 {
+			payload["stop_sequences"] = sequences
+		}
+	}
+	if tools := convertAnthropicTools(opts["tools"]); len(tools) > 0 {
+		payload["tools"] = tools
+		if choice := convertAnthropicToolChoice(opts["tool_choice"]); choice != nil {
+			payload["tool_choice"] = choice
+		}
+	}
+	if stream {
+		payload["stream"] = true
+	}
+	return payload
+}
+
+// Chat sends a chat request to Anthropic and returns the response
+func (p *AnthropicProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	url := fmt.Sprintf("%s/v1/messages", p.Host)
+	payload := p.buildChatPayload(modelID, messages, opts, false)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
 	req.Header.Set("x-api-key", p.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 	req.Header.Set("content-type", "application/json")
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
+	return parseAnthropicResponse(resp)
+}
+
+// parseAnthropicResponse converts a Messages API response body into a ChatResult. The text
+// blocks are joined in order into the content, tool_use blocks become tool calls and thinking
+// blocks the thinking; other block types are skipped.
+func parseAnthropicResponse(resp *http.Response) (*ChatResult, error) {
 	var chatResp struct {
 		Content []struct {
-			Text string `json:"text"`
+			Type     string          `json:"type"`
+			Text     string          `json:"text"`
+			Thinking string          `json:"thinking"`
+			ID       string          `json:"id"`
+			Name     string          `json:"name"`
+			Input    json.RawMessage `json:"input"`
 		} `json:"content"`
+		StopReason string         `json:"stop_reason"`
+		Usage      anthropicUsage `json:"usage"`
+	}
+	raw, err := readResponse(resp, &chatResp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ChatResult{
+		FinishReason: anthropicFinishReason(chatResp.StopReason),
+		Usage: &models.Usage{
+			PromptTokens:     chatResp.Usage.InputTokens,
+			CompletionTokens: chatResp.Usage.OutputTokens,
+			TotalTokens:      chatResp.Usage.InputTokens + chatResp.Usage.OutputTokens,
+		},
+	}
+	var content, thinking strings.Builder
+	for _, block := range chatResp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, models.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: models.ToolCallFunction{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+	result.Content = content.String()
+	result.Thinking = thinking.String()
+	return checkResult(resp, raw, result)
+}
+
+// ChatStream sends a streaming chat request to Anthropic and invokes onChunk for every text delta
+func (p *AnthropicProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	url := fmt.Sprintf("%s/v1/messages", p.Host)
+	payload := p.buildChatPayload(modelID, messages, opts, true)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("accept", "text/event-stream")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newUpstreamError(resp)
+	}
+	if err := checkContentType(resp, "text/event-stream", "text/plain"); err != nil {
+		return err
+	}
+
+	stream := &anthropicStream{}
+	return readSSE(resp.Body, func(event, data string) error {
+		return stream.handle([]byte(data), onChunk)
+	})
+}
+
+// anthropicUsage is the token usage reported in Messages API responses and stream events
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicStream relays the events of a Messages API stream. message_start reports the
+// input tokens and message_delta the output tokens, which are relayed as a usage chunk.
+type anthropicStream struct {
+	usage models.Usage
+}
+
+// handle relays a single stream event. It returns errStreamDone once the message is complete.
+func (s *anthropicStream) handle(data []byte, onChunk func(StreamChunk) error) error {
+	var streamEvent struct {
+		Type    string `json:"type"`
+		Message struct {
+			Usage anthropicUsage `json:"usage"`
+		} `json:"message"`
+		Delta struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+		Usage anthropicUsage `json:"usage"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &streamEvent); err != nil {
+		return err
+	}
+
+	switch streamEvent.Type {
+	case "message_start":
+		s.usage.PromptTokens = streamEvent.Message.Usage.InputTokens
+	case "content_block_delta":
+		if streamEvent.Delta.Text == "" {
+			return nil
+		}
+		return onChunk(StreamChunk{Content: streamEvent.Delta.Text})
+	case "message_delta":
+		s.usage.CompletionTokens = streamEvent.Usage.OutputTokens
+		s.usage.TotalTokens = s.usage.PromptTokens + s.usage.CompletionTokens
+		usage := s.usage
+		return onChunk(StreamChunk{Usage: &usage})
+	case "message_stop":
+		return errStreamDone
+	case "error":
+		return fmt.Errorf("anthropic stream error: %s: %s", streamEvent.Error.Type, streamEvent.Error.Message)
+	}
+	return nil
+}
+
+// Generate completes a prompt through the Messages API, which has no text completion mode
+func (p *AnthropicProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	return generateWithChat(ctx, p, modelID, prompt, opts)
+}
+
+// Embeddings is not supported by the Anthropic API
+func (p *AnthropicProvider) Embeddings(ctx context.Context, modelID string, input string) ([]float64, error) {
+	return nil, ErrEmbeddingsUnsupported
+}
+
+// anthropicFinishReason maps an Anthropic stop_reason to the OpenAI finish_reason vocabulary
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "refusal":
+		return "content_filter"
+	case "":
+		return ""
+	default:
+		return "stop"
+	}
+}
+
+// convertAnthropicMessages splits out the system prompt and maps roles to the Anthropic format
+func convertAnthropicMessages(messages []models.Message) ([]map[string]interface{}, string) {
+	var anthropicMessages []map[string]interface{}
+	var systemMessage string
+	for _, msg := range messages {
+		role := msg.Role
+		content := msg.Content
+		switch {
+		case role == "system":
+			systemMessage = content
+		case role == "tool":
+			// Tool results are sent back as tool_result blocks in a user turn
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     content,
+			}
+			// Consecutive tool results must share a single user message
+			if n := len(anthropicMessages); n > 0 {
+				if blocks, ok := anthropicMessages[n-1]["content"].([]map[string]interface{}); ok && anthropicMessages[n-1]["role"] == "user" {
+					anthropicMessages[n-1]["content"] = append(blocks, block)
+					continue
+				}
+			}
+			anthropicMessages = append(anthropicMessages, map[string]interface{}{
+				"role":    "user",
+				"content": []map[string]interface{}{block},
+			})
+		case role == "assistant" && len(msg.ToolCalls) > 0:
+			// Assistant tool calls become tool_use blocks alongside any text
+			var blocks []map[string]interface{}
+			if content != "" {
+				blocks = append(blocks, map[string]interface{}{
+					"type": "text",
+					"text": content,
+				})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": call.Function.ArgumentsObject(),
+				})
+			}
+			anthropicMessages = append(anthropicMessages, map[string]interface{}{
+				"role":    "assistant",
+				"content": blocks,
+			})
+		default:
+			// Ensure role is compatible with Anthropic API (e.g., 'user' or 'assistant')
+			anthropicRole := role
+			if role == "user" || role == "assistant" {
+				anthropicRole = role
+			} else {
+				// Default to 'user' for unknown roles to maintain compatibility
+				anthropicRole = "user"
+			}
+			var anthropicContent interface{} = content
+			if msg.HasImages() {
+				anthropicContent = convertAnthropicParts(msg.Parts)
+			}
+			anthropicMessages = append(anthropicMessages, map[string]interface{}{
+				"role":    anthropicRole,
+				"content": anthropicContent,
+			})
+		}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", err
+	return anthropicMessages, systemMessage
+}
+
+// convertAnthropicParts maps multimodal content parts to Anthropic text and image blocks
+func convertAnthropicParts(parts []models.ContentPart) []map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "image_url" {
+			blocks = append(blocks, map[string]interface{}{
+				"type": "text",
+				"text": part.Text,
+			})
+			continue
+		}
+
+		source := map[string]interface{}{
+			"type": "url",
+			"url":  part.ImageURL,
+		}
+		if mediaType, data, ok := part.DataURI(); ok {
+			source = map[string]interface{}{
+				"type":       "base64",
+				"media_type": mediaType,
+				"data":       data,
+			}
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":   "image",
+			"source": source,
+		})
+	}
+	return blocks
+}
+
+// convertAnthropicTools maps OpenAI-style function tools to Anthropic tool definitions
+func convertAnthropicTools(tools interface{}) []map[string]interface{} {
+	list, ok := tools.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var anthropicTools []map[string]interface{}
+	for _, item := range list {
+		tool, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		function, ok := tool["function"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		anthropicTool := map[string]interface{}{
+			"name":         function["name"],
+			"input_schema": function["parameters"],
+		}
+		if anthropicTool["input_schema"] == nil {
+			anthropicTool["input_schema"] = map[string]interface{}{"type": "object"}
+		}
+		if description, ok := function["description"]; ok {
+			anthropicTool["description"] = description
+		}
+		anthropicTools = append(anthropicTools, anthropicTool)
 	}
+	return anthropicTools
+}
 
-	if len(chatResp.Content) > 0 {
-		return chatResp.Content[0].Text, nil
+// convertAnthropicToolChoice maps an OpenAI tool_choice value to Anthropic's tool_choice object
+func convertAnthropicToolChoice(choice interface{}) map[string]interface{} {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return map[string]interface{}{"type": "auto"}
+		case "required":
+			return map[string]interface{}{"type": "any"}
+		case "none":
+			return map[string]interface{}{"type": "none"}
+		}
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]interface{}); ok {
+			return map[string]interface{}{"type": "tool", "name": function["name"]}
+		}
 	}
-	return "", fmt.Errorf("no response content found")
+	return nil
 }
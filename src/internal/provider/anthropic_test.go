@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestAnthropicProvider_ChatUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":10,"output_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider("test-key", server.URL)
+	result, err := p.Chat(context.Background(), "claude-3-haiku", []models.Message{{Role: "user", Content: "Hi"}}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Content != "Hello" {
+		t.Errorf("Expected content 'Hello', got %q", result.Content)
+	}
+	if result.Usage == nil {
+		t.Fatal("Expected usage to be populated")
+	}
+	if result.Usage.PromptTokens != 10 || result.Usage.CompletionTokens != 4 || result.Usage.TotalTokens != 14 {
+		t.Errorf("Unexpected usage: %+v", *result.Usage)
+	}
+}
+
+func TestAnthropicProvider_ChatJoinsContentBlocks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[` +
+			`{"type":"thinking","thinking":"The user wants the weather.","signature":"sig"},` +
+			`{"type":"text","text":"Let me check the weather. "},` +
+			`{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},` +
+			`{"type":"text","text":"I will report back."}` +
+			`],"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":30}}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider("test-key", server.URL)
+	result, err := p.Chat(context.Background(), "claude-sonnet-4", []models.Message{{Role: "user", Content: "Weather in Paris?"}}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Content != "Let me check the weather. I will report back." {
+		t.Errorf("Expected every text block in order, got %q", result.Content)
+	}
+	if result.Thinking != "The user wants the weather." {
+		t.Errorf("Expected the thinking block, got %q", result.Thinking)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].ID != "toolu_1" || result.ToolCalls[0].Function.Name != "get_weather" || result.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Expected the tool_use block as a tool call, got %+v", result.ToolCalls)
+	}
+	if result.FinishReason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %q", result.FinishReason)
+	}
+}
+
+func TestAnthropicProvider_MapsImageParts(t *testing.T) {
+	p := NewAnthropicProvider("test-key", "https://api.anthropic.com")
+	messages := []models.Message{{
+		Role:    "user",
+		Content: "What is this?",
+		Parts: []models.ContentPart{
+			{Type: "text", Text: "What is this?"},
+			{Type: "image_url", ImageURL: "data:image/png;base64,iVBORw0KGgo="},
+		},
+	}}
+
+	payload := p.buildChatPayload("claude-3-5-sonnet", messages, nil, false)
+	converted := payload["messages"].([]map[string]interface{})
+	blocks, ok := converted[0]["content"].([]map[string]interface{})
+	if !ok || len(blocks) != 2 {
+		t.Fatalf("Expected text and image blocks, got %v", converted[0]["content"])
+	}
+	source := blocks[1]["source"].(map[string]interface{})
+	if blocks[1]["type"] != "image" || source["type"] != "base64" || source["media_type"] != "image/png" || source["data"] != "iVBORw0KGgo=" {
+		t.Errorf("Expected base64 image block, got %v", blocks[1])
+	}
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// defaultAzureAPIVersion is used when AZURE_OPENAI_API_VERSION is not set
+const defaultAzureAPIVersion = "2024-10-21"
+
+// AzureOpenAIProvider handles interactions with Azure OpenAI. Requests use the
+// OpenAI wire format but are addressed to deployments and authenticated with an api-key header.
+type AzureOpenAIProvider struct {
+	*OpenAIProvider
+	APIVersion string
+	// Deployments maps model IDs to Azure deployment names
+	Deployments map[string]string
+}
+
+// NewAzureOpenAIProvider creates a new instance of AzureOpenAIProvider for the given resource endpoint
+func NewAzureOpenAIProvider(apiKey, endpoint, apiVersion string, deployments map[string]string) *AzureOpenAIProvider {
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	if deployments == nil {
+		deployments = map[string]string{}
+	}
+
+	p := &AzureOpenAIProvider{
+		OpenAIProvider: NewOpenAIProvider(apiKey, endpoint),
+		APIVersion:     apiVersion,
+		Deployments:    deployments,
+	}
+	// Azure has no public default endpoint, so never fall back to api.openai.com
+	p.Host = strings.TrimSuffix(endpoint, "/")
+	p.endpoint = p.deploymentURL
+	p.authorize = func(req *http.Request) {
+		req.Header.Set("api-key", p.APIKey)
+	}
+	return p
+}
+
+// Deployment returns the deployment serving a model, falling back to the model ID itself
+func (p *AzureOpenAIProvider) Deployment(modelID string) string {
+	if deployment, ok := p.Deployments[modelID]; ok {
+		return deployment
+	}
+	return modelID
+}
+
+// deploymentURL builds {endpoint}/openai/deployments/{deployment}{path}?api-version=...
+func (p *AzureOpenAIProvider) deploymentURL(path, modelID string) string {
+	query := "?api-version=" + url.QueryEscape(p.APIVersion)
+	if modelID == "" {
+		return fmt.Sprintf("%s/openai%s%s", p.Host, path, query)
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s%s%s", p.Host, url.PathEscape(p.Deployment(modelID)), path, query)
+}
+
+// GetModels lists the model IDs configured in the deployment mapping
+func (p *AzureOpenAIProvider) GetModels(ctx context.Context) ([]models.Model, error) {
+	modelIDs := make([]string, 0, len(p.Deployments))
+	for modelID := range p.Deployments {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	modelList := make([]models.Model, 0, len(modelIDs))
+	for _, modelID := range modelIDs {
+		modelList = append(modelList, models.Model{
+			Name:     modelID,
+			ModelID:  modelID,
+			IsActive: true,
+		})
+	}
+	return modelList, nil
+}
+
+// Ping checks that the resource is reachable and the key is accepted by listing its base models
+func (p *AzureOpenAIProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p.OpenAIProvider)
+}
+
+// ParseDeployments parses a "model=deployment,model=deployment" mapping
+func ParseDeployments(spec string) map[string]string {
+	deployments := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		modelID, deployment, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || strings.TrimSpace(modelID) == "" || strings.TrimSpace(deployment) == "" {
+			continue
+		}
+		deployments[strings.TrimSpace(modelID)] = strings.TrimSpace(deployment)
+	}
+	return deployments
+}
+
+// newAzureProviderFromEnv creates an Azure provider, reading the API version and
+// deployment mapping from AZURE_OPENAI_API_VERSION and AZURE_OPENAI_DEPLOYMENTS
+func newAzureProviderFromEnv(prov *models.Provider) *AzureOpenAIProvider {
+	return NewAzureOpenAIProvider(
+		prov.APIKey,
+		prov.Host,
+		os.Getenv("AZURE_OPENAI_API_VERSION"),
+		ParseDeployments(os.Getenv("AZURE_OPENAI_DEPLOYMENTS")),
+	)
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestAzureOpenAIProvider_Chat(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello from Azure"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewAzureOpenAIProvider("azure-key", server.URL+"/", "2024-06-01", map[string]string{"gpt-4o": "prod-gpt4o"})
+	result, err := p.Chat(context.Background(), "gpt-4o", []models.Message{{Role: "user", Content: "Hi"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if result.Content != "Hello from Azure" {
+		t.Errorf("Expected content from Azure, got %q", result.Content)
+	}
+	if gotPath != "/openai/deployments/prod-gpt4o/chat/completions" {
+		t.Errorf("Expected deployment URL, got %q", gotPath)
+	}
+	if gotVersion != "2024-06-01" {
+		t.Errorf("Expected api-version 2024-06-01, got %q", gotVersion)
+	}
+	if gotKey != "azure-key" || gotAuth != "" {
+		t.Errorf("Expected api-key header only, got api-key=%q Authorization=%q", gotKey, gotAuth)
+	}
+}
+
+func TestAzureOpenAIProvider_Models(t *testing.T) {
+	p := NewAzureOpenAIProvider("key", "https://example.openai.azure.com", "", ParseDeployments("gpt-4o=prod-gpt4o, text-embedding-3-small = embed ,broken"))
+
+	if p.APIVersion != defaultAzureAPIVersion {
+		t.Errorf("Expected default api-version, got %q", p.APIVersion)
+	}
+	if got := p.Deployment("text-embedding-3-small"); got != "embed" {
+		t.Errorf("Expected mapped deployment, got %q", got)
+	}
+	if got := p.Deployment("unmapped"); got != "unmapped" {
+		t.Errorf("Expected unmapped model to be used as the deployment name, got %q", got)
+	}
+
+	modelList, _ := p.GetModels(context.Background())
+	if len(modelList) != 2 || modelList[0].ModelID != "gpt-4o" || modelList[1].ModelID != "text-embedding-3-small" {
+		t.Errorf("Expected the mapped models, got %+v", modelList)
+	}
+}
@@ -0,0 +1,388 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// bedrockDefaultRegion is used when no region is configured
+const bedrockDefaultRegion = "us-east-1"
+
+// bedrockAnthropicVersion is the Messages API version Bedrock expects for Claude models
+const bedrockAnthropicVersion = "bedrock-2023-05-31"
+
+// Model families supported on Bedrock, each with its own request and response shape
+const (
+	bedrockFamilyClaude     = "claude"
+	bedrockFamilyTitanText  = "titan-text"
+	bedrockFamilyTitanEmbed = "titan-embed"
+)
+
+// BedrockProvider handles interactions with the AWS Bedrock Runtime API
+type BedrockProvider struct {
+	Region string
+	// RuntimeHost serves InvokeModel; ControlHost serves ListFoundationModels
+	RuntimeHost string
+	ControlHost string
+	client      *http.Client
+	// streamClient sends streamed requests, which have no overall timeout
+	streamClient *http.Client
+
+	// requestID is forwarded upstream as X-Request-ID
+	requestID string
+	// headers are the provider's custom headers, added to every upstream request
+	headers map[string]string
+
+	credentials func() (awsCredentials, error)
+	now         func() time.Time
+}
+
+// NewBedrockProvider creates a new instance of BedrockProvider. The runtime host defaults
+// to the regional Bedrock Runtime endpoint; credentials come from the standard AWS chain.
+func NewBedrockProvider(region string, runtimeHost string) *BedrockProvider {
+	if region == "" {
+		region = bedrockDefaultRegion
+	}
+	if runtimeHost == "" {
+		runtimeHost = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+	client, streamClient := newClients()
+	return &BedrockProvider{
+		Region:       region,
+		RuntimeHost:  strings.TrimSuffix(runtimeHost, "/"),
+		ControlHost:  fmt.Sprintf("https://bedrock.%s.amazonaws.com", region),
+		client:       client,
+		streamClient: streamClient,
+		credentials:  loadAWSCredentials,
+		now:          time.Now,
+	}
+}
+
+// bedrockRegionFromEnv returns the configured Bedrock region
+func bedrockRegionFromEnv() string {
+	for _, key := range []string{"BEDROCK_REGION", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(key); region != "" {
+			return region
+		}
+	}
+	return bedrockDefaultRegion
+}
+
+// Ping checks that the provider is reachable by listing its models
+func (p *BedrockProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
+
+// SetRequestID sets the correlation ID forwarded with every upstream request
+func (p *BedrockProvider) SetRequestID(id string) {
+	p.requestID = id
+}
+
+// SetHeaders sets the custom headers added to every upstream request
+func (p *BedrockProvider) SetHeaders(headers map[string]string) {
+	p.headers = headers
+}
+
+// SetTransport sets the transport of upstream requests, e.g. to go through a proxy
+func (p *BedrockProvider) SetTransport(transport http.RoundTripper) {
+	p.client.Transport = transport
+	p.streamClient.Transport = transport
+}
+
+// bedrockFamily returns the request shape for a model ID, ignoring any
+// cross-region inference profile prefix such as "us."
+func bedrockFamily(modelID string) string {
+	switch {
+	case strings.Contains(modelID, "anthropic.claude"):
+		return bedrockFamilyClaude
+	case strings.Contains(modelID, "amazon.titan-embed"):
+		return bedrockFamilyTitanEmbed
+	case strings.Contains(modelID, "amazon.titan-text"), strings.Contains(modelID, "amazon.titan-tg1"):
+		return bedrockFamilyTitanText
+	default:
+		return ""
+	}
+}
+
+// do signs and sends a request to a Bedrock endpoint with the given client
+func (p *BedrockProvider) do(ctx context.Context, client *http.Client, method, host, path string, body []byte, accept string) (*http.Response, error) {
+	creds, err := p.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, host+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	signV4(req, body, creds, p.Region, "bedrock", p.now())
+
+	setCustomHeaders(req, p.headers)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newUpstreamError(resp)
+	}
+	return resp, nil
+}
+
+// modelPath builds the escaped runtime path for a model action such as invoke
+func modelPath(modelID, action string) string {
+	return fmt.Sprintf("/model/%s/%s", awsURIEncode(modelID), action)
+}
+
+// GetModels lists the foundation models Bedrock offers that this provider knows how to call
+func (p *BedrockProvider) GetModels(ctx context.Context) ([]models.Model, error) {
+	resp, err := p.do(ctx, p.client, "GET", p.ControlHost, "/foundation-models", nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp struct {
+		ModelSummaries []struct {
+			ModelID   string `json:"modelId"`
+			ModelName string `json:"modelName"`
+		} `json:"modelSummaries"`
+	}
+	if err := decodeResponse(resp, &modelsResp); err != nil {
+		return nil, err
+	}
+
+	var modelList []models.Model
+	for _, m := range modelsResp.ModelSummaries {
+		if bedrockFamily(m.ModelID) == "" {
+			continue
+		}
+		modelList = append(modelList, models.Model{
+			Name:     m.ModelID,
+			ModelID:  m.ModelID,
+			IsActive: true,
+		})
+	}
+	return modelList, nil
+}
+
+// buildInvokePayload builds the model-specific InvokeModel request body
+func (p *BedrockProvider) buildInvokePayload(modelID string, messages []models.Message, opts map[string]interface{}) (map[string]interface{}, error) {
+	switch bedrockFamily(modelID) {
+	case bedrockFamilyClaude:
+		payload := (&AnthropicProvider{}).buildChatPayload(modelID, messages, opts, false)
+		delete(payload, "model")
+		payload["anthropic_version"] = bedrockAnthropicVersion
+		return payload, nil
+	case bedrockFamilyTitanText:
+		return buildTitanPayload(messages, opts), nil
+	default:
+		return nil, fmt.Errorf("bedrock model %s is not supported for chat", modelID)
+	}
+}
+
+// titanOptionFields maps recognized sampling options to Titan textGenerationConfig fields
+var titanOptionFields = map[string]string{
+	"temperature": "temperature",
+	"top_p":       "topP",
+	"max_tokens":  "maxTokenCount",
+}
+
+// buildTitanPayload flattens the conversation into the single prompt Titan text models accept
+func buildTitanPayload(messages []models.Message, opts map[string]interface{}) map[string]interface{} {
+	var prompt strings.Builder
+	if wantsJSONInstruction(opts) {
+		prompt.WriteString(jsonModeInstruction + "\n\n")
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			prompt.WriteString(msg.Content + "\n\n")
+		case "assistant":
+			prompt.WriteString("Bot: " + msg.Content + "\n")
+		default:
+			prompt.WriteString("User: " + msg.Content + "\n")
+		}
+	}
+	prompt.WriteString("Bot:")
+
+	config := map[string]interface{}{}
+	applyOptions(config, opts, titanOptionFields)
+	if stop, ok := opts["stop"]; ok {
+		if sequences := stopSequences(stop); len(sequences) > 0 {
+			config["stopSequences"] = sequences
+		}
+	}
+
+	return map[string]interface{}{
+		"inputText":            prompt.String(),
+		"textGenerationConfig": config,
+	}
+}
+
+// Chat invokes a Bedrock model and returns its response
+func (p *BedrockProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	payload, err := p.buildInvokePayload(modelID, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, p.client, "POST", p.RuntimeHost, modelPath(modelID, "invoke"), body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if bedrockFamily(modelID) == bedrockFamilyClaude {
+		return parseAnthropicResponse(resp)
+	}
+	return parseTitanResponse(resp)
+}
+
+// parseTitanResponse converts a Titan text response body into a ChatResult
+func parseTitanResponse(resp *http.Response) (*ChatResult, error) {
+	var titanResp struct {
+		InputTextTokenCount int `json:"inputTextTokenCount"`
+		Results             []struct {
+			TokenCount       int    `json:"tokenCount"`
+			OutputText       string `json:"outputText"`
+			CompletionReason string `json:"completionReason"`
+		} `json:"results"`
+	}
+	raw, err := readResponse(resp, &titanResp)
+	if err != nil {
+		return nil, err
+	}
+	if len(titanResp.Results) == 0 {
+		return checkResult(resp, raw, nil)
+	}
+
+	result := titanResp.Results[0]
+	return checkResult(resp, raw, &ChatResult{
+		Content:      strings.TrimSpace(result.OutputText),
+		FinishReason: titanFinishReason(result.CompletionReason),
+		Usage: &models.Usage{
+			PromptTokens:     titanResp.InputTextTokenCount,
+			CompletionTokens: result.TokenCount,
+			TotalTokens:      titanResp.InputTextTokenCount + result.TokenCount,
+		},
+	})
+}
+
+// titanFinishReason maps a Titan completionReason to the OpenAI finish_reason vocabulary
+func titanFinishReason(reason string) string {
+	switch reason {
+	case "LENGTH":
+		return "length"
+	case "CONTENT_FILTERED":
+		return "content_filter"
+	case "":
+		return ""
+	default:
+		return "stop"
+	}
+}
+
+// ChatStream invokes a Bedrock model with a response stream and invokes onChunk for every text delta
+func (p *BedrockProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	payload, err := p.buildInvokePayload(modelID, messages, opts)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(ctx, p.streamClient, "POST", p.RuntimeHost, modelPath(modelID, "invoke-with-response-stream"), body, "application/vnd.amazon.eventstream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkContentType(resp, "application/vnd.amazon.eventstream", "application/octet-stream"); err != nil {
+		return err
+	}
+
+	family := bedrockFamily(modelID)
+	claudeStream := &anthropicStream{}
+	return readEventStream(resp.Body, func(headers map[string]string, data []byte) error {
+		if headers[":message-type"] == "exception" {
+			return fmt.Errorf("bedrock stream error: %s: %s", headers[":exception-type"], data)
+		}
+		if headers[":event-type"] != "chunk" {
+			return nil
+		}
+
+		var chunk struct {
+			Bytes []byte `json:"bytes"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+
+		if family == bedrockFamilyClaude {
+			return claudeStream.handle(chunk.Bytes, onChunk)
+		}
+
+		var titanChunk struct {
+			OutputText string `json:"outputText"`
+		}
+		if err := json.Unmarshal(chunk.Bytes, &titanChunk); err != nil {
+			return err
+		}
+		if titanChunk.OutputText == "" {
+			return nil
+		}
+		return onChunk(StreamChunk{Content: titanChunk.OutputText})
+	})
+}
+
+// Generate completes a prompt by invoking the model with a single user message
+func (p *BedrockProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	return generateWithChat(ctx, p, modelID, prompt, opts)
+}
+
+// Embeddings requests an embedding vector from a Titan embedding model
+func (p *BedrockProvider) Embeddings(ctx context.Context, modelID string, input string) ([]float64, error) {
+	if bedrockFamily(modelID) != bedrockFamilyTitanEmbed {
+		return nil, ErrEmbeddingsUnsupported
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"inputText": input})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, p.client, "POST", p.RuntimeHost, modelPath(modelID, "invoke"), body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embeddingResp struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := decodeResponse(resp, &embeddingResp); err != nil {
+		return nil, err
+	}
+	if len(embeddingResp.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding found")
+	}
+	return embeddingResp.Embedding, nil
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// TestSignV4 checks the signer against the get-vanilla case of the AWS SigV4 test suite
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Unexpected Authorization header:\n got  %s\n want %s", got, want)
+	}
+}
+
+// encodeEventStreamMessage builds an event stream message with string headers
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var headerBytes bytes.Buffer
+	for name, value := range headers {
+		headerBytes.WriteByte(byte(len(name)))
+		headerBytes.WriteString(name)
+		headerBytes.WriteByte(7)
+		binary.Write(&headerBytes, binary.BigEndian, uint16(len(value)))
+		headerBytes.WriteString(value)
+	}
+
+	total := uint32(16 + headerBytes.Len() + len(payload))
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, total)
+	binary.Write(&msg, binary.BigEndian, uint32(headerBytes.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(headerBytes.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+// bedrockChunk wraps a model event as a Bedrock chunk message
+func bedrockChunk(event string) []byte {
+	payload, _ := json.Marshal(map[string][]byte{"bytes": []byte(event)})
+	return encodeEventStreamMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+	}, payload)
+}
+
+func newTestBedrockProvider(host string) *BedrockProvider {
+	p := NewBedrockProvider("us-west-2", host)
+	p.ControlHost = host
+	p.credentials = func() (awsCredentials, error) {
+		return awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}
+	return p
+}
+
+func TestBedrockProvider_ChatClaude(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hello from Bedrock"}],"stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	p := newTestBedrockProvider(server.URL)
+	result, err := p.Chat(context.Background(), "anthropic.claude-3-haiku-20240307-v1:0", []models.Message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "Hi"},
+	}, map[string]interface{}{"max_tokens": 50})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if result.Content != "Hello from Bedrock" || result.Usage.TotalTokens != 8 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if gotPath != "/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke" {
+		t.Errorf("Unexpected path %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(gotAuth, "/us-west-2/bedrock/aws4_request") {
+		t.Errorf("Expected a SigV4 Authorization header, got %q", gotAuth)
+	}
+	if gotBody["anthropic_version"] != bedrockAnthropicVersion || gotBody["system"] != "Be brief" {
+		t.Errorf("Unexpected Claude payload: %v", gotBody)
+	}
+	if _, ok := gotBody["model"]; ok {
+		t.Errorf("Expected model to be omitted from the payload, got %v", gotBody)
+	}
+}
+
+func TestBedrockProvider_ChatTitan(t *testing.T) {
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"inputTextTokenCount":4,"results":[{"tokenCount":2,"outputText":" Hi there","completionReason":"FINISH"}]}`))
+	}))
+	defer server.Close()
+
+	p := newTestBedrockProvider(server.URL)
+	result, err := p.Chat(context.Background(), "amazon.titan-text-express-v1", []models.Message{{Role: "user", Content: "Hello"}}, map[string]interface{}{"top_p": 0.9})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if result.Content != "Hi there" || result.FinishReason != "stop" || result.Usage.TotalTokens != 6 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if gotBody["inputText"] != "User: Hello\nBot:" {
+		t.Errorf("Unexpected Titan prompt: %v", gotBody["inputText"])
+	}
+	if config, _ := gotBody["textGenerationConfig"].(map[string]interface{}); config["topP"] != 0.9 {
+		t.Errorf("Expected topP in textGenerationConfig, got %v", gotBody["textGenerationConfig"])
+	}
+}
+
+func TestBedrockProvider_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/invoke-with-response-stream") {
+			t.Errorf("Unexpected path %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(bedrockChunk(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"Hel"}}`))
+		w.Write(bedrockChunk(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"lo"}}`))
+		w.Write(bedrockChunk(`{"type":"message_stop"}`))
+	}))
+	defer server.Close()
+
+	var content strings.Builder
+	p := newTestBedrockProvider(server.URL)
+	err := p.ChatStream(context.Background(), "anthropic.claude-3-haiku-20240307-v1:0", []models.Message{{Role: "user", Content: "Hi"}}, nil, func(chunk StreamChunk) error {
+		content.WriteString(chunk.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if content.String() != "Hello" {
+		t.Errorf("Expected streamed content Hello, got %q", content.String())
+	}
+}
+
+func TestReadEventStreamRejectsCorruptMessages(t *testing.T) {
+	msg := bedrockChunk(`{"type":"message_stop"}`)
+	msg[len(msg)-1] ^= 0xff
+
+	err := readEventStream(bytes.NewReader(msg), func(map[string]string, []byte) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+	if err := readEventStream(io.LimitReader(bytes.NewReader(nil), 0), nil); err != nil {
+		t.Errorf("Expected an empty stream to be accepted, got %v", err)
+	}
+}
+
+func TestBedrockProvider_GetModelsFiltersUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/foundation-models" {
+			t.Errorf("Unexpected path %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"modelSummaries":[{"modelId":"anthropic.claude-3-haiku-20240307-v1:0"},{"modelId":"amazon.titan-embed-text-v2:0"},{"modelId":"stability.sd3-large-v1:0"}]}`))
+	}))
+	defer server.Close()
+
+	modelList, err := newTestBedrockProvider(server.URL).GetModels(context.Background())
+	if err != nil {
+		t.Fatalf("GetModels failed: %v", err)
+	}
+	if len(modelList) != 2 {
+		t.Errorf("Expected the Claude and Titan models only, got %+v", modelList)
+	}
+}
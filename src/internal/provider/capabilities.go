@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// visionModelPrefixes lists model ID prefixes known to accept image inputs, per provider
+var visionModelPrefixes = map[string][]string{
+	"openai":    {"gpt-4o", "gpt-4-turbo", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"},
+	"azure":     {"gpt-4o", "gpt-4-turbo", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"},
+	"anthropic": {"claude-3", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4"},
+	"mistral":   {"pixtral"},
+	"bedrock":   {"anthropic.claude-3", "anthropic.claude-sonnet-4", "anthropic.claude-opus-4"},
+	"ollama":    {"llava", "bakllava", "llama3.2-vision", "llama4", "moondream", "minicpm-v", "gemma3", "qwen2.5vl", "granite3.2-vision"},
+}
+
+// SupportsVision reports whether the given provider's model advertises the vision capability
+func SupportsVision(providerName string, modelID string) bool {
+	for _, prefix := range visionModelPrefixes[providerName] {
+		if strings.HasPrefix(modelID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capability names reported by /api/show, as used by Ollama
+const (
+	CapabilityCompletion = "completion"
+	CapabilityTools      = "tools"
+	CapabilityVision     = "vision"
+	CapabilityThinking   = "thinking"
+	CapabilityEmbedding  = "embedding"
+)
+
+// defaultContextLength is reported for models whose context length is not known
+const defaultContextLength = 8192
+
+// knownModel holds the defaults of the models whose ID starts with prefix. Vision support is
+// taken from visionModelPrefixes.
+type knownModel struct {
+	prefix        string
+	family        string
+	contextLength int
+	// capabilities are added to completion, or replace it for embedding models
+	capabilities []string
+}
+
+// openAIModels lists the defaults of OpenAI models, also served by Azure
+var openAIModels = []knownModel{
+	{"gpt-5", "gpt", 400000, []string{CapabilityTools, CapabilityThinking}},
+	{"gpt-4.1", "gpt", 1047576, []string{CapabilityTools}},
+	{"gpt-4o", "gpt", 128000, []string{CapabilityTools}},
+	{"gpt-4-turbo", "gpt", 128000, []string{CapabilityTools}},
+	{"gpt-4", "gpt", 8192, []string{CapabilityTools}},
+	{"gpt-3.5-turbo", "gpt", 16385, []string{CapabilityTools}},
+	{"o1", "gpt", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"o3", "gpt", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"o4", "gpt", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"text-embedding-3", "gpt", 8191, []string{CapabilityEmbedding}},
+	{"text-embedding-ada", "gpt", 8191, []string{CapabilityEmbedding}},
+}
+
+// claudeModels lists the defaults of Claude models by the ID Anthropic uses
+var claudeModels = []knownModel{
+	{"claude-opus-4", "claude", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"claude-sonnet-4", "claude", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"claude-haiku-4", "claude", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"claude-3-7", "claude", 200000, []string{CapabilityTools, CapabilityThinking}},
+	{"claude-3", "claude", 200000, []string{CapabilityTools}},
+}
+
+// knownModels lists model defaults per provider type. The first matching prefix wins, so
+// longer prefixes come first.
+var knownModels = map[string][]knownModel{
+	"openai":    openAIModels,
+	"azure":     openAIModels,
+	"anthropic": claudeModels,
+	"mistral": {
+		{"mistral-large", "mistral", 131072, []string{CapabilityTools}},
+		{"mistral-medium", "mistral", 131072, []string{CapabilityTools}},
+		{"mistral-small", "mistral", 32768, []string{CapabilityTools}},
+		{"open-mistral-nemo", "mistral", 131072, []string{CapabilityTools}},
+		{"pixtral", "mistral", 131072, []string{CapabilityTools}},
+		{"codestral", "mistral", 256000, nil},
+		{"mistral-embed", "mistral", 8192, []string{CapabilityEmbedding}},
+	},
+	"deepseek": {
+		{"deepseek-chat", "deepseek", 65536, []string{CapabilityTools}},
+		{"deepseek-reasoner", "deepseek", 65536, []string{CapabilityThinking}},
+	},
+	"perplexity": {
+		{"sonar-reasoning", "sonar", 128000, []string{CapabilityThinking}},
+		{"sonar-deep-research", "sonar", 128000, []string{CapabilityThinking}},
+		{"sonar-pro", "sonar", 200000, nil},
+		{"sonar", "sonar", 128000, nil},
+	},
+	"bedrock": {
+		{"amazon.titan-embed", "titan", 8192, []string{CapabilityEmbedding}},
+		{"amazon.titan-text", "titan", 8192, nil},
+	},
+}
+
+// ModelMetadataFor returns what is known about a provider's model: the fields set in stored
+// override the defaults for the model ID, and models that are not known get completion
+// support with an assumed context length.
+func ModelMetadataFor(providerType, modelID string, stored *models.ModelMetadata) models.ModelMetadata {
+	metadata := defaultModelMetadata(providerType, modelID)
+	if stored == nil {
+		return metadata
+	}
+	if len(stored.Capabilities) > 0 {
+		metadata.Capabilities = stored.Capabilities
+	}
+	if stored.ContextLength > 0 {
+		metadata.ContextLength = stored.ContextLength
+	}
+	if stored.Family != "" {
+		metadata.Family = stored.Family
+	}
+	if stored.ParameterSize != "" {
+		metadata.ParameterSize = stored.ParameterSize
+	}
+	if stored.QuantizationLevel != "" {
+		metadata.QuantizationLevel = stored.QuantizationLevel
+	}
+	return metadata
+}
+
+// KnownContextLength returns the context length of a provider's model from its stored metadata
+// or the known model defaults. Unlike ModelMetadataFor it returns 0 when neither has one,
+// instead of assuming a length.
+func KnownContextLength(providerType, modelID string, stored *models.ModelMetadata) int {
+	if stored != nil && stored.ContextLength > 0 {
+		return stored.ContextLength
+	}
+	if model, ok := lookupKnownModel(providerType, modelID); ok {
+		return model.contextLength
+	}
+	return 0
+}
+
+// lookupKnownModel returns the defaults of the first known model whose prefix matches modelID
+func lookupKnownModel(providerType, modelID string) (knownModel, bool) {
+	// Bedrock serves Claude under Anthropic's IDs prefixed with the vendor
+	known := knownModels[providerType]
+	if providerType == "bedrock" && strings.HasPrefix(modelID, "anthropic.") {
+		known = claudeModels
+		modelID = strings.TrimPrefix(modelID, "anthropic.")
+	}
+	for _, model := range known {
+		if strings.HasPrefix(modelID, model.prefix) {
+			return model, true
+		}
+	}
+	return knownModel{}, false
+}
+
+// defaultModelMetadata returns the defaults known for a provider's model
+func defaultModelMetadata(providerType, modelID string) models.ModelMetadata {
+	metadata := models.ModelMetadata{
+		Capabilities:  []string{CapabilityCompletion},
+		ContextLength: defaultContextLength,
+		Family:        providerType,
+	}
+
+	if model, ok := lookupKnownModel(providerType, modelID); ok {
+		metadata.Family = model.family
+		metadata.ContextLength = model.contextLength
+		if len(model.capabilities) > 0 && model.capabilities[0] == CapabilityEmbedding {
+			metadata.Capabilities = []string{CapabilityEmbedding}
+		} else {
+			metadata.Capabilities = append(metadata.Capabilities, model.capabilities...)
+		}
+	}
+
+	if SupportsVision(providerType, modelID) {
+		metadata.Capabilities = append(metadata.Capabilities, CapabilityVision)
+	}
+	return metadata
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestModelMetadataFor(t *testing.T) {
+	tests := []struct {
+		name                string
+		providerType, model string
+		stored              *models.ModelMetadata
+		want                models.ModelMetadata
+	}{
+		{"openai chat", "openai", "gpt-4o-mini", nil, models.ModelMetadata{Capabilities: []string{"completion", "tools", "vision"}, ContextLength: 128000, Family: "gpt"}},
+		{"openai embedding", "openai", "text-embedding-3-small", nil, models.ModelMetadata{Capabilities: []string{"embedding"}, ContextLength: 8191, Family: "gpt"}},
+		{"anthropic", "anthropic", "claude-3-5-haiku-latest", nil, models.ModelMetadata{Capabilities: []string{"completion", "tools", "vision"}, ContextLength: 200000, Family: "claude"}},
+		{"deepseek reasoner", "deepseek", "deepseek-reasoner", nil, models.ModelMetadata{Capabilities: []string{"completion", "thinking"}, ContextLength: 65536, Family: "deepseek"}},
+		{"bedrock claude", "bedrock", "anthropic.claude-sonnet-4-20250514-v1:0", nil, models.ModelMetadata{Capabilities: []string{"completion", "tools", "thinking", "vision"}, ContextLength: 200000, Family: "claude"}},
+		{"unknown model", "openai-compatible", "my-model", nil, models.ModelMetadata{Capabilities: []string{"completion"}, ContextLength: 8192, Family: "openai-compatible"}},
+		{"stored override", "openai", "gpt-4o", &models.ModelMetadata{ContextLength: 32000}, models.ModelMetadata{Capabilities: []string{"completion", "tools", "vision"}, ContextLength: 32000, Family: "gpt"}},
+		{"stored capabilities", "openai-compatible", "my-model", &models.ModelMetadata{Capabilities: []string{"completion", "tools"}, Family: "llama"}, models.ModelMetadata{Capabilities: []string{"completion", "tools"}, ContextLength: 8192, Family: "llama"}},
+	}
+
+	for _, tt := range tests {
+		if got := ModelMetadataFor(tt.providerType, tt.model, tt.stored); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestKnownContextLength(t *testing.T) {
+	if got := KnownContextLength("bedrock", "anthropic.claude-3-haiku-20240307-v1:0", nil); got != 200000 {
+		t.Errorf("Expected the known context length, got %d", got)
+	}
+	if got := KnownContextLength("openai-compatible", "my-model", nil); got != 0 {
+		t.Errorf("Expected 0 for an unknown model, got %d", got)
+	}
+	if got := KnownContextLength("openai-compatible", "my-model", &models.ModelMetadata{ContextLength: 4096}); got != 4096 {
+		t.Errorf("Expected the stored context length, got %d", got)
+	}
+}
@@ -0,0 +1,26 @@
+package provider
+
+import "context"
+
+// defaultDeepSeekHost is used when no host is configured
+const defaultDeepSeekHost = "https://api.deepseek.com"
+
+// DeepSeekProvider handles interactions with the DeepSeek API, which uses the OpenAI wire
+// format. Its reasoner models return their chain of thought as reasoning_content, which the
+// OpenAI implementation reports as ChatResult.Thinking.
+type DeepSeekProvider struct {
+	*OpenAIProvider
+}
+
+// NewDeepSeekProvider creates a new instance of DeepSeekProvider
+func NewDeepSeekProvider(apiKey string, host string) *DeepSeekProvider {
+	if host == "" {
+		host = defaultDeepSeekHost
+	}
+	return &DeepSeekProvider{OpenAIProvider: NewOpenAIProvider(apiKey, host)}
+}
+
+// Ping checks that the provider is reachable by listing its models
+func (p *DeepSeekProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestDeepSeekProvider_ChatCapturesReasoning(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"c1","object":"chat.completion","model":"deepseek-reasoner","choices":[{"index":0,"message":{"role":"assistant","content":"9.11 is smaller.","reasoning_content":"Compare the tenths: 1 < 9."},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":20,"total_tokens":32}}`))
+	}))
+	defer server.Close()
+
+	p := CreateProvider(&models.Provider{Name: "deepseek", APIKey: "ds-key", Host: server.URL})
+	result, err := p.Chat(context.Background(), "deepseek-reasoner", []models.Message{{Role: "user", Content: "9.11 or 9.9?"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if gotPath != "/v1/chat/completions" || gotAuth != "Bearer ds-key" {
+		t.Errorf("Unexpected upstream request: path %q, auth %q", gotPath, gotAuth)
+	}
+	if result.Content != "9.11 is smaller." {
+		t.Errorf("Unexpected content %q", result.Content)
+	}
+	if result.Thinking != "Compare the tenths: 1 < 9." {
+		t.Errorf("Expected reasoning content to be captured, got %q", result.Thinking)
+	}
+
+	body, err := NewOllamaResponseTransformer().TransformChatResponse(result, "deepseek-reasoner")
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	var ollamaResp struct {
+		Message struct {
+			Content  string `json:"content"`
+			Thinking string `json:"thinking"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if ollamaResp.Message.Thinking != result.Thinking || ollamaResp.Message.Content != result.Content {
+		t.Errorf("Expected thinking alongside content, got %s", body)
+	}
+}
+
+func TestDeepSeekProviderDefaultHost(t *testing.T) {
+	if host := NewDeepSeekProvider("key", "").Host; host != "https://api.deepseek.com" {
+		t.Errorf("Expected default host, got %q", host)
+	}
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxUpstreamErrorBytes caps how much of an upstream error body is kept in the error message
+const maxUpstreamErrorBytes = 1024
+
+// maxDecodeSnippetBytes caps how much of an undecodable response body is kept in the error message
+const maxDecodeSnippetBytes = 256
+
+// UpstreamError is returned when a provider answers with a non-success status code
+type UpstreamError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d: %s", e.StatusCode, e.Message)
+}
+
+// newUpstreamError builds an UpstreamError from a failed response, keeping the start of its body
+func newUpstreamError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamErrorBytes))
+	return &UpstreamError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+	}
+}
+
+// DecodeError is returned when a provider answers with a body that is not the expected JSON,
+// such as an HTML error page from a proxy in front of a misconfigured host
+type DecodeError struct {
+	ContentType string
+	Snippet     string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("invalid response from provider (content-type %q): %v: %q", e.ContentType, e.Err, e.Snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// EmptyResponseError is returned when a provider answers successfully but without any content,
+// reasoning or tool calls. FinishReason is the finish reason in the OpenAI vocabulary, which
+// is content_filter when the provider's safety system withheld the answer.
+type EmptyResponseError struct {
+	FinishReason string
+	Snippet      string
+}
+
+func (e *EmptyResponseError) Error() string {
+	if e.ContentFiltered() {
+		return "provider withheld the response: content filter triggered"
+	}
+	if e.FinishReason != "" {
+		return fmt.Sprintf("provider returned an empty response (finish reason %q)", e.FinishReason)
+	}
+	return "provider returned an empty response"
+}
+
+// ContentFiltered reports whether the response was withheld by the provider's content filter
+func (e *EmptyResponseError) ContentFiltered() bool {
+	return e.FinishReason == "content_filter"
+}
+
+// checkResult returns result unless it has no content, reasoning or tool calls, in which case
+// it returns an EmptyResponseError for the raw response body, and logs it. A nil result is
+// empty too, for responses without any choice.
+func checkResult(resp *http.Response, body []byte, result *ChatResult) (*ChatResult, error) {
+	if result != nil && (result.Content != "" || result.Thinking != "" || len(result.ToolCalls) > 0) {
+		return result, nil
+	}
+	emptyErr := &EmptyResponseError{Snippet: responseSnippet(body)}
+	if result != nil {
+		emptyErr.FinishReason = result.FinishReason
+	}
+	if resp.Request != nil {
+		log.Printf("Empty response from %s: %v: %q", resp.Request.URL.Redacted(), emptyErr, emptyErr.Snippet)
+	} else {
+		log.Printf("Empty provider response: %v: %q", emptyErr, emptyErr.Snippet)
+	}
+	return nil, emptyErr
+}
+
+// jsonMediaTypes are the content types of JSON responses. text/plain is accepted too, as some
+// servers label JSON bodies with it.
+var jsonMediaTypes = []string{"application/json", "text/plain"}
+
+// decodeResponse decodes a JSON response body into v. When the content type is not JSON or
+// decoding fails, the error carries the content type and the start of the raw body, and is
+// logged.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	_, err := readResponse(resp, v)
+	return err
+}
+
+// readResponse decodes a JSON response body into v like decodeResponse, and returns the raw
+// body for checkResult
+func readResponse(resp *http.Response, v interface{}) ([]byte, error) {
+	if err := checkContentType(resp, jsonMediaTypes...); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, newDecodeError(resp, body, err)
+	}
+	return body, nil
+}
+
+// checkContentType returns a DecodeError when a response declares a content type other than
+// the accepted media types or a +json type, so that e.g. an HTML error page from a gateway is
+// never handed to a JSON or stream decoder. Responses without a content type are accepted.
+func checkContentType(resp *http.Response, accepted ...string) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if strings.HasSuffix(mediaType, "+json") {
+			return nil
+		}
+		for _, t := range accepted {
+			if mediaType == t {
+				return nil
+			}
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxDecodeSnippetBytes+1))
+	return newDecodeError(resp, body, fmt.Errorf("unexpected content type, expected %s", strings.Join(accepted, " or ")))
+}
+
+// newDecodeError builds and logs a DecodeError for a response with an unexpected body
+func newDecodeError(resp *http.Response, body []byte, err error) error {
+	decodeErr := &DecodeError{ContentType: resp.Header.Get("Content-Type"), Snippet: responseSnippet(body), Err: err}
+	if resp.Request != nil {
+		log.Printf("Failed to decode response from %s: %v", resp.Request.URL.Redacted(), decodeErr)
+	} else {
+		log.Printf("Failed to decode provider response: %v", decodeErr)
+	}
+	return decodeErr
+}
+
+// responseSnippet returns the start of a response body for error messages and logs
+func responseSnippet(body []byte) string {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > maxDecodeSnippetBytes {
+		snippet = snippet[:maxDecodeSnippetBytes] + "..."
+	}
+	return snippet
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestNonJSONResponsesReportContentTypeAndSnippet(t *testing.T) {
+	page := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("x", 500) + "</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	providers := map[string]ProviderInterface{
+		"openai":    NewOpenAIProvider("key", server.URL),
+		"anthropic": NewAnthropicProvider("key", server.URL),
+		"ollama":    NewOllamaProvider(server.URL),
+	}
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+
+	for name, p := range providers {
+		_, chatErr := p.Chat(context.Background(), "model", messages, nil)
+		_, modelsErr := p.GetModels(context.Background())
+		for call, err := range map[string]error{"Chat": chatErr, "GetModels": modelsErr} {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("%s %s: expected a DecodeError, got %v", name, call, err)
+				continue
+			}
+			if decodeErr.ContentType != "text/html; charset=utf-8" {
+				t.Errorf("%s %s: expected the content type, got %q", name, call, decodeErr.ContentType)
+			}
+			if !strings.HasPrefix(decodeErr.Snippet, "<html><head><title>502 Bad Gateway") || len(decodeErr.Snippet) > maxDecodeSnippetBytes+3 {
+				t.Errorf("%s %s: expected a truncated snippet, got %q", name, call, decodeErr.Snippet)
+			}
+			if !strings.Contains(err.Error(), "502 Bad Gateway") {
+				t.Errorf("%s %s: expected the snippet in the message, got %q", name, call, err.Error())
+			}
+		}
+	}
+}
+
+func TestStreamsRejectHTMLResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Sign in to continue</body></html>"))
+	}))
+	defer server.Close()
+
+	providers := map[string]ProviderInterface{
+		"openai":    NewOpenAIProvider("key", server.URL),
+		"anthropic": NewAnthropicProvider("key", server.URL),
+		"ollama":    NewOllamaProvider(server.URL),
+	}
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+
+	for name, p := range providers {
+		chunks := 0
+		err := p.ChatStream(context.Background(), "model", messages, nil, func(StreamChunk) error {
+			chunks++
+			return nil
+		})
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.ContentType != "text/html" {
+			t.Errorf("%s: expected a DecodeError with the content type, got %v", name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "Sign in to continue") || chunks != 0 {
+			t.Errorf("%s: expected the snippet and no chunks, got %q after %d chunks", name, err.Error(), chunks)
+		}
+	}
+}
+
+func TestEmptyResponsesReturnEmptyResponseError(t *testing.T) {
+	tests := []struct {
+		name     string
+		newP     func(host string) ProviderInterface
+		model    string
+		body     string
+		filtered bool
+	}{
+		{"openai no choices", func(h string) ProviderInterface { return NewOpenAIProvider("key", h) }, "gpt-4o", `{"choices":[]}`, false},
+		{"openai empty message", func(h string) ProviderInterface { return NewOpenAIProvider("key", h) }, "gpt-4o", `{"choices":[{"message":{"content":""},"finish_reason":"stop"}]}`, false},
+		{"openai content filter", func(h string) ProviderInterface { return NewOpenAIProvider("key", h) }, "gpt-4o", `{"choices":[{"message":{"content":null},"finish_reason":"content_filter"}]}`, true},
+		{"anthropic no blocks", func(h string) ProviderInterface { return NewAnthropicProvider("key", h) }, "claude-3-5-sonnet", `{"content":[],"stop_reason":"end_turn"}`, false},
+		{"anthropic empty text", func(h string) ProviderInterface { return NewAnthropicProvider("key", h) }, "claude-3-5-sonnet", `{"content":[{"type":"text","text":""}],"stop_reason":"end_turn"}`, false},
+		{"anthropic refusal", func(h string) ProviderInterface { return NewAnthropicProvider("key", h) }, "claude-3-5-sonnet", `{"content":[],"stop_reason":"refusal"}`, true},
+		{"ollama empty message", func(h string) ProviderInterface { return NewOllamaProvider(h) }, "llama3", `{"message":{"role":"assistant","content":""},"done_reason":"stop","done":true}`, false},
+		{"bedrock titan no results", func(h string) ProviderInterface { return newTestBedrockProvider(h) }, "amazon.titan-text-express-v1", `{"inputTextTokenCount":4,"results":[]}`, false},
+		{"bedrock titan content filtered", func(h string) ProviderInterface { return newTestBedrockProvider(h) }, "amazon.titan-text-express-v1", `{"inputTextTokenCount":4,"results":[{"tokenCount":0,"outputText":"","completionReason":"CONTENT_FILTERED"}]}`, true},
+		{"bedrock claude empty", func(h string) ProviderInterface { return newTestBedrockProvider(h) }, "anthropic.claude-3-haiku-20240307-v1:0", `{"content":[],"stop_reason":"end_turn"}`, false},
+	}
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(tt.body))
+		}))
+
+		result, err := tt.newP(server.URL).Chat(context.Background(), tt.model, messages, nil)
+		server.Close()
+
+		var emptyErr *EmptyResponseError
+		if !errors.As(err, &emptyErr) {
+			t.Errorf("%s: expected an EmptyResponseError, got %+v, %v", tt.name, result, err)
+			continue
+		}
+		if emptyErr.ContentFiltered() != tt.filtered {
+			t.Errorf("%s: expected filtered %v, got finish reason %q", tt.name, tt.filtered, emptyErr.FinishReason)
+		}
+		if emptyErr.Snippet != tt.body {
+			t.Errorf("%s: expected the raw body as snippet, got %q", tt.name, emptyErr.Snippet)
+		}
+	}
+}
+
+func TestResponsesWithOnlyToolCallsOrReasoningAreNotEmpty(t *testing.T) {
+	bodies := map[string]string{
+		"tool calls": `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+		"reasoning":  `{"choices":[{"message":{"content":"","reasoning_content":"Thinking it over"},"finish_reason":"length"}]}`,
+	}
+	for name, body := range bodies {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+
+		_, err := NewOpenAIProvider("key", server.URL).Chat(context.Background(), "gpt-4o", []models.Message{{Role: "user", Content: "Hi"}}, nil)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: expected a result, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// maxEventStreamMessage bounds a single AWS event stream message
+const maxEventStreamMessage = 16 * 1024 * 1024
+
+// readEventStream decodes an AWS event stream (application/vnd.amazon.eventstream)
+// and invokes handle with the string headers and payload of every message
+func readEventStream(r io.Reader, handle func(headers map[string]string, payload []byte) error) error {
+	prelude := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(r, prelude); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		totalLength := binary.BigEndian.Uint32(prelude[0:4])
+		headersLength := binary.BigEndian.Uint32(prelude[4:8])
+		if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+			return errors.New("event stream prelude checksum mismatch")
+		}
+		if totalLength < 16 || totalLength > maxEventStreamMessage || headersLength > totalLength-16 {
+			return fmt.Errorf("invalid event stream message length %d", totalLength)
+		}
+
+		message := make([]byte, totalLength)
+		copy(message, prelude)
+		if _, err := io.ReadFull(r, message[12:]); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(message[:totalLength-4]) != binary.BigEndian.Uint32(message[totalLength-4:]) {
+			return errors.New("event stream message checksum mismatch")
+		}
+
+		headers, err := parseEventStreamHeaders(message[12 : 12+headersLength])
+		if err != nil {
+			return err
+		}
+		if err := handle(headers, message[12+headersLength:totalLength-4]); err != nil {
+			if err == errStreamDone {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// parseEventStreamHeaders decodes message headers, keeping only string-typed values
+func parseEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 1+nameLength+1 {
+			return nil, errors.New("truncated event stream header")
+		}
+		name := string(data[1 : 1+nameLength])
+		valueType := data[1+nameLength]
+		data = data[2+nameLength:]
+
+		var size int
+		switch valueType {
+		case 0, 1: // bool true / false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // int16
+			size = 2
+		case 4: // int32
+			size = 4
+		case 5, 8: // int64, timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // bytes, string
+			if len(data) < 2 {
+				return nil, errors.New("truncated event stream header")
+			}
+			size = 2 + int(binary.BigEndian.Uint16(data[:2]))
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", valueType)
+		}
+		if len(data) < size {
+			return nil, errors.New("truncated event stream header")
+		}
+		if valueType == 7 {
+			headers[name] = string(data[2:size])
+		}
+		data = data[size:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,13 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// generateWithChat serves a completion from a chat-only API by sending the prompt as a
+// single user message. Chat APIs have no fill-in-the-middle mode, so suffix is ignored.
+func generateWithChat(ctx context.Context, p ProviderInterface, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	return p.Chat(ctx, modelID, []models.Message{{Role: "user", Content: prompt}}, opts)
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIProvider_GenerateUsesCompletionsForInstructModels(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"text":" return a + b","finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":4,"total_tokens":9}}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider("key", server.URL)
+	result, err := p.Generate(context.Background(), "gpt-3.5-turbo-instruct", "def add(a, b):", map[string]interface{}{"suffix": "\n\nprint(add(1, 2))", "max_tokens": float64(16)})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if gotPath != "/v1/completions" {
+		t.Errorf("Expected the completions endpoint, got %q", gotPath)
+	}
+	if gotBody["prompt"] != "def add(a, b):" || gotBody["suffix"] != "\n\nprint(add(1, 2))" || gotBody["max_tokens"] != float64(16) {
+		t.Errorf("Expected prompt, suffix and max_tokens to be sent, got %v", gotBody)
+	}
+	if result.Content != " return a + b" || result.FinishReason != "stop" || result.Usage == nil || result.Usage.TotalTokens != 9 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestOpenAIProvider_GenerateFallsBackToChat(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider("key", server.URL)
+	result, err := p.Generate(context.Background(), "gpt-4o", "Say hi", map[string]interface{}{"suffix": "ignored"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("Expected chat models to use chat completions, got %q", gotPath)
+	}
+	messages, _ := gotBody["messages"].([]interface{})
+	if len(messages) != 1 || messages[0].(map[string]interface{})["content"] != "Say hi" {
+		t.Errorf("Expected the prompt as a single user message, got %v", gotBody["messages"])
+	}
+	if _, ok := gotBody["suffix"]; ok {
+		t.Error("Expected suffix not to be sent to the chat endpoint")
+	}
+	if result.Content != "Hi" {
+		t.Errorf("Expected chat content, got %q", result.Content)
+	}
+}
+
+func TestOpenAICompatibleProvider_GenerateUsesCompletions(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"text":"done","finish_reason":"length"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatibleProvider(server.URL+"/v1", "", "")
+	result, err := p.Generate(context.Background(), "qwen2.5-coder", "fn main() {", nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if gotPath != "/v1/completions" {
+		t.Errorf("Expected compatible backends to use completions for any model, got %q", gotPath)
+	}
+	if result.Content != "done" || result.FinishReason != "length" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestOllamaProvider_Generate(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response":"middle","done":true,"done_reason":"stop","prompt_eval_count":3,"eval_count":2}`))
+	}))
+	defer server.Close()
+
+	p := NewOllamaProvider(server.URL)
+	result, err := p.Generate(context.Background(), "codellama:code", "start", map[string]interface{}{"suffix": "end", "max_tokens": float64(8)})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if gotPath != "/api/generate" || gotBody["suffix"] != "end" || gotBody["stream"] != false {
+		t.Errorf("Expected a non-streaming generate request with suffix, got %s %v", gotPath, gotBody)
+	}
+	if options, _ := gotBody["options"].(map[string]interface{}); options["num_predict"] != float64(8) {
+		t.Errorf("Expected max_tokens as num_predict, got %v", gotBody["options"])
+	}
+	if result.Content != "middle" || result.Usage.TotalTokens != 5 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// headersSetter is implemented by providers that send custom headers upstream
+type headersSetter interface {
+	SetHeaders(headers map[string]string)
+}
+
+// WithHeaders adds the provider's custom headers to all of its upstream requests when it supports them
+func WithHeaders(p ProviderInterface, headers map[string]string) ProviderInterface {
+	if setter, ok := p.(headersSetter); ok && len(headers) > 0 {
+		setter.SetHeaders(headers)
+	}
+	return p
+}
+
+// setCustomHeaders adds custom headers to an upstream request. They are applied last, so they
+// override the client's forwarded headers and the provider's own defaults.
+func setCustomHeaders(req *http.Request, headers map[string]string) {
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// headersFromEnv reads {PREFIX}_HEADERS, a "Name=value,Name=value" list, for the provider
+// enabled by enableEnvVar (IS_{PREFIX}_ACTIVE)
+func headersFromEnv(enableEnvVar string) map[string]string {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(prefix+"_HEADERS"), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			continue
+		}
+		headers[name] = value
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
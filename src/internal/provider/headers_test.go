@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestCustomHeadersAreSentUpstream(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	prov := &models.Provider{
+		Name:    "openrouter",
+		Type:    "openai",
+		APIKey:  "key",
+		Host:    server.URL,
+		Headers: map[string]string{"HTTP-Referer": "https://allama.example", "X-Title": "Allama"},
+	}
+	p := CreateProvider(prov)
+	if _, err := p.Chat(context.Background(), "model", []models.Message{{Role: "user", Content: "Hi"}}, nil); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	for name, value := range prov.Headers {
+		if got.Get(name) != value {
+			t.Errorf("Expected header %s %q, got %q", name, value, got.Get(name))
+		}
+	}
+	if got.Get("Authorization") != "Bearer key" {
+		t.Errorf("Expected the provider's own auth header to be kept, got %q", got.Get("Authorization"))
+	}
+}
+
+func TestForwardRequestCustomHeadersOverrideClientHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	p := NewOllamaProvider(server.URL)
+	p.SetHeaders(map[string]string{"X-Proxy-Auth": "secret"})
+	clientHeaders := map[string]string{"X-Proxy-Auth": "from-client", "X-Client": "cli"}
+	if _, _, err := p.ForwardRequest(context.Background(), http.MethodGet, "/api/tags", nil, clientHeaders); err != nil {
+		t.Fatalf("ForwardRequest failed: %v", err)
+	}
+
+	if got.Get("X-Proxy-Auth") != "secret" {
+		t.Errorf("Expected custom header to override the client's, got %q", got.Get("X-Proxy-Auth"))
+	}
+	if got.Get("X-Client") != "cli" {
+		t.Errorf("Expected client header to be forwarded, got %q", got.Get("X-Client"))
+	}
+}
+
+func TestHeadersFromEnv(t *testing.T) {
+	t.Setenv("OPENROUTER_HEADERS", "HTTP-Referer=https://allama.example, X-Title = Allama,invalid")
+
+	headers := headersFromEnv("IS_OPENROUTER_ACTIVE")
+	if len(headers) != 2 || headers["HTTP-Referer"] != "https://allama.example" || headers["X-Title"] != "Allama" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+	if headers := headersFromEnv("IS_UNSET_ACTIVE"); headers != nil {
+		t.Errorf("Expected no headers when unset, got %v", headers)
+	}
+}
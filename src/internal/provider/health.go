@@ -0,0 +1,23 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// pingTimeout bounds how long a health probe may wait for a provider
+const pingTimeout = 5 * time.Second
+
+// pingByListingModels is the default Ping: a provider is healthy when it can list its models in time
+func pingByListingModels(ctx context.Context, p ProviderInterface) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	_, err := p.GetModels(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("ping timed out after %s", pingTimeout)
+	}
+	return err
+}
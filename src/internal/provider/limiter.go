@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// ErrProviderBusy is returned when a request waited too long for a free slot at a provider
+var ErrProviderBusy = errors.New("provider is at its concurrency limit")
+
+// Limiter caps the number of concurrent upstream requests to a provider. Requests beyond
+// the cap queue for at most the limiter's wait before giving up with ErrProviderBusy.
+type Limiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// NewLimiter creates a limiter allowing max concurrent requests
+func NewLimiter(max int, wait time.Duration) *Limiter {
+	return &Limiter{slots: make(chan struct{}, max), wait: wait}
+}
+
+// Acquire waits for a free slot and returns the function that releases it
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-timer.C:
+		return nil, ErrProviderBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// Limiters holds the limiter of every provider with a concurrency cap, keyed by provider name
+type Limiters map[string]*Limiter
+
+// NewLimiters creates limiters for the configured providers that set a concurrency cap
+func NewLimiters(configs []ProviderConfig, wait time.Duration) Limiters {
+	limiters := make(Limiters)
+	for _, config := range configs {
+		if config.MaxConcurrency > 0 {
+			limiters[config.Name] = NewLimiter(config.MaxConcurrency, wait)
+		}
+	}
+	return limiters
+}
+
+// For returns the limiter of the named provider, or nil when it has no cap
+func (l Limiters) For(name string) *Limiter {
+	return l[name]
+}
+
+// maxConcurrencyFromEnv reads {PREFIX}_MAX_CONCURRENCY for the provider enabled by
+// enableEnvVar, e.g. IS_OPENAI_2_ACTIVE reads OPENAI_2_MAX_CONCURRENCY
+func maxConcurrencyFromEnv(enableEnvVar string) int {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	n, err := strconv.Atoi(os.Getenv(prefix + "_MAX_CONCURRENCY"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// WithLimiter makes the provider hold a slot of the limiter for the duration of every
+// Chat, ChatStream and Embeddings call. A nil limiter leaves the provider unlimited.
+func WithLimiter(p ProviderInterface, limiter *Limiter) ProviderInterface {
+	if limiter == nil {
+		return p
+	}
+	return &limitedProvider{ProviderInterface: p, limiter: limiter}
+}
+
+// limitedProvider wraps a provider with a concurrency limiter
+type limitedProvider struct {
+	ProviderInterface
+	limiter *Limiter
+}
+
+func (p *limitedProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	release, err := p.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.ProviderInterface.Chat(ctx, modelID, messages, opts)
+}
+
+func (p *limitedProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	release, err := p.limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return p.ProviderInterface.ChatStream(ctx, modelID, messages, opts, onChunk)
+}
+
+func (p *limitedProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	release, err := p.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.ProviderInterface.Generate(ctx, modelID, prompt, opts)
+}
+
+func (p *limitedProvider) Embeddings(ctx context.Context, modelID string, input string) ([]float64, error) {
+	release, err := p.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.ProviderInterface.Embeddings(ctx, modelID, input)
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// slowProvider records how many Chat calls run at once
+type slowProvider struct {
+	ProviderInterface
+	active, peak atomic.Int32
+}
+
+func (p *slowProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	n := p.active.Add(1)
+	defer p.active.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &ChatResult{Content: "ok"}, nil
+}
+
+func TestLimiterSerializesBeyondCap(t *testing.T) {
+	upstream := &slowProvider{}
+	limited := WithLimiter(upstream, NewLimiter(2, time.Second))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := limited.Chat(context.Background(), "model", nil, nil); err != nil {
+				t.Errorf("Chat failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := upstream.peak.Load(); peak != 2 {
+		t.Errorf("Expected at most 2 concurrent upstream calls, got %d", peak)
+	}
+}
+
+func TestLimiterGivesUpAfterWait(t *testing.T) {
+	limiter := NewLimiter(1, 20*time.Millisecond)
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	if _, err := limiter.Acquire(context.Background()); !errors.Is(err, ErrProviderBusy) {
+		t.Errorf("Expected ErrProviderBusy while the slot is held, got %v", err)
+	}
+
+	release()
+	if _, err := limiter.Acquire(context.Background()); err != nil {
+		t.Errorf("Expected the released slot to be available, got %v", err)
+	}
+}
+
+func TestNewLimitersFromConfig(t *testing.T) {
+	t.Setenv("OPENAI_MAX_CONCURRENCY", "4")
+	t.Setenv("IS_OPENAI_2_ACTIVE", "true")
+	t.Setenv("OPENAI_2_MAX_CONCURRENCY", "1")
+	t.Setenv("ANTHROPIC_MAX_CONCURRENCY", "lots")
+
+	limiters := NewLimiters(GetProviderConfigs(), time.Second)
+
+	if l := limiters.For("openai"); l == nil || cap(l.slots) != 4 {
+		t.Errorf("Expected openai to allow 4 concurrent requests, got %+v", l)
+	}
+	if l := limiters.For("openai-2"); l == nil || cap(l.slots) != 1 {
+		t.Errorf("Expected openai-2 to allow 1 concurrent request, got %+v", l)
+	}
+	if limiters.For("anthropic") != nil || limiters.For("ollama") != nil {
+		t.Errorf("Expected providers without a valid cap to be unlimited")
+	}
+}
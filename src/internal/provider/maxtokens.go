@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// defaultMaxTokensFromEnv reads {PREFIX}_DEFAULT_MAX_TOKENS for the provider enabled by
+// enableEnvVar, e.g. IS_ANTHROPIC_ACTIVE reads ANTHROPIC_DEFAULT_MAX_TOKENS
+func defaultMaxTokensFromEnv(enableEnvVar string) int {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	n, err := strconv.Atoi(os.Getenv(prefix + "_DEFAULT_MAX_TOKENS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// WithDefaultMaxTokens makes the provider send maxTokens as max_tokens on Chat, ChatStream and
+// Generate calls that do not set it. Zero leaves the provider unchanged, so providers where
+// max_tokens is optional only send it when the client does.
+func WithDefaultMaxTokens(p ProviderInterface, maxTokens int) ProviderInterface {
+	if maxTokens <= 0 {
+		return p
+	}
+	return &defaultMaxTokensProvider{ProviderInterface: p, maxTokens: maxTokens}
+}
+
+// defaultMaxTokensProvider wraps a provider with a default max_tokens
+type defaultMaxTokensProvider struct {
+	ProviderInterface
+	maxTokens int
+}
+
+// withDefault returns opts with max_tokens set, copying it rather than changing the caller's map
+func (p *defaultMaxTokensProvider) withDefault(opts map[string]interface{}) map[string]interface{} {
+	if _, ok := opts["max_tokens"]; ok {
+		return opts
+	}
+	withDefault := make(map[string]interface{}, len(opts)+1)
+	for key, value := range opts {
+		withDefault[key] = value
+	}
+	withDefault["max_tokens"] = float64(p.maxTokens)
+	return withDefault
+}
+
+func (p *defaultMaxTokensProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	return p.ProviderInterface.Chat(ctx, modelID, messages, p.withDefault(opts))
+}
+
+func (p *defaultMaxTokensProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	return p.ProviderInterface.ChatStream(ctx, modelID, messages, p.withDefault(opts), onChunk)
+}
+
+func (p *defaultMaxTokensProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	return p.ProviderInterface.Generate(ctx, modelID, prompt, p.withDefault(opts))
+}
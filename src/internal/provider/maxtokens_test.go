@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestAnthropicProvider_UsesConfiguredDefaultMaxTokens(t *testing.T) {
+	var gotMaxTokens []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		gotMaxTokens = append(gotMaxTokens, payload["max_tokens"])
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hello"}]}`))
+	}))
+	defer server.Close()
+
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+	configured := WithDefaultMaxTokens(NewAnthropicProvider("test-key", server.URL), 4096)
+	if _, err := configured.Chat(context.Background(), "claude-3-haiku", messages, nil); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	opts := map[string]interface{}{"max_tokens": float64(200)}
+	if _, err := configured.Chat(context.Background(), "claude-3-haiku", messages, opts); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, err := NewAnthropicProvider("test-key", server.URL).Chat(context.Background(), "claude-3-haiku", messages, nil); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	want := []interface{}{float64(4096), float64(200), float64(anthropicDefaultMaxTokens)}
+	for i, got := range gotMaxTokens {
+		if got != want[i] {
+			t.Errorf("Request %d: expected max_tokens %v, got %v", i, want[i], got)
+		}
+	}
+	if len(opts) != 1 {
+		t.Errorf("Expected the client's options not to be modified, got %v", opts)
+	}
+}
+
+func TestOpenAIProvider_OmitsMaxTokensWithoutDefault(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	p := WithDefaultMaxTokens(NewOpenAIProvider("key", server.URL), 0)
+	if _, err := p.Chat(context.Background(), "gpt-4o", []models.Message{{Role: "user", Content: "Hi"}}, nil); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if _, ok := payload["max_tokens"]; ok {
+		t.Errorf("Expected max_tokens to be omitted when neither the client nor the provider sets it, got %v", payload["max_tokens"])
+	}
+}
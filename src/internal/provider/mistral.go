@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// defaultMistralHost is used when no host is configured
+const defaultMistralHost = "https://api.mistral.ai"
+
+// MistralProvider handles interactions with the Mistral API. Chat and embeddings use
+// the OpenAI wire format; only the model listing differs.
+type MistralProvider struct {
+	*OpenAIProvider
+}
+
+// NewMistralProvider creates a new instance of MistralProvider
+func NewMistralProvider(apiKey string, host string) *MistralProvider {
+	if host == "" {
+		host = defaultMistralHost
+	}
+	return &MistralProvider{OpenAIProvider: NewOpenAIProvider(apiKey, host)}
+}
+
+// Ping checks that the provider is reachable by listing its models
+func (p *MistralProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
+
+// mistralModel is an entry of Mistral's /v1/models listing
+type mistralModel struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+}
+
+// GetModels retrieves the list of available models from Mistral, skipping archived fine-tunes
+func (p *MistralProvider) GetModels(ctx context.Context) ([]models.Model, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url("/models", ""), nil)
+	if err != nil {
+		return nil, err
+	}
+	setRequestIDHeader(req, p.requestID)
+	p.setAuth(req)
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp)
+	}
+
+	var modelsResp struct {
+		Data []mistralModel `json:"data"`
+	}
+	if err := decodeResponse(resp, &modelsResp); err != nil {
+		return nil, err
+	}
+
+	var modelList []models.Model
+	seen := make(map[string]bool)
+	for _, m := range modelsResp.Data {
+		// Aliases such as mistral-large-latest are listed alongside the dated IDs
+		if m.ID == "" || m.Archived || seen[m.ID] {
+			continue
+		}
+		seen[m.ID] = true
+		name := m.ID
+		if strings.TrimSpace(m.Name) != "" {
+			name = m.Name
+		}
+		modelList = append(modelList, models.Model{
+			Name:     name,
+			ModelID:  m.ID,
+			IsActive: true,
+		})
+	}
+
+	return modelList, nil
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestMistralProvider_GetModels(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[
+			{"id":"mistral-large-2411","object":"model","owned_by":"mistralai","name":"mistral-large-2411","capabilities":{"completion_chat":true,"function_calling":true},"max_context_length":131072,"aliases":["mistral-large-latest"],"type":"base"},
+			{"id":"mistral-large-latest","object":"model","owned_by":"mistralai","name":"mistral-large-2411","aliases":["mistral-large-2411"],"type":"base"},
+			{"id":"mistral-embed","object":"model","owned_by":"mistralai","capabilities":{"completion_chat":false},"type":"base"},
+			{"id":"ft:open-mistral-7b:abc","object":"model","owned_by":"me","archived":true,"type":"fine-tuned"}
+		]}`))
+	}))
+	defer server.Close()
+
+	p := NewMistralProvider("mistral-key", server.URL)
+	modelList, err := p.GetModels(context.Background())
+	if err != nil {
+		t.Fatalf("GetModels failed: %v", err)
+	}
+
+	if gotPath != "/v1/models" {
+		t.Errorf("Expected /v1/models, got %q", gotPath)
+	}
+	if gotAuth != "Bearer mistral-key" {
+		t.Errorf("Expected bearer auth, got %q", gotAuth)
+	}
+	want := []string{"mistral-large-2411", "mistral-large-latest", "mistral-embed"}
+	if len(modelList) != len(want) {
+		t.Fatalf("Expected %d models, got %+v", len(want), modelList)
+	}
+	for i, id := range want {
+		if modelList[i].ModelID != id {
+			t.Errorf("Expected model %d to be %q, got %q", i, id, modelList[i].ModelID)
+		}
+	}
+	if modelList[2].Name != "mistral-embed" {
+		t.Errorf("Expected unnamed model to use its ID, got %q", modelList[2].Name)
+	}
+}
+
+func TestMistralProvider_Chat(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"cmpl-1","object":"chat.completion","model":"mistral-small-latest","choices":[{"index":0,"message":{"role":"assistant","content":"Bonjour"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	p := CreateProvider(&models.Provider{Name: "mistral", APIKey: "key", Host: server.URL})
+	if _, ok := p.(*MistralProvider); !ok {
+		t.Fatalf("Expected CreateProvider to return a MistralProvider, got %T", p)
+	}
+	result, err := p.Chat(context.Background(), "mistral-small-latest", []models.Message{{Role: "user", Content: "Salut"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("Expected /v1/chat/completions, got %q", gotPath)
+	}
+	if result.Content != "Bonjour" {
+		t.Errorf("Expected content Bonjour, got %q", result.Content)
+	}
+}
+
+func TestNewMistralProviderDefaultHost(t *testing.T) {
+	if got := NewMistralProvider("key", "").Host; got != defaultMistralHost {
+		t.Errorf("Expected default host %q, got %q", defaultMistralHost, got)
+	}
+}
@@ -2,11 +2,12 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"time"
 
 	"github.com/offbeat-studio/allama/internal/models"
 )
@@ -15,26 +16,63 @@ import (
 type OllamaProvider struct {
 	Host   string
 	client *http.Client
+	// streamClient sends streamed requests and long-running forwards such as model pulls,
+	// which have no overall timeout
+	streamClient *http.Client
+
+	// requestID is forwarded upstream as X-Request-ID
+	requestID string
+	// headers are the provider's custom headers, added to every upstream request
+	headers map[string]string
 }
 
+// defaultOllamaHost is used when no host is configured
+const defaultOllamaHost = "http://localhost:11434"
+
 // NewOllamaProvider creates a new instance of OllamaProvider
 func NewOllamaProvider(host string) *OllamaProvider {
+	if host == "" {
+		host = defaultOllamaHost
+	}
+	client, streamClient := newClients()
 	return &OllamaProvider{
-		Host: host,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		Host:         host,
+		client:       client,
+		streamClient: streamClient,
 	}
 }
 
+// Ping checks that the provider is reachable by listing its models
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
+
+// SetRequestID sets the correlation ID forwarded with every upstream request
+func (p *OllamaProvider) SetRequestID(id string) {
+	p.requestID = id
+}
+
+// SetHeaders sets the custom headers added to every upstream request
+func (p *OllamaProvider) SetHeaders(headers map[string]string) {
+	p.headers = headers
+}
+
+// SetTransport sets the transport of upstream requests, e.g. to go through a proxy
+func (p *OllamaProvider) SetTransport(transport http.RoundTripper) {
+	p.client.Transport = transport
+	p.streamClient.Transport = transport
+}
+
 // GetModels retrieves the list of available models from Ollama
-func (p *OllamaProvider) GetModels() ([]models.Model, error) {
+func (p *OllamaProvider) GetModels(ctx context.Context) ([]models.Model, error) {
 	url := fmt.Sprintf("%s/api/tags", p.Host)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -42,96 +80,356 @@ func (p *OllamaProvider) GetModels() ([]models.Model, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
 	var modelsResp struct {
 		Models []struct {
-			Name string `json:"name"`
+			Name    string `json:"name"`
+			Details struct {
+				Family            string `json:"family"`
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
 		} `json:"models"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+	if err := decodeResponse(resp, &modelsResp); err != nil {
 		return nil, err
 	}
 
 	var modelList []models.Model
 	for _, m := range modelsResp.Models {
-		modelList = append(modelList, models.Model{
+		model := models.Model{
 			Name:     m.Name,
 			ModelID:  m.Name,
 			IsActive: true,
-		})
+		}
+		if m.Details.Family != "" || m.Details.ParameterSize != "" || m.Details.QuantizationLevel != "" {
+			model.Metadata = &models.ModelMetadata{
+				Family:            m.Details.Family,
+				ParameterSize:     m.Details.ParameterSize,
+				QuantizationLevel: m.Details.QuantizationLevel,
+			}
+		}
+		modelList = append(modelList, model)
 	}
 
 	return modelList, nil
 }
 
-// Chat sends a chat request to Ollama and returns the response
-func (p *OllamaProvider) Chat(modelID string, messages []map[string]string) (string, error) {
-	url := fmt.Sprintf("%s/api/chat", p.Host)
+// ollamaOptionFields maps recognized sampling options to fields of Ollama's options object
+var ollamaOptionFields = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"top_k":             "top_k",
+	"max_tokens":        "num_predict",
+	"stop":              "stop",
+	"seed":              "seed",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"num_ctx":           "num_ctx",
+}
+
+// buildChatPayload builds the Ollama chat request body
+func (p *OllamaProvider) buildChatPayload(modelID string, messages []models.Message, opts map[string]interface{}, stream bool) map[string]interface{} {
 	payload := map[string]interface{}{
 		"model":    modelID,
-		"messages": messages,
-		"stream":   false,
+		"messages": convertOllamaMessages(messages),
+		"stream":   stream,
+	}
+	if tools, ok := opts["tools"]; ok {
+		payload["tools"] = tools
+	}
+	if format := ollamaFormat(opts); format != nil {
+		payload["format"] = format
+	}
+	options := make(map[string]interface{})
+	applyOptions(options, opts, ollamaOptionFields)
+	if stop, ok := options["stop"]; ok {
+		options["stop"] = stopSequences(stop)
 	}
+	if len(options) > 0 {
+		payload["options"] = options
+	}
+	return payload
+}
+
+// convertOllamaMessages maps messages to Ollama's format, where tool call arguments are objects
+func convertOllamaMessages(messages []models.Message) []map[string]interface{} {
+	ollamaMessages := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		ollamaMessage := map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+		if msg.HasImages() {
+			// Ollama only accepts inline base64 images
+			var images []string
+			for _, part := range msg.Parts {
+				if _, data, ok := part.DataURI(); ok {
+					images = append(images, data)
+				} else if part.Type == "image_url" {
+					log.Printf("Skipping non-inline image for Ollama: %s", part.ImageURL)
+				}
+			}
+			ollamaMessage["images"] = images
+		}
+		if len(msg.ToolCalls) > 0 {
+			toolCalls := make([]map[string]interface{}, 0, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"function": map[string]interface{}{
+						"name":      call.Function.Name,
+						"arguments": call.Function.ArgumentsObject(),
+					},
+				})
+			}
+			ollamaMessage["tool_calls"] = toolCalls
+		}
+		ollamaMessages = append(ollamaMessages, ollamaMessage)
+	}
+	return ollamaMessages
+}
+
+// Chat sends a chat request to Ollama and returns the response
+func (p *OllamaProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	url := fmt.Sprintf("%s/api/chat", p.Host)
+	payload := p.buildChatPayload(modelID, messages, opts, false)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
 	req.Header.Set("Content-Type", "application/json")
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
 	var chatResp struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string            `json:"content"`
+			ToolCalls []models.ToolCall `json:"tool_calls"`
 		} `json:"message"`
+		DoneReason      string `json:"done_reason"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", err
+	raw, err := readResponse(resp, &chatResp)
+	if err != nil {
+		return nil, err
 	}
 
-	return chatResp.Message.Content, nil
+	finishReason := chatResp.DoneReason
+	if len(chatResp.Message.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	return checkResult(resp, raw, &ChatResult{
+		Content:      chatResp.Message.Content,
+		ToolCalls:    chatResp.Message.ToolCalls,
+		FinishReason: finishReason,
+		Usage: &models.Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+			TotalTokens:      chatResp.PromptEvalCount + chatResp.EvalCount,
+		},
+	})
 }
 
-// ForwardRequest forwards a raw request to Ollama and returns the raw response
-func (p *OllamaProvider) ForwardRequest(method, path string, body []byte, headers map[string]string) ([]byte, int, error) {
-	url := fmt.Sprintf("%s%s", p.Host, path)
+// Generate sends a prompt to Ollama's native generate endpoint, which supports suffix for fill-in-the-middle
+func (p *OllamaProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	url := fmt.Sprintf("%s/api/generate", p.Host)
+	payload := map[string]interface{}{
+		"model":  modelID,
+		"prompt": prompt,
+		"stream": false,
+	}
+	if suffix, ok := opts["suffix"]; ok {
+		payload["suffix"] = suffix
+	}
+	if format := ollamaFormat(opts); format != nil {
+		payload["format"] = format
+	}
+	options := make(map[string]interface{})
+	applyOptions(options, opts, ollamaOptionFields)
+	if stop, ok := options["stop"]; ok {
+		options["stop"] = stopSequences(stop)
+	}
+	if len(options) > 0 {
+		payload["options"] = options
+	}
 
-	var req *http.Request
-	var err error
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
 
-	if body != nil {
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(body))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
+
+	req.Header.Set("Content-Type", "application/json")
 
+	setCustomHeaders(req, p.headers)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Copy headers from the original request
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp)
+	}
+
+	var generateResp struct {
+		Response        string `json:"response"`
+		Thinking        string `json:"thinking"`
+		DoneReason      string `json:"done_reason"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+	raw, err := readResponse(resp, &generateResp)
+	if err != nil {
+		return nil, err
 	}
 
+	return checkResult(resp, raw, &ChatResult{
+		Content:      generateResp.Response,
+		FinishReason: generateResp.DoneReason,
+		Usage: &models.Usage{
+			PromptTokens:     generateResp.PromptEvalCount,
+			CompletionTokens: generateResp.EvalCount,
+			TotalTokens:      generateResp.PromptEvalCount + generateResp.EvalCount,
+		},
+		Thinking: generateResp.Thinking,
+	})
+}
+
+// ChatStream sends a streaming chat request to Ollama and invokes onChunk for every content delta
+func (p *OllamaProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	url := fmt.Sprintf("%s/api/chat", p.Host)
+	payload := p.buildChatPayload(modelID, messages, opts, true)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newUpstreamError(resp)
+	}
+	if err := checkContentType(resp, append([]string{"application/x-ndjson"}, jsonMediaTypes...)...); err != nil {
+		return err
+	}
+
+	// Ollama streams newline-delimited JSON objects
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done  bool   `json:"done"`
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if chunk.Error != "" {
+			return fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			if err := onChunk(StreamChunk{Content: chunk.Message.Content}); err != nil {
+				return err
+			}
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+}
+
+// Embeddings requests an embedding vector for the input from Ollama
+func (p *OllamaProvider) Embeddings(ctx context.Context, modelID string, input string) ([]float64, error) {
+	url := fmt.Sprintf("%s/api/embeddings", p.Host)
+	payload := map[string]interface{}{
+		"model":  modelID,
+		"prompt": input,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp)
+	}
+
+	var embeddingResp struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := decodeResponse(resp, &embeddingResp); err != nil {
+		return nil, err
+	}
+
+	return embeddingResp.Embedding, nil
+}
+
+// ForwardRequest forwards a raw request to Ollama and returns the raw response
+func (p *OllamaProvider) ForwardRequest(ctx context.Context, method, path string, body []byte, headers map[string]string) ([]byte, int, error) {
+	resp, err := p.ForwardResponse(ctx, method, path, body, headers)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -144,3 +442,41 @@ func (p *OllamaProvider) ForwardRequest(method, path string, body []byte, header
 
 	return responseBody, resp.StatusCode, nil
 }
+
+// ForwardResponse forwards a raw request to Ollama and returns the response, with its status
+// and headers, for the caller to read and close. It is bounded by the client timeout.
+func (p *OllamaProvider) ForwardResponse(ctx context.Context, method, path string, body []byte, headers map[string]string) (*http.Response, error) {
+	return p.forward(ctx, p.client, method, path, body, headers)
+}
+
+// ForwardStream forwards a request to Ollama and returns the response for the caller to
+// stream and close, without buffering the body or applying the client timeout
+func (p *OllamaProvider) ForwardStream(ctx context.Context, method, path string, body []byte, headers map[string]string) (*http.Response, error) {
+	return p.forward(ctx, p.streamClient, method, path, body, headers)
+}
+
+// forward sends a raw request to Ollama with the client's headers, overridden by the custom headers
+func (p *OllamaProvider) forward(ctx context.Context, client *http.Client, method, path string, body []byte, headers map[string]string) (*http.Response, error) {
+	url := fmt.Sprintf("%s%s", p.Host, path)
+
+	var req *http.Request
+	var err error
+
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy headers from the original request
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	setCustomHeaders(req, p.headers)
+	return client.Do(req)
+}
@@ -2,43 +2,104 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/offbeat-studio/allama/internal/models"
 )
 
+// defaultOpenAIHost is used when no host is configured
+const defaultOpenAIHost = "https://api.openai.com"
+
 // OpenAIProvider handles interactions with the OpenAI API
 type OpenAIProvider struct {
 	APIKey string
 	Host   string
 	client *http.Client
+	// streamClient sends streamed requests, which have no overall timeout
+	streamClient *http.Client
+
+	// requestID is forwarded upstream as X-Request-ID
+	requestID string
+	// headers are the provider's custom headers, added to every upstream request
+	headers map[string]string
+
+	// endpoint and authorize override the public OpenAI URL scheme and bearer
+	// authentication for compatible APIs that differ only in those respects
+	endpoint  func(path, modelID string) string
+	authorize func(req *http.Request)
+	// completions reports whether a model is served by the legacy /completions endpoint
+	completions func(modelID string) bool
 }
 
 // NewOpenAIProvider creates a new instance of OpenAIProvider
 func NewOpenAIProvider(apiKey string, host string) *OpenAIProvider {
+	if host == "" {
+		host = defaultOpenAIHost
+	}
+	client, streamClient := newClients()
 	return &OpenAIProvider{
-		APIKey: apiKey,
-		Host:   host,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		APIKey:       apiKey,
+		Host:         strings.TrimSuffix(host, "/"),
+		client:       client,
+		streamClient: streamClient,
+	}
+}
+
+// Ping checks that the provider is reachable by listing its models
+func (p *OpenAIProvider) Ping(ctx context.Context) error {
+	return pingByListingModels(ctx, p)
+}
+
+// SetRequestID sets the correlation ID forwarded with every upstream request
+func (p *OpenAIProvider) SetRequestID(id string) {
+	p.requestID = id
+}
+
+// SetHeaders sets the custom headers added to every upstream request
+func (p *OpenAIProvider) SetHeaders(headers map[string]string) {
+	p.headers = headers
+}
+
+// SetTransport sets the transport of upstream requests, e.g. to go through a proxy
+func (p *OpenAIProvider) SetTransport(transport http.RoundTripper) {
+	p.client.Transport = transport
+	p.streamClient.Transport = transport
+}
+
+// url returns the request URL for an API path such as /chat/completions
+func (p *OpenAIProvider) url(path, modelID string) string {
+	if p.endpoint != nil {
+		return p.endpoint(path, modelID)
+	}
+	return fmt.Sprintf("%s/v1%s", p.Host, path)
+}
+
+// setAuth adds the credentials to an upstream request
+func (p *OpenAIProvider) setAuth(req *http.Request) {
+	if p.authorize != nil {
+		p.authorize(req)
+		return
 	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.APIKey))
 }
 
 // GetModels retrieves the list of available models from OpenAI
-func (p *OpenAIProvider) GetModels() ([]models.Model, error) {
-	url := fmt.Sprintf("%s/v1/models", p.Host)
-	req, err := http.NewRequest("GET", url, nil)
+func (p *OpenAIProvider) GetModels(ctx context.Context) ([]models.Model, error) {
+	url := p.url("/models", "")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.APIKey))
+	p.setAuth(req)
 	req.Header.Set("Content-Type", "application/json")
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -46,7 +107,7 @@ func (p *OpenAIProvider) GetModels() ([]models.Model, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
 	var modelsResp struct {
@@ -54,7 +115,7 @@ func (p *OpenAIProvider) GetModels() ([]models.Model, error) {
 			ID string `json:"id"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+	if err := decodeResponse(resp, &modelsResp); err != nil {
 		return nil, err
 	}
 
@@ -70,50 +131,299 @@ func (p *OpenAIProvider) GetModels() ([]models.Model, error) {
 	return modelList, nil
 }
 
-// Chat sends a chat request to OpenAI and returns the response
-func (p *OpenAIProvider) Chat(modelID string, messages []map[string]string) (string, error) {
-	url := fmt.Sprintf("%s/v1/chat/completions", p.Host)
+// openAIOptionFields maps recognized sampling options to OpenAI request fields
+var openAIOptionFields = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"max_tokens":        "max_tokens",
+	"stop":              "stop",
+	"seed":              "seed",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"tools":             "tools",
+	"tool_choice":       "tool_choice",
+	"response_format":   "response_format",
+}
+
+// buildChatPayload builds the OpenAI chat completions request body
+func (p *OpenAIProvider) buildChatPayload(modelID string, messages []models.Message, opts map[string]interface{}, stream bool) map[string]interface{} {
 	payload := map[string]interface{}{
 		"model":    modelID,
 		"messages": messages,
 	}
+	applyOptions(payload, opts, openAIOptionFields)
+	if stream {
+		payload["stream"] = true
+	}
+	return payload
+}
+
+// Chat sends a chat request to OpenAI and returns the response
+func (p *OpenAIProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	url := p.url("/chat/completions", modelID)
+	payload := p.buildChatPayload(modelID, messages, opts, false)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	setRequestIDHeader(req, p.requestID)
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.APIKey))
+	p.setAuth(req)
 	req.Header.Set("Content-Type", "application/json")
 
+	setCustomHeaders(req, p.headers)
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newUpstreamError(resp)
 	}
 
 	var chatResp struct {
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content          string            `json:"content"`
+				ReasoningContent string            `json:"reasoning_content"`
+				ToolCalls        []models.ToolCall `json:"tool_calls"`
 			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
+		Usage             *models.Usage `json:"usage"`
+		SystemFingerprint string        `json:"system_fingerprint"`
+		// Citations and SearchResults are returned by search-grounded APIs such as Perplexity
+		Citations     []string `json:"citations"`
+		SearchResults []struct {
+			URL string `json:"url"`
+		} `json:"search_results"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", err
+	raw, err := readResponse(resp, &chatResp)
+	if err != nil {
+		return nil, err
+	}
+
+	citations := chatResp.Citations
+	if len(citations) == 0 {
+		// Newer responses only list the sources as search results
+		for _, result := range chatResp.SearchResults {
+			if result.URL != "" {
+				citations = append(citations, result.URL)
+			}
+		}
 	}
 
 	if len(chatResp.Choices) > 0 {
-		return chatResp.Choices[0].Message.Content, nil
+		return checkResult(resp, raw, &ChatResult{
+			Content:      chatResp.Choices[0].Message.Content,
+			ToolCalls:    chatResp.Choices[0].Message.ToolCalls,
+			FinishReason: chatResp.Choices[0].FinishReason,
+			Usage:        chatResp.Usage,
+
+			SystemFingerprint: chatResp.SystemFingerprint,
+			Thinking:          chatResp.Choices[0].Message.ReasoningContent,
+			Citations:         citations,
+		})
+	}
+	return checkResult(resp, raw, nil)
+}
+
+// ChatStream sends a streaming chat request to OpenAI and invokes onChunk for every content delta
+func (p *OpenAIProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	url := p.url("/chat/completions", modelID)
+	payload := p.buildChatPayload(modelID, messages, opts, true)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	p.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newUpstreamError(resp)
+	}
+	if err := checkContentType(resp, "text/event-stream", "text/plain"); err != nil {
+		return err
+	}
+
+	return readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		return onChunk(StreamChunk{Content: chunk.Choices[0].Delta.Content})
+	})
+}
+
+// isOpenAICompletionModel reports whether an OpenAI model accepts text completions.
+// Only the instruct and base models do; chat models reject /v1/completions.
+func isOpenAICompletionModel(modelID string) bool {
+	return strings.Contains(modelID, "instruct") ||
+		strings.HasPrefix(modelID, "davinci") ||
+		strings.HasPrefix(modelID, "babbage")
+}
+
+// openAICompletionFields maps recognized sampling options to OpenAI completions request fields
+var openAICompletionFields = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"max_tokens":        "max_tokens",
+	"stop":              "stop",
+	"seed":              "seed",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"suffix":            "suffix",
+}
+
+// Generate completes a prompt, using the native completions endpoint for models that support
+// it and falling back to a chat request for chat-only models
+func (p *OpenAIProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	completions := p.completions
+	if completions == nil {
+		completions = isOpenAICompletionModel
+	}
+	if !completions(modelID) {
+		return generateWithChat(ctx, p, modelID, prompt, opts)
+	}
+
+	url := p.url("/completions", modelID)
+	payload := map[string]interface{}{
+		"model":  modelID,
+		"prompt": prompt,
+	}
+	applyOptions(payload, opts, openAICompletionFields)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	p.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp)
+	}
+
+	var completionResp struct {
+		Choices []struct {
+			Text         string `json:"text"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage             *models.Usage `json:"usage"`
+		SystemFingerprint string        `json:"system_fingerprint"`
+	}
+	raw, err := readResponse(resp, &completionResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(completionResp.Choices) > 0 {
+		return checkResult(resp, raw, &ChatResult{
+			Content:      completionResp.Choices[0].Text,
+			FinishReason: completionResp.Choices[0].FinishReason,
+			Usage:        completionResp.Usage,
+
+			SystemFingerprint: completionResp.SystemFingerprint,
+		})
+	}
+	return checkResult(resp, raw, nil)
+}
+
+// Embeddings requests an embedding vector for the input from OpenAI
+func (p *OpenAIProvider) Embeddings(ctx context.Context, modelID string, input string) ([]float64, error) {
+	url := p.url("/embeddings", modelID)
+	payload := map[string]interface{}{
+		"model": modelID,
+		"input": input,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	setRequestIDHeader(req, p.requestID)
+
+	p.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp)
+	}
+
+	var embeddingsResp struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := decodeResponse(resp, &embeddingsResp); err != nil {
+		return nil, err
+	}
+
+	if len(embeddingsResp.Data) > 0 {
+		return embeddingsResp.Data[0].Embedding, nil
 	}
-	return "", fmt.Errorf("no response content found")
+	return nil, fmt.Errorf("no embedding found")
 }
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// OpenAICompatibleProvidersEnvVar lists the names of OpenAI-compatible providers to register
+const OpenAICompatibleProvidersEnvVar = "OPENAI_COMPATIBLE_PROVIDERS"
+
+// OpenAICompatibleType is the provider type of generic OpenAI-compatible backends
+const OpenAICompatibleType = "openai-compatible"
+
+// isBuiltinType reports whether name is one of the built-in provider types
+func isBuiltinType(name string) bool {
+	for _, env := range builtinProviderEnvs {
+		if env.Type == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAICompatibleProvider handles servers that speak the OpenAI API at a custom
+// base URL, such as vLLM, LM Studio, llama.cpp, Groq, Together or OpenRouter
+type OpenAICompatibleProvider struct {
+	*OpenAIProvider
+	// AuthHeader and AuthValue are sent with every request when both are set
+	AuthHeader string
+	AuthValue  string
+}
+
+// NewOpenAICompatibleProvider creates a provider for an OpenAI-compatible API.
+// baseURL already includes any version prefix, e.g. https://api.groq.com/openai/v1.
+func NewOpenAICompatibleProvider(baseURL, authHeader, authValue string) *OpenAICompatibleProvider {
+	p := &OpenAICompatibleProvider{
+		OpenAIProvider: NewOpenAIProvider("", baseURL),
+		AuthHeader:     authHeader,
+		AuthValue:      authValue,
+	}
+	// There is no sensible default host for a self-hosted backend
+	p.Host = strings.TrimSuffix(baseURL, "/")
+	p.endpoint = func(path, _ string) string {
+		return p.Host + path
+	}
+	p.authorize = func(req *http.Request) {
+		if p.AuthHeader != "" && p.AuthValue != "" {
+			req.Header.Set(p.AuthHeader, p.AuthValue)
+		}
+	}
+	// Self-hosted servers such as vLLM and llama.cpp serve completions for every model
+	p.completions = func(string) bool { return true }
+	return p
+}
+
+// OpenAICompatibleNames returns the provider names listed in OPENAI_COMPATIBLE_PROVIDERS,
+// skipping blanks, duplicates and names reserved by the built-in providers
+func OpenAICompatibleNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv(OpenAICompatibleProvidersEnvVar), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] || isBuiltinType(name) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// openAICompatibleEnvPrefix turns a provider name into its environment variable prefix, e.g. lm-studio -> LM_STUDIO
+func openAICompatibleEnvPrefix(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// openAICompatibleConfigs returns the provider configurations for OPENAI_COMPATIBLE_PROVIDERS
+func openAICompatibleConfigs() []ProviderConfig {
+	var configs []ProviderConfig
+	for _, name := range OpenAICompatibleNames() {
+		prefix := openAICompatibleEnvPrefix(name)
+		config := ProviderConfig{
+			Name:         name,
+			Type:         OpenAICompatibleType,
+			Host:         os.Getenv(prefix + "_HOST"),
+			EnableEnvVar: "IS_" + prefix + "_ACTIVE",
+			ApiKeyEnvVar: prefix + "_API_KEY",
+			HostRequired: true,
+
+			MaxConcurrency: maxConcurrencyFromEnv("IS_" + prefix + "_ACTIVE"),
+			Headers:        headersFromEnv("IS_" + prefix + "_ACTIVE"),
+
+			DefaultMaxTokens: defaultMaxTokensFromEnv("IS_" + prefix + "_ACTIVE"),
+		}
+		config.SystemPrompt, config.SystemPromptMode = systemPromptFromEnv(config.EnableEnvVar)
+		config.Priority, config.Weight = priorityFromEnv(config.EnableEnvVar)
+		config.Proxy = proxyFromEnv(config.EnableEnvVar)
+		config.TLS = tlsFromEnv(config.EnableEnvVar)
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// newOpenAICompatibleProviderFromEnv creates a compatible provider for a stored provider.
+// The API key is sent as a bearer token unless {NAME}_AUTH_HEADER names another header,
+// in which case the key is sent verbatim in that header.
+func newOpenAICompatibleProviderFromEnv(prov *models.Provider) *OpenAICompatibleProvider {
+	authHeader := os.Getenv(openAICompatibleEnvPrefix(prov.Name) + "_AUTH_HEADER")
+	authValue := prov.APIKey
+	if authHeader == "" && authValue != "" {
+		authHeader = "Authorization"
+		authValue = "Bearer " + authValue
+	}
+	return NewOpenAICompatibleProvider(prov.Host, authHeader, authValue)
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestOpenAICompatibleProvider_Chat(t *testing.T) {
+	var gotPath, gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-api-key")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello from vLLM"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatibleProvider(server.URL+"/openai/v1/", "x-api-key", "secret")
+	result, err := p.Chat(context.Background(), "llama-3.1-8b", []models.Message{{Role: "user", Content: "Hi"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if result.Content != "Hello from vLLM" {
+		t.Errorf("Expected content from the backend, got %q", result.Content)
+	}
+	if gotPath != "/openai/v1/chat/completions" {
+		t.Errorf("Expected the base URL to be used as is, got %q", gotPath)
+	}
+	if gotKey != "secret" || gotAuth != "" {
+		t.Errorf("Expected the custom auth header only, got x-api-key=%q Authorization=%q", gotKey, gotAuth)
+	}
+}
+
+func TestOpenAICompatibleProvider_FromEnv(t *testing.T) {
+	t.Setenv(OpenAICompatibleProvidersEnvVar, " groq, lm-studio,groq,openai,")
+	t.Setenv("GROQ_HOST", "https://api.groq.com/openai/v1")
+	t.Setenv("LM_STUDIO_AUTH_HEADER", "")
+
+	configs := openAICompatibleConfigs()
+	if len(configs) != 2 || configs[0].Name != "groq" || configs[1].Name != "lm-studio" {
+		t.Fatalf("Expected groq and lm-studio configs, got %+v", configs)
+	}
+	if configs[0].Type != OpenAICompatibleType || configs[0].Host != "https://api.groq.com/openai/v1" || configs[1].EnableEnvVar != "IS_LM_STUDIO_ACTIVE" || configs[1].ApiKeyEnvVar != "LM_STUDIO_API_KEY" {
+		t.Errorf("Unexpected env mapping: %+v", configs)
+	}
+
+	p, ok := CreateProvider(&models.Provider{Name: "groq", Type: configs[0].Type, Host: configs[0].Host, APIKey: "gsk"}).(*OpenAICompatibleProvider)
+	if !ok {
+		t.Fatal("Expected an OpenAICompatibleProvider for the openai-compatible type")
+	}
+	if p.AuthHeader != "Authorization" || p.AuthValue != "Bearer gsk" {
+		t.Errorf("Expected bearer authentication by default, got %s: %s", p.AuthHeader, p.AuthValue)
+	}
+
+	keyless := CreateProvider(&models.Provider{Name: "lm-studio", Type: OpenAICompatibleType, Host: "http://localhost:1234/v1"}).(*OpenAICompatibleProvider)
+	if keyless.AuthHeader != "" {
+		t.Errorf("Expected no auth header without a key, got %q", keyless.AuthHeader)
+	}
+	if CreateProvider(&models.Provider{Name: "together"}) != nil {
+		t.Error("Expected a name without a known type to remain unknown")
+	}
+}
@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// chatOptionKeys lists the sampling options accepted from clients. Anything else is dropped.
+var chatOptionKeys = []string{
+	"temperature",
+	"top_p",
+	"top_k",
+	"max_tokens",
+	"stop",
+	"seed",
+	"presence_penalty",
+	"frequency_penalty",
+	"tools",
+	"tool_choice",
+	"response_format",
+	"suffix",
+	"num_ctx",
+}
+
+// nestedOptionKeys name request fields that hold options as an object. Ollama clients send
+// them under "options", and /api/generate also accepts "parameters".
+var nestedOptionKeys = []string{"parameters", "options"}
+
+// ollamaOptionNames maps Ollama option names to the equivalent chat option
+var ollamaOptionNames = map[string]string{
+	"num_predict": "max_tokens",
+}
+
+// FilterChatOptions returns only the recognized sampling options from a request body.
+// Options nested under "options" or "parameters" are included, but top-level ones win.
+func FilterChatOptions(params map[string]interface{}) map[string]interface{} {
+	opts := make(map[string]interface{})
+	for _, nestedKey := range nestedOptionKeys {
+		nested, _ := params[nestedKey].(map[string]interface{})
+		for name, value := range nested {
+			// A negative num_predict means no limit, as does leaving max_tokens out
+			if n, ok := value.(float64); ok && name == "num_predict" && n < 0 {
+				continue
+			}
+			if renamed, ok := ollamaOptionNames[name]; ok {
+				name = renamed
+			}
+			if isChatOptionKey(name) && value != nil {
+				opts[name] = value
+			}
+		}
+	}
+	for _, key := range chatOptionKeys {
+		if value, ok := params[key]; ok && value != nil {
+			opts[key] = value
+		}
+	}
+	return opts
+}
+
+// optionRange bounds a numeric option; integer options must also be whole numbers
+type optionRange struct {
+	min, max float64
+	integer  bool
+}
+
+// numericOptionRanges lists the accepted range of every numeric chat option, following OpenAI's limits
+var numericOptionRanges = map[string]optionRange{
+	"temperature":       {0, 2, false},
+	"top_p":             {0, 1, false},
+	"top_k":             {0, math.MaxInt32, true},
+	"max_tokens":        {1, math.MaxInt32, true},
+	"num_ctx":           {1, math.MaxInt32, true},
+	"seed":              {math.MinInt64, math.MaxInt64, true},
+	"presence_penalty":  {-2, 2, false},
+	"frequency_penalty": {-2, 2, false},
+}
+
+// responseFormatTypes lists the accepted response_format types
+var responseFormatTypes = map[string]bool{"text": true, "json_object": true, "json_schema": true}
+
+// OptionError reports a chat option with an invalid type or value
+type OptionError struct {
+	// Option names the offending request field
+	Option  string
+	Message string
+}
+
+func (e *OptionError) Error() string {
+	return e.Message
+}
+
+// invalidOption returns an OptionError for option
+func invalidOption(option, message string) error {
+	return &OptionError{Option: option, Message: message}
+}
+
+// ValidateChatOptions checks the types and ranges of filtered chat options, returning an
+// *OptionError naming the first invalid one. Unrecognized options are never rejected.
+func ValidateChatOptions(opts map[string]interface{}) error {
+	for _, key := range chatOptionKeys {
+		value, ok := opts[key]
+		if !ok {
+			continue
+		}
+		if bounds, numeric := numericOptionRanges[key]; numeric {
+			n, ok := value.(float64)
+			if !ok {
+				return invalidOption(key, fmt.Sprintf("%s must be a number", key))
+			}
+			if bounds.integer && n != math.Trunc(n) {
+				return invalidOption(key, fmt.Sprintf("%s must be an integer", key))
+			}
+			if n < bounds.min || n > bounds.max {
+				return invalidOption(key, fmt.Sprintf("%s must be between %g and %g", key, bounds.min, bounds.max))
+			}
+			continue
+		}
+
+		switch key {
+		case "stop":
+			if _, ok := value.(string); ok {
+				continue
+			}
+			sequences, ok := value.([]interface{})
+			if !ok {
+				return invalidOption("stop", "stop must be a string or a list of strings")
+			}
+			for _, sequence := range sequences {
+				if _, ok := sequence.(string); !ok {
+					return invalidOption("stop", "stop must be a string or a list of strings")
+				}
+			}
+		case "suffix":
+			if _, ok := value.(string); !ok {
+				return invalidOption("suffix", "suffix must be a string")
+			}
+		case "tools":
+			if _, ok := value.([]interface{}); !ok {
+				return invalidOption("tools", "tools must be a list")
+			}
+		case "response_format":
+			if _, ok := value.(map[string]interface{}); !ok {
+				return invalidOption("response_format", "response_format must be an object")
+			}
+			if formatType := responseFormatType(opts); !responseFormatTypes[formatType] {
+				return invalidOption("response_format", fmt.Sprintf("response_format type %q is not supported", formatType))
+			}
+		}
+	}
+	return nil
+}
+
+// messageRoles lists the roles a chat message may have
+var messageRoles = map[string]bool{"system": true, "user": true, "assistant": true, "tool": true}
+
+// ValidateMessages checks that a chat request has at least one message and that every message
+// has a known role, returning an *OptionError naming the first invalid field
+func ValidateMessages(messages []models.Message) error {
+	if len(messages) == 0 {
+		return invalidOption("messages", "messages must contain at least one message")
+	}
+	for i, msg := range messages {
+		if !messageRoles[msg.Role] {
+			field := fmt.Sprintf("messages[%d].role", i)
+			return invalidOption(field, fmt.Sprintf("%s must be one of system, user, assistant or tool, got %q", field, msg.Role))
+		}
+	}
+	return nil
+}
+
+// ValidateKeepAlive checks an Ollama keep_alive value, which is either a number of seconds or a
+// duration string such as "5m". Negative values keep the model loaded indefinitely. A nil
+// value is valid, and the error is an *OptionError for keep_alive.
+func ValidateKeepAlive(value interface{}) error {
+	switch v := value.(type) {
+	case nil, float64:
+		return nil
+	case string:
+		if _, err := time.ParseDuration(v); err == nil {
+			return nil
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return nil
+		}
+	}
+	return invalidOption("keep_alive", `keep_alive must be a duration such as "5m" or a number of seconds`)
+}
+
+// isChatOptionKey reports whether key is a recognized sampling option
+func isChatOptionKey(key string) bool {
+	for _, known := range chatOptionKeys {
+		if known == key {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOptions copies the options named in mapping into target, renaming them to the provider's field names
+func applyOptions(target map[string]interface{}, opts map[string]interface{}, mapping map[string]string) {
+	for key, field := range mapping {
+		if value, ok := opts[key]; ok {
+			target[field] = value
+		}
+	}
+}
+
+// stopSequences normalizes a stop option, which may be a single string or a list, into a list
+func stopSequences(stop interface{}) []interface{} {
+	switch v := stop.(type) {
+	case string:
+		return []interface{}{v}
+	case []string:
+		sequences := make([]interface{}, len(v))
+		for i, s := range v {
+			sequences[i] = s
+		}
+		return sequences
+	case []interface{}:
+		return v
+	default:
+		return nil
+	}
+}
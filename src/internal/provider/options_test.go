@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestFilterChatOptions(t *testing.T) {
+	opts := FilterChatOptions(map[string]interface{}{
+		"model":       "gpt-4o",
+		"temperature": 0.2,
+		"max_tokens":  256.0,
+		"logit_bias":  map[string]interface{}{"50256": -100},
+	})
+
+	if len(opts) != 2 {
+		t.Fatalf("Expected 2 recognized options, got %v", opts)
+	}
+	if opts["temperature"] != 0.2 || opts["max_tokens"] != 256.0 {
+		t.Errorf("Unexpected options: %v", opts)
+	}
+}
+
+func TestFilterChatOptionsNested(t *testing.T) {
+	opts := FilterChatOptions(map[string]interface{}{
+		"seed": 7.0,
+		"options": map[string]interface{}{
+			"seed":        42.0,
+			"num_predict": 128.0,
+			"mirostat":    1.0,
+		},
+	})
+
+	if opts["seed"] != 7.0 {
+		t.Errorf("Expected the top-level seed to win, got %v", opts["seed"])
+	}
+	if opts["max_tokens"] != 128.0 {
+		t.Errorf("Expected num_predict to map to max_tokens, got %v", opts)
+	}
+	if _, ok := opts["mirostat"]; ok {
+		t.Errorf("Expected unrecognized Ollama options to be dropped, got %v", opts)
+	}
+}
+
+func TestOpenAIProvider_BuildChatPayload(t *testing.T) {
+	p := NewOpenAIProvider("test-key", "https://api.openai.com")
+	opts := map[string]interface{}{"temperature": 0.5, "seed": 42.0, "top_k": 10.0}
+
+	payload := p.buildChatPayload("gpt-4o", nil, opts, false)
+
+	if payload["temperature"] != 0.5 || payload["seed"] != 42.0 {
+		t.Errorf("Expected temperature and seed to be forwarded, got %v", payload)
+	}
+	if _, ok := payload["top_k"]; ok {
+		t.Errorf("Expected top_k to be dropped for OpenAI, got %v", payload)
+	}
+	if _, ok := payload["stream"]; ok {
+		t.Errorf("Expected stream to be omitted for non-streaming requests")
+	}
+}
+
+func TestStopReachesProviderPayloads(t *testing.T) {
+	for _, stop := range []interface{}{"END", []interface{}{"END", "\n\n"}} {
+		want := stopSequences(stop)
+
+		openai := NewOpenAIProvider("test-key", "").buildChatPayload("gpt-4o", nil, map[string]interface{}{"stop": stop}, false)
+		if !reflect.DeepEqual(openai["stop"], stop) {
+			t.Errorf("Expected OpenAI to receive stop %v unchanged, got %v", stop, openai["stop"])
+		}
+
+		anthropic := NewAnthropicProvider("test-key", "").buildChatPayload("claude-3-haiku", nil, map[string]interface{}{"stop": stop}, false)
+		if !reflect.DeepEqual(anthropic["stop_sequences"], want) {
+			t.Errorf("Expected Anthropic stop_sequences %v, got %v", want, anthropic["stop_sequences"])
+		}
+
+		titan := buildTitanPayload(nil, map[string]interface{}{"stop": stop})
+		if config := titan["textGenerationConfig"].(map[string]interface{}); !reflect.DeepEqual(config["stopSequences"], want) {
+			t.Errorf("Expected Titan stopSequences %v, got %v", want, config["stopSequences"])
+		}
+
+		ollama := NewOllamaProvider("").buildChatPayload("llama3", nil, map[string]interface{}{"stop": stop}, false)
+		if options := ollama["options"].(map[string]interface{}); !reflect.DeepEqual(options["stop"], want) {
+			t.Errorf("Expected Ollama options.stop %v, got %v", want, options["stop"])
+		}
+	}
+}
+
+func TestAnthropicProvider_BuildChatPayload(t *testing.T) {
+	p := NewAnthropicProvider("test-key", "https://api.anthropic.com")
+
+	payload := p.buildChatPayload("claude-3-haiku", nil, nil, false)
+	if payload["max_tokens"] != anthropicDefaultMaxTokens {
+		t.Errorf("Expected default max_tokens %d, got %v", anthropicDefaultMaxTokens, payload["max_tokens"])
+	}
+
+	opts := map[string]interface{}{"max_tokens": 4096.0, "stop": "END", "seed": 1.0}
+	payload = p.buildChatPayload("claude-3-haiku", nil, opts, false)
+	if payload["max_tokens"] != 4096.0 {
+		t.Errorf("Expected max_tokens 4096, got %v", payload["max_tokens"])
+	}
+	if !reflect.DeepEqual(payload["stop_sequences"], []interface{}{"END"}) {
+		t.Errorf("Expected stop_sequences [END], got %v", payload["stop_sequences"])
+	}
+	if _, ok := payload["stop"]; ok {
+		t.Errorf("Expected stop to be renamed for Anthropic")
+	}
+	if _, ok := payload["seed"]; ok {
+		t.Errorf("Expected seed to be dropped for Anthropic")
+	}
+}
+
+func TestValidateChatOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]interface{}
+		wantErr bool
+	}{
+		{"empty", map[string]interface{}{}, false},
+		{"valid", map[string]interface{}{"temperature": 0.7, "max_tokens": float64(100), "stop": []interface{}{"\n"}, "seed": float64(42)}, false},
+		{"stop string", map[string]interface{}{"stop": "END"}, false},
+		{"temperature too high", map[string]interface{}{"temperature": 2.5}, true},
+		{"temperature not a number", map[string]interface{}{"temperature": "hot"}, true},
+		{"zero max_tokens", map[string]interface{}{"max_tokens": float64(0)}, true},
+		{"fractional top_k", map[string]interface{}{"top_k": 1.5}, true},
+		{"stop list with number", map[string]interface{}{"stop": []interface{}{"a", 1.0}}, true},
+		{"tools not a list", map[string]interface{}{"tools": "search"}, true},
+		{"unknown response_format", map[string]interface{}{"response_format": map[string]interface{}{"type": "xml"}}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateChatOptions(tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  []models.Message
+		wantField string
+	}{
+		{"valid", []models.Message{{Role: "system", Content: "Be brief"}, {Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello"}, {Role: "tool", Content: "{}"}}, ""},
+		{"empty", nil, "messages"},
+		{"empty role", []models.Message{{Role: "user", Content: "Hi"}, {Content: "Hi"}}, "messages[1].role"},
+		{"unknown role", []models.Message{{Role: "bot", Content: "Hi"}}, "messages[0].role"},
+	}
+	for _, tt := range tests {
+		err := ValidateMessages(tt.messages)
+		if tt.wantField == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		var optionErr *OptionError
+		if !errors.As(err, &optionErr) || optionErr.Option != tt.wantField {
+			t.Errorf("%s: expected an error naming %s, got %v", tt.name, tt.wantField, err)
+		}
+	}
+}
+
+func TestValidateKeepAlive(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		wantErr bool
+	}{
+		{nil, false},
+		{"5m", false},
+		{"-1", false},
+		{float64(300), false},
+		{"soon", true},
+		{true, true},
+	}
+	for _, tt := range tests {
+		err := ValidateKeepAlive(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: expected error %v, got %v", tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateChatOptionsBoundaries(t *testing.T) {
+	tests := []struct {
+		option  string
+		value   interface{}
+		wantErr bool
+	}{
+		{"temperature", 0.0, false},
+		{"temperature", 2.0, false},
+		{"temperature", -0.1, true},
+		{"temperature", 2.1, true},
+		{"top_p", 0.0, false},
+		{"top_p", 1.0, false},
+		{"top_p", -0.1, true},
+		{"top_p", 1.1, true},
+		{"top_k", 0.0, false},
+		{"top_k", -1.0, true},
+		{"max_tokens", 1.0, false},
+		{"max_tokens", 0.0, true},
+		{"max_tokens", -5.0, true},
+		{"max_tokens", "100", true},
+		{"seed", -1.0, false},
+		{"seed", 0.5, true},
+		{"presence_penalty", -2.0, false},
+		{"presence_penalty", 2.0, false},
+		{"presence_penalty", -2.1, true},
+		{"presence_penalty", 2.1, true},
+		{"frequency_penalty", -2.0, false},
+		{"frequency_penalty", 2.0, false},
+		{"frequency_penalty", -2.1, true},
+		{"frequency_penalty", 2.1, true},
+		{"stop", "", false},
+		{"stop", 1.0, true},
+		{"suffix", "}", false},
+		{"suffix", 1.0, true},
+		{"tools", []interface{}{}, false},
+		{"tools", map[string]interface{}{}, true},
+		{"response_format", map[string]interface{}{"type": "text"}, false},
+		{"response_format", map[string]interface{}{"type": "json_object"}, false},
+		{"response_format", map[string]interface{}{"type": "json_schema"}, false},
+		{"response_format", map[string]interface{}{"type": "yaml"}, true},
+		{"response_format", "json_object", true},
+	}
+	for _, tt := range tests {
+		err := ValidateChatOptions(map[string]interface{}{tt.option: tt.value, "unknown_option": -1.0})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s=%v: expected error %v, got %v", tt.option, tt.value, tt.wantErr, err)
+			continue
+		}
+		var optionErr *OptionError
+		if tt.wantErr && (!errors.As(err, &optionErr) || optionErr.Option != tt.option) {
+			t.Errorf("%s=%v: expected an error naming %s, got %v", tt.option, tt.value, tt.option, err)
+		}
+	}
+}
+
+func TestFilterChatOptionsMapsOllamaOptions(t *testing.T) {
+	tests := []struct {
+		option string
+		value  interface{}
+		key    string
+	}{
+		{"num_predict", 128.0, "max_tokens"},
+		{"num_ctx", 4096.0, "num_ctx"},
+		{"temperature", 0.3, "temperature"},
+		{"top_p", 0.9, "top_p"},
+		{"top_k", 40.0, "top_k"},
+		{"seed", 42.0, "seed"},
+		{"stop", []interface{}{"END"}, "stop"},
+		{"presence_penalty", 0.5, "presence_penalty"},
+		{"frequency_penalty", 0.2, "frequency_penalty"},
+	}
+	for _, tt := range tests {
+		opts := FilterChatOptions(map[string]interface{}{"options": map[string]interface{}{tt.option: tt.value}})
+		if len(opts) != 1 || !reflect.DeepEqual(opts[tt.key], tt.value) {
+			t.Errorf("Expected options.%s to map to %s=%v, got %v", tt.option, tt.key, tt.value, opts)
+		}
+		if err := ValidateChatOptions(opts); err != nil {
+			t.Errorf("Expected options.%s=%v to be valid, got %v", tt.option, tt.value, err)
+		}
+	}
+
+	// Ollama's negative num_predict means no limit, so no max_tokens is sent
+	if opts := FilterChatOptions(map[string]interface{}{"options": map[string]interface{}{"num_predict": -1.0}}); len(opts) != 0 {
+		t.Errorf("Expected a negative num_predict to be dropped, got %v", opts)
+	}
+	if err := ValidateChatOptions(map[string]interface{}{"num_ctx": 0.0}); err == nil {
+		t.Errorf("Expected num_ctx 0 to be rejected")
+	}
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// defaultPerplexityHost is used when no host is configured
+const defaultPerplexityHost = "https://api.perplexity.ai"
+
+// perplexityModels lists the Sonar models, as Perplexity has no model listing endpoint
+var perplexityModels = []string{"sonar", "sonar-pro", "sonar-reasoning", "sonar-reasoning-pro", "sonar-deep-research"}
+
+// PerplexityProvider handles interactions with the Perplexity API. Chat uses the OpenAI wire
+// format without the /v1 prefix; the sources of a search-grounded answer are returned as
+// citations, which the OpenAI implementation reports as ChatResult.Citations.
+type PerplexityProvider struct {
+	*OpenAIProvider
+}
+
+// NewPerplexityProvider creates a new instance of PerplexityProvider
+func NewPerplexityProvider(apiKey string, host string) *PerplexityProvider {
+	if host == "" {
+		host = defaultPerplexityHost
+	}
+	p := &PerplexityProvider{OpenAIProvider: NewOpenAIProvider(apiKey, host)}
+	p.endpoint = func(path, _ string) string {
+		return p.Host + path
+	}
+	return p
+}
+
+// GetModels lists the Sonar models
+func (p *PerplexityProvider) GetModels(ctx context.Context) ([]models.Model, error) {
+	modelList := make([]models.Model, 0, len(perplexityModels))
+	for _, modelID := range perplexityModels {
+		modelList = append(modelList, models.Model{
+			Name:     modelID,
+			ModelID:  modelID,
+			IsActive: true,
+		})
+	}
+	return modelList, nil
+}
+
+// Ping checks that the API is reachable and the key is accepted. Without a model listing to
+// query, an empty chat request is sent: it is rejected as invalid before any model runs, but
+// only after the key was checked.
+func (p *PerplexityProvider) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url("/chat/completions", ""), strings.NewReader("{}"))
+	if err != nil {
+		return err
+	}
+	setRequestIDHeader(req, p.requestID)
+	p.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	setCustomHeaders(req, p.headers)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ping timed out after %s", pingTimeout)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= http.StatusInternalServerError {
+		return newUpstreamError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestPerplexityProvider_ChatCapturesCitations(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"p1","object":"chat.completion","model":"sonar","citations":["https://go.dev/doc/go1.24","https://go.dev/blog/go1.24"],"search_results":[{"title":"Go 1.24 Release Notes","url":"https://go.dev/doc/go1.24"}],"choices":[{"index":0,"message":{"role":"assistant","content":"Go 1.24 was released in February 2025 [1][2]."},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":14,"total_tokens":23}}`))
+	}))
+	defer server.Close()
+
+	p := CreateProvider(&models.Provider{Name: "perplexity", APIKey: "pplx-key", Host: server.URL})
+	result, err := p.Chat(context.Background(), "sonar", []models.Message{{Role: "user", Content: "When was Go 1.24 released?"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if gotPath != "/chat/completions" || gotAuth != "Bearer pplx-key" {
+		t.Errorf("Unexpected upstream request: path %q, auth %q", gotPath, gotAuth)
+	}
+	want := []string{"https://go.dev/doc/go1.24", "https://go.dev/blog/go1.24"}
+	if !reflect.DeepEqual(result.Citations, want) {
+		t.Fatalf("Expected citations %v, got %v", want, result.Citations)
+	}
+
+	body, err := NewOpenAIResponseTransformer().TransformChatResponse(result, "sonar")
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	var openAIResp struct {
+		Citations []string `json:"citations"`
+	}
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !reflect.DeepEqual(openAIResp.Citations, want) {
+		t.Errorf("Expected citations in the OpenAI response, got %s", body)
+	}
+
+	body, err = NewOllamaResponseTransformer().TransformChatResponse(result, "sonar")
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	var ollamaResp struct {
+		Citations []string `json:"citations"`
+	}
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !reflect.DeepEqual(ollamaResp.Citations, want) {
+		t.Errorf("Expected citations in the Ollama response, got %s", body)
+	}
+}
+
+func TestPerplexityProvider_CitationsFromSearchResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"search_results":[{"title":"Go","url":"https://go.dev"},{"title":"No URL"}],"choices":[{"message":{"role":"assistant","content":"Go is a programming language [1]."},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	result, err := NewPerplexityProvider("key", server.URL).Chat(context.Background(), "sonar", []models.Message{{Role: "user", Content: "What is Go?"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Citations, []string{"https://go.dev"}) {
+		t.Errorf("Expected citations from the search results, got %v", result.Citations)
+	}
+}
+
+func TestPerplexityProviderDefaultHost(t *testing.T) {
+	if host := NewPerplexityProvider("key", "").Host; host != "https://api.perplexity.ai" {
+		t.Errorf("Expected default host, got %q", host)
+	}
+}
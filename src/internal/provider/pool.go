@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConnectionPool sizes the idle connections kept open to upstreams for reuse
+type ConnectionPool struct {
+	// MaxIdleConns caps the idle connections across all upstreams; zero means no limit
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the idle connections to each upstream host. Concurrent requests
+	// beyond it open connections that are closed instead of reused afterwards.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept; zero means no limit
+	IdleConnTimeout time.Duration
+}
+
+// Default connection pool size, used until SetConnectionPool is called
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+var (
+	poolMu         sync.RWMutex
+	connectionPool = ConnectionPool{
+		MaxIdleConns:        DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
+	}
+)
+
+// SetConnectionPool sets the connection pool size of providers created from now on
+func SetConnectionPool(pool ConnectionPool) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	connectionPool = pool
+}
+
+// currentConnectionPool returns the pool size set by SetConnectionPool
+func currentConnectionPool() ConnectionPool {
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+	return connectionPool
+}
+
+// applyConnectionPool sets the idle connection limits of a transport
+func applyConnectionPool(transport *http.Transport, pool ConnectionPool) {
+	transport.MaxIdleConns = pool.MaxIdleConns
+	transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = pool.IdleConnTimeout
+}
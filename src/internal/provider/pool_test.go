@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// newCountingServer starts an OpenAI-style chat server that counts the connections opened to it
+func newCountingServer(tb testing.TB) (*httptest.Server, *int64) {
+	var conns int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	server.Start()
+	tb.Cleanup(server.Close)
+	return server, &conns
+}
+
+func TestConcurrentRequestsReuseConnections(t *testing.T) {
+	server, conns := newCountingServer(t)
+
+	const workers, requests = 8, 25
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				// A provider is created per request, as the router does
+				p := CreateProvider(&models.Provider{Name: "openai", APIKey: "key", Host: server.URL})
+				if _, err := p.Chat(context.Background(), "gpt-4o", messages, nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Without pooling every request would open its own connection
+	if opened := atomic.LoadInt64(conns); opened > 2*workers {
+		t.Errorf("Expected about %d connections for %d requests, %d were opened", workers, workers*requests, opened)
+	}
+}
+
+func BenchmarkConcurrentChatConnectionReuse(b *testing.B) {
+	server, conns := newCountingServer(b)
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p := CreateProvider(&models.Provider{Name: "openai", APIKey: "key", Host: server.URL})
+			if _, err := p.Chat(context.Background(), "gpt-4o", messages, nil); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(atomic.LoadInt64(conns)), "conns")
+}
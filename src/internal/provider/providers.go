@@ -1,21 +1,190 @@
 // Package provider provides configurations for different AI providers.
 package provider
 
-import "os"
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
-// ProviderConfig defines the configuration for a provider.
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// ProviderConfig defines the configuration for a provider instance.
 type ProviderConfig struct {
 	Name         string
+	Type         string
 	Host         string
 	EnableEnvVar string
 	ApiKeyEnvVar string
+
+	// KeyRequired and HostRequired mark settings without which the provider cannot work
+	KeyRequired  bool
+	HostRequired bool
+
+	// MaxConcurrency caps concurrent upstream requests, from {PREFIX}_MAX_CONCURRENCY; zero means no cap
+	MaxConcurrency int
+	// Headers are custom headers sent with every upstream request, from {PREFIX}_HEADERS
+	Headers map[string]string
+	// DefaultMaxTokens is used when a client does not set max_tokens, from {PREFIX}_DEFAULT_MAX_TOKENS
+	DefaultMaxTokens int
+	// SystemPrompt and SystemPromptMode come from {PREFIX}_SYSTEM_PROMPT and {PREFIX}_SYSTEM_PROMPT_MODE
+	SystemPrompt     string
+	SystemPromptMode string
+	// Priority and Weight come from {PREFIX}_PRIORITY and {PREFIX}_WEIGHT
+	Priority int
+	Weight   int
+	// Proxy is the URL of the proxy for upstream requests, from {PREFIX}_PROXY
+	Proxy string
+	// TLS holds the CA bundle, client certificate and verification settings from {PREFIX}_TLS_*
+	TLS *models.ProviderTLS
+}
+
+// providerEnv names the environment variables that configure a built-in provider type
+type providerEnv struct {
+	Type         string
+	HostEnvVar   string
+	EnableEnvVar string
+	ApiKeyEnvVar string
+
+	KeyRequired  bool
+	HostRequired bool
+
+	// DefaultHost is used by the default instance when its host variable is unset
+	DefaultHost string
 }
 
-// GetProviderConfigs returns a list of provider configurations.
+// builtinProviderEnvs lists the environment variables of the built-in provider types
+var builtinProviderEnvs = []providerEnv{
+	{Type: "openai", HostEnvVar: "OPENAI_HOST", EnableEnvVar: "IS_OPENAI_ACTIVE", ApiKeyEnvVar: "OPENAI_API_KEY", KeyRequired: true},
+	{Type: "anthropic", HostEnvVar: "ANTHROPIC_HOST", EnableEnvVar: "IS_ANTHROPIC_ACTIVE", ApiKeyEnvVar: "ANTHROPIC_API_KEY", KeyRequired: true},
+	{Type: "ollama", HostEnvVar: "OLLAMA_HOST", EnableEnvVar: "IS_OLLAMA_ACTIVE", ApiKeyEnvVar: "OLLAMA_API_KEY", HostRequired: true, DefaultHost: defaultOllamaHost},
+	{Type: "mistral", HostEnvVar: "MISTRAL_HOST", EnableEnvVar: "IS_MISTRAL_ACTIVE", ApiKeyEnvVar: "MISTRAL_API_KEY", KeyRequired: true},
+	{Type: "deepseek", HostEnvVar: "DEEPSEEK_HOST", EnableEnvVar: "IS_DEEPSEEK_ACTIVE", ApiKeyEnvVar: "DEEPSEEK_API_KEY", KeyRequired: true},
+	{Type: "perplexity", HostEnvVar: "PERPLEXITY_HOST", EnableEnvVar: "IS_PERPLEXITY_ACTIVE", ApiKeyEnvVar: "PERPLEXITY_API_KEY", KeyRequired: true},
+	{Type: "azure", HostEnvVar: "AZURE_OPENAI_HOST", EnableEnvVar: "IS_AZURE_ACTIVE", ApiKeyEnvVar: "AZURE_OPENAI_API_KEY", KeyRequired: true, HostRequired: true},
+	// Bedrock signs requests with AWS credentials from the environment or ~/.aws/credentials
+	{Type: "bedrock", HostEnvVar: "BEDROCK_HOST", EnableEnvVar: "IS_BEDROCK_ACTIVE"},
+}
+
+// GetProviderConfigs returns a list of provider configurations. Besides the default
+// instance of each type, numbered instances such as IS_OPENAI_2_ACTIVE, OPENAI_2_HOST
+// and OPENAI_2_API_KEY are configured under names like "openai-2".
 func GetProviderConfigs() []ProviderConfig {
-	return []ProviderConfig{
-		{Name: "openai", Host: os.Getenv("OPENAI_HOST"), EnableEnvVar: "IS_OPENAI_ACTIVE", ApiKeyEnvVar: "OPENAI_API_KEY"},
-		{Name: "anthropic", Host: os.Getenv("ANTHROPIC_HOST"), EnableEnvVar: "IS_ANTHROPIC_ACTIVE", ApiKeyEnvVar: "ANTHROPIC_API_KEY"},
-		{Name: "ollama", Host: os.Getenv("OLLAMA_HOST"), EnableEnvVar: "IS_OLLAMA_ACTIVE", ApiKeyEnvVar: "OLLAMA_API_KEY"},
+	var configs []ProviderConfig
+	for _, env := range builtinProviderEnvs {
+		configs = append(configs, env.config(env.Type, 0))
+		for _, n := range instanceNumbers(env.EnableEnvVar) {
+			configs = append(configs, env.config(fmt.Sprintf("%s-%d", env.Type, n), n))
+		}
+	}
+	return append(configs, openAICompatibleConfigs()...)
+}
+
+// config builds the configuration of instance n of the type, where 0 is the default instance
+func (e providerEnv) config(name string, n int) ProviderConfig {
+	config := ProviderConfig{
+		Name:         name,
+		Type:         e.Type,
+		Host:         os.Getenv(numberedEnvVar(e.HostEnvVar, n)),
+		EnableEnvVar: numberedEnvVar(e.EnableEnvVar, n),
+		ApiKeyEnvVar: numberedEnvVar(e.ApiKeyEnvVar, n),
+		KeyRequired:  e.KeyRequired,
+		HostRequired: e.HostRequired,
+
+		MaxConcurrency: maxConcurrencyFromEnv(numberedEnvVar(e.EnableEnvVar, n)),
+		Headers:        headersFromEnv(numberedEnvVar(e.EnableEnvVar, n)),
+
+		DefaultMaxTokens: defaultMaxTokensFromEnv(numberedEnvVar(e.EnableEnvVar, n)),
+	}
+	if config.Host == "" && n == 0 {
+		config.Host = e.DefaultHost
+	}
+	config.SystemPrompt, config.SystemPromptMode = systemPromptFromEnv(config.EnableEnvVar)
+	config.Priority, config.Weight = priorityFromEnv(config.EnableEnvVar)
+	config.Proxy = proxyFromEnv(config.EnableEnvVar)
+	config.TLS = tlsFromEnv(config.EnableEnvVar)
+	return config
+}
+
+// Validate checks that the settings the provider needs are present and well formed,
+// so a misconfigured provider is reported at startup instead of failing on its first request
+func (c ProviderConfig) Validate() error {
+	var problems []string
+	if c.KeyRequired && os.Getenv(c.ApiKeyEnvVar) == "" {
+		problems = append(problems, c.ApiKeyEnvVar+" is not set")
+	}
+	if c.Host == "" {
+		if c.HostRequired {
+			problems = append(problems, "no host is configured")
+		}
+	} else if u, err := url.Parse(c.Host); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("host %q is not an http(s) URL", c.Host))
+	}
+	if c.Proxy != "" {
+		if _, err := ParseProxyURL(c.Proxy); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.TLS != nil {
+		if _, err := TLSConfig(c.TLS); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s provider is misconfigured: %s", c.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// priorityFromEnv reads {PREFIX}_PRIORITY and {PREFIX}_WEIGHT for the provider enabled by
+// enableEnvVar. Priority defaults to 0 and weight to 1.
+func priorityFromEnv(enableEnvVar string) (int, int) {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	priority, err := strconv.Atoi(os.Getenv(prefix + "_PRIORITY"))
+	if err != nil {
+		priority = 0
+	}
+	weight, err := strconv.Atoi(os.Getenv(prefix + "_WEIGHT"))
+	if err != nil || weight < 1 {
+		weight = 1
+	}
+	return priority, weight
+}
+
+// numberedEnvVar inserts an instance number before the variable's suffix,
+// e.g. OPENAI_API_KEY becomes OPENAI_2_API_KEY
+func numberedEnvVar(name string, n int) string {
+	if name == "" || n == 0 {
+		return name
+	}
+	for _, suffix := range []string{"_API_KEY", "_HOST", "_ACTIVE"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			return fmt.Sprintf("%s_%d%s", base, n, suffix)
+		}
+	}
+	return fmt.Sprintf("%s_%d", name, n)
+}
+
+// instanceNumbers returns, in order, the numbers N >= 2 whose numbered enable variable is set
+func instanceNumbers(enableEnvVar string) []int {
+	prefix := strings.TrimSuffix(enableEnvVar, "_ACTIVE") + "_"
+	var numbers []int
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		digits, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if digits, ok = strings.CutSuffix(digits, "_ACTIVE"); !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(digits); err == nil && n >= 2 && strconv.Itoa(n) == digits {
+			numbers = append(numbers, n)
+		}
 	}
+	sort.Ints(numbers)
+	return numbers
 }
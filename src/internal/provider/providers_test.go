@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetProviderConfigsNumberedInstances(t *testing.T) {
+	t.Setenv("IS_OPENAI_3_ACTIVE", "true")
+	t.Setenv("IS_OPENAI_2_ACTIVE", "true")
+	t.Setenv("OPENAI_2_HOST", "https://cheap.example.com")
+	t.Setenv("OPENAI_2_API_KEY", "key-2")
+	t.Setenv("IS_OPENAI_02_ACTIVE", "true")
+	t.Setenv("IS_AZURE_2_ACTIVE", "true")
+
+	byName := make(map[string]ProviderConfig)
+	var order []string
+	for _, config := range GetProviderConfigs() {
+		byName[config.Name] = config
+		order = append(order, config.Name)
+	}
+
+	second, ok := byName["openai-2"]
+	if !ok {
+		t.Fatalf("Expected an openai-2 instance, got %v", order)
+	}
+	if second.Type != "openai" || second.Host != "https://cheap.example.com" || second.EnableEnvVar != "IS_OPENAI_2_ACTIVE" || second.ApiKeyEnvVar != "OPENAI_2_API_KEY" {
+		t.Errorf("Unexpected openai-2 config: %+v", second)
+	}
+	if _, ok := byName["openai-3"]; !ok {
+		t.Errorf("Expected an openai-3 instance, got %v", order)
+	}
+	if _, ok := byName["openai-02"]; ok {
+		t.Errorf("Expected zero-padded numbers to be ignored, got %v", order)
+	}
+	if azure := byName["azure-2"]; azure.Type != "azure" || azure.ApiKeyEnvVar != "AZURE_OPENAI_2_API_KEY" {
+		t.Errorf("Unexpected azure-2 config: %+v", azure)
+	}
+	if order[0] != "openai" || order[1] != "openai-2" || order[2] != "openai-3" {
+		t.Errorf("Expected numbered instances to follow their default instance in order, got %v", order)
+	}
+	if byName["bedrock"].ApiKeyEnvVar != "" {
+		t.Errorf("Expected bedrock to have no API key variable, got %q", byName["bedrock"].ApiKeyEnvVar)
+	}
+}
+
+func TestProviderConfigValidate(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "key")
+	t.Setenv("OLLAMA_HOST", "")
+
+	configs := make(map[string]ProviderConfig)
+	for _, config := range GetProviderConfigs() {
+		configs[config.Name] = config
+	}
+
+	tests := []struct {
+		name    string
+		config  ProviderConfig
+		wantErr string
+	}{
+		{"missing key", configs["openai"], "OPENAI_API_KEY is not set"},
+		{"key set", configs["anthropic"], ""},
+		{"ollama without host", ProviderConfig{Name: "ollama", Type: "ollama", HostRequired: true}, "no host is configured"},
+		{"ollama with host", ProviderConfig{Name: "ollama", Type: "ollama", Host: "http://localhost:11434", HostRequired: true}, ""},
+		{"ollama defaults its host", configs["ollama"], ""},
+		{"malformed host", ProviderConfig{Name: "ollama", Type: "ollama", Host: "localhost:11434"}, "is not an http(s) URL"},
+		{"malformed proxy", ProviderConfig{Name: "bedrock", Type: "bedrock", Proxy: "proxy.internal:3128"}, "is not an http(s) or socks5 URL"},
+		{"bedrock needs no key", ProviderConfig{Name: "bedrock", Type: "bedrock"}, ""},
+	}
+
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestOllamaHostDefaultsToLocalhost(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "")
+	t.Setenv("IS_OLLAMA_2_ACTIVE", "true")
+
+	configs := make(map[string]ProviderConfig)
+	for _, config := range GetProviderConfigs() {
+		configs[config.Name] = config
+	}
+	if host := configs["ollama"].Host; host != "http://localhost:11434" {
+		t.Errorf("Expected the ollama config to default to http://localhost:11434, got %q", host)
+	}
+	if host := configs["ollama-2"].Host; host != "" {
+		t.Errorf("Expected numbered instances to need an explicit host, got %q", host)
+	}
+
+	if host := NewOllamaProvider("").Host; host != "http://localhost:11434" {
+		t.Errorf("Expected the ollama provider to default to http://localhost:11434, got %q", host)
+	}
+}
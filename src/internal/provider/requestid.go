@@ -0,0 +1,26 @@
+package provider
+
+import "net/http"
+
+// RequestIDHeader carries the gateway's correlation ID to upstream providers
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDSetter is implemented by providers that forward the correlation ID upstream
+type requestIDSetter interface {
+	SetRequestID(id string)
+}
+
+// WithRequestID tags the provider's upstream calls with a correlation ID when it supports one
+func WithRequestID(p ProviderInterface, id string) ProviderInterface {
+	if setter, ok := p.(requestIDSetter); ok && id != "" {
+		setter.SetRequestID(id)
+	}
+	return p
+}
+
+// setRequestIDHeader adds the correlation ID to an upstream request
+func setRequestIDHeader(req *http.Request, id string) {
+	if id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+// jsonModeInstruction is added to the system prompt of providers without a native JSON mode
+const jsonModeInstruction = "Respond only with a valid JSON object, without any surrounding text or code fences."
+
+// jsonSchemaProviderTypes lists the provider types that can enforce a json_schema response format
+var jsonSchemaProviderTypes = map[string]bool{
+	"openai":             true,
+	"azure":              true,
+	"mistral":            true,
+	"ollama":             true,
+	OpenAICompatibleType: true,
+}
+
+// responseFormatType returns the type of the response_format option, such as "json_object"
+// or "json_schema", or an empty string when none was requested
+func responseFormatType(opts map[string]interface{}) string {
+	format, _ := opts["response_format"].(map[string]interface{})
+	formatType, _ := format["type"].(string)
+	return formatType
+}
+
+// SupportsResponseFormat reports whether a provider type can honor the requested response
+// format. JSON mode works everywhere, natively or through a system prompt instruction, but
+// a JSON schema can only be enforced by providers with native support.
+func SupportsResponseFormat(providerType string, opts map[string]interface{}) bool {
+	return responseFormatType(opts) != "json_schema" || jsonSchemaProviderTypes[providerType]
+}
+
+// wantsJSONInstruction reports whether a provider without native JSON mode must be
+// instructed to answer in JSON
+func wantsJSONInstruction(opts map[string]interface{}) bool {
+	return responseFormatType(opts) == "json_object"
+}
+
+// withInstruction appends an instruction to a system prompt
+func withInstruction(system, instruction string) string {
+	if system == "" {
+		return instruction
+	}
+	return system + "\n\n" + instruction
+}
+
+// ollamaFormat maps the response_format option to Ollama's format field, which takes
+// "json" for JSON mode or the schema itself
+func ollamaFormat(opts map[string]interface{}) interface{} {
+	switch responseFormatType(opts) {
+	case "json_object":
+		return "json"
+	case "json_schema":
+		format, _ := opts["response_format"].(map[string]interface{})
+		jsonSchema, _ := format["json_schema"].(map[string]interface{})
+		if schema, ok := jsonSchema["schema"]; ok {
+			return schema
+		}
+		return "json"
+	default:
+		return nil
+	}
+}
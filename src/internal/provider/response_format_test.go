@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonSchemaFormat() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "answer",
+			"strict": true,
+			"schema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"answer": map[string]interface{}{"type": "string"}},
+			},
+		},
+	}
+}
+
+func TestOpenAIPayloadKeepsResponseFormat(t *testing.T) {
+	format := jsonSchemaFormat()
+	opts := FilterChatOptions(map[string]interface{}{"response_format": format})
+
+	payload := NewOpenAIProvider("key", "").buildChatPayload("gpt-4o", nil, opts, false)
+	if !reflect.DeepEqual(payload["response_format"], format) {
+		t.Errorf("Expected response_format to be forwarded unchanged, got %v", payload["response_format"])
+	}
+}
+
+func TestJSONModeWithoutNativeSupport(t *testing.T) {
+	opts := map[string]interface{}{"response_format": map[string]interface{}{"type": "json_object"}}
+
+	payload := NewAnthropicProvider("key", "").buildChatPayload("claude-3-haiku", nil, opts, false)
+	if payload["system"] != jsonModeInstruction {
+		t.Errorf("Expected the JSON instruction as Anthropic system prompt, got %q", payload["system"])
+	}
+	if _, ok := payload["response_format"]; ok {
+		t.Errorf("Expected response_format not to be sent to Anthropic")
+	}
+
+	titan := buildTitanPayload(nil, opts)
+	if !strings.HasPrefix(titan["inputText"].(string), jsonModeInstruction) {
+		t.Errorf("Expected the JSON instruction in the Titan prompt, got %q", titan["inputText"])
+	}
+}
+
+func TestOllamaFormat(t *testing.T) {
+	p := NewOllamaProvider("http://localhost:11434")
+
+	payload := p.buildChatPayload("llama3", nil, map[string]interface{}{"response_format": map[string]interface{}{"type": "json_object"}}, false)
+	if payload["format"] != "json" {
+		t.Errorf("Expected format json, got %v", payload["format"])
+	}
+
+	format := jsonSchemaFormat()
+	payload = p.buildChatPayload("llama3", nil, map[string]interface{}{"response_format": format}, false)
+	schema := format["json_schema"].(map[string]interface{})["schema"]
+	if !reflect.DeepEqual(payload["format"], schema) {
+		t.Errorf("Expected the schema as format, got %v", payload["format"])
+	}
+}
+
+func TestSupportsResponseFormat(t *testing.T) {
+	schema := map[string]interface{}{"response_format": jsonSchemaFormat()}
+	jsonMode := map[string]interface{}{"response_format": map[string]interface{}{"type": "json_object"}}
+
+	if !SupportsResponseFormat("openai", schema) || !SupportsResponseFormat("ollama", schema) {
+		t.Errorf("Expected OpenAI and Ollama to support json_schema")
+	}
+	if SupportsResponseFormat("anthropic", schema) || SupportsResponseFormat("bedrock", schema) {
+		t.Errorf("Expected Anthropic and Bedrock to reject json_schema")
+	}
+	if !SupportsResponseFormat("anthropic", jsonMode) || !SupportsResponseFormat("anthropic", nil) {
+		t.Errorf("Expected Anthropic to accept json_object and no format")
+	}
+}
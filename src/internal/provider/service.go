@@ -1,24 +1,63 @@
 package provider
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/offbeat-studio/allama/internal/models"
-	"github.com/offbeat-studio/allama/internal/storage"
 )
 
+// ErrEmbeddingsUnsupported is returned by providers that do not offer an embeddings API
+var ErrEmbeddingsUnsupported = errors.New("embeddings are not supported by this provider")
+
+// ChatResult holds the outcome of a non-streaming chat request
+type ChatResult struct {
+	Content      string
+	ToolCalls    []models.ToolCall
+	FinishReason string
+	Usage        *models.Usage
+
+	// SystemFingerprint identifies the backend configuration that served a seeded request
+	SystemFingerprint string
+	// Thinking is the model's reasoning, returned separately from Content by reasoning models
+	Thinking string
+	// Citations are the URLs of the sources a search-grounded answer is based on
+	Citations []string
+}
+
 // ProviderInterface defines the common interface for all provider implementations.
 type ProviderInterface interface {
-	GetModels() ([]models.Model, error)
-	Chat(modelID string, messages []map[string]string) (string, error)
+	GetModels(ctx context.Context) ([]models.Model, error)
+	Ping(ctx context.Context) error
+	Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error)
+	ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error
+	Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error)
+	Embeddings(ctx context.Context, modelID string, input string) ([]float64, error)
 }
 
 // ResponseTransformer defines the interface for transforming provider responses to Ollama format
 type ResponseTransformer interface {
-	TransformChatResponse(content string, modelID string) ([]byte, error)
-	TransformGenerateResponse(content string, modelID string) ([]byte, error)
+	TransformChatResponse(result *ChatResult, modelID string) ([]byte, error)
+	TransformGenerateResponse(result *ChatResult, modelID string) ([]byte, error)
+	TransformChatChunk(content string, modelID string, done bool) ([]byte, error)
+	TransformEmbeddingsResponse(embedding []float64) ([]byte, error)
+}
+
+// StreamTransformer transforms the deltas of a single stream to a client wire format.
+// TransformDelta is called for every delta and TransformFinal once, after the last one.
+type StreamTransformer interface {
+	// ContentType is the media type of the transformed stream
+	ContentType() string
+	// TransformDelta transforms a streamed delta to the bytes sent to the client
+	TransformDelta(content string) ([]byte, error)
+	// TransformFinal returns the chunk that ends the stream, summarized by timing
+	TransformFinal(timing *StreamTiming) ([]byte, error)
 }
 
 // OllamaResponseTransformer transforms responses to match Ollama's response formats
@@ -29,8 +68,74 @@ func NewOllamaResponseTransformer() *OllamaResponseTransformer {
 	return &OllamaResponseTransformer{}
 }
 
-// TransformChatResponse transforms a simple string response to Ollama's chat response format
-func (t *OllamaResponseTransformer) TransformChatResponse(content string, modelID string) ([]byte, error) {
+// TransformChatResponse transforms a chat result to Ollama's chat response format
+func (t *OllamaResponseTransformer) TransformChatResponse(result *ChatResult, modelID string) ([]byte, error) {
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": result.Content,
+	}
+	if len(result.ToolCalls) > 0 {
+		// Ollama expects tool call arguments as an object rather than a JSON string
+		toolCalls := make([]map[string]interface{}, 0, len(result.ToolCalls))
+		for _, call := range result.ToolCalls {
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"function": map[string]interface{}{
+					"name":      call.Function.Name,
+					"arguments": call.Function.ArgumentsObject(),
+				},
+			})
+		}
+		message["tool_calls"] = toolCalls
+	}
+	if result.Thinking != "" {
+		message["thinking"] = result.Thinking
+	}
+
+	response := map[string]interface{}{
+		"model":      modelID,
+		"created_at": time.Now().Format(time.RFC3339),
+		"message":    message,
+		"done":       true,
+	}
+	if len(result.Citations) > 0 {
+		response["citations"] = result.Citations
+	}
+	addOllamaUsage(response, result.Usage)
+
+	return json.Marshal(response)
+}
+
+// TransformGenerateResponse transforms a chat result to Ollama's generate response format
+func (t *OllamaResponseTransformer) TransformGenerateResponse(result *ChatResult, modelID string) ([]byte, error) {
+	response := map[string]interface{}{
+		"model":      modelID,
+		"created_at": time.Now().Format(time.RFC3339),
+		"response":   result.Content,
+		"done":       true,
+	}
+	if result.Thinking != "" {
+		response["thinking"] = result.Thinking
+	}
+	if len(result.Citations) > 0 {
+		response["citations"] = result.Citations
+	}
+	addOllamaUsage(response, result.Usage)
+
+	return json.Marshal(response)
+}
+
+// addOllamaUsage adds Ollama's token count fields to a response when usage is known
+func addOllamaUsage(response map[string]interface{}, usage *models.Usage) {
+	if usage == nil {
+		return
+	}
+	response["prompt_eval_count"] = usage.PromptTokens
+	response["eval_count"] = usage.CompletionTokens
+}
+
+// TransformChatChunk transforms a single streamed delta to an Ollama chat NDJSON line.
+// The final chunk of a stream should be sent with done set to true.
+func (t *OllamaResponseTransformer) TransformChatChunk(content string, modelID string, done bool) ([]byte, error) {
 	response := map[string]interface{}{
 		"model":      modelID,
 		"created_at": time.Now().Format(time.RFC3339),
@@ -38,63 +143,437 @@ func (t *OllamaResponseTransformer) TransformChatResponse(content string, modelI
 			"role":    "assistant",
 			"content": content,
 		},
-		"done": true,
+		"done": done,
+	}
+	if done {
+		response["done_reason"] = "stop"
 	}
 
-	return json.Marshal(response)
+	line, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
 }
 
-// TransformGenerateResponse transforms a simple string response to Ollama's generate response format
-func (t *OllamaResponseTransformer) TransformGenerateResponse(content string, modelID string) ([]byte, error) {
+// StreamTiming summarizes a finished stream for the timing fields of Ollama's final chunk
+type StreamTiming struct {
+	TotalDuration time.Duration
+	// EvalCount is the number of generated tokens the provider reported, or else the number of
+	// streamed chunks as an approximation
+	EvalCount int
+}
+
+// TransformGenerateChunk transforms a single streamed delta to an Ollama generate NDJSON line.
+// The final chunk of a stream is sent with timing set and carries Ollama's duration fields.
+func (t *OllamaResponseTransformer) TransformGenerateChunk(content string, modelID string, timing *StreamTiming) ([]byte, error) {
 	response := map[string]interface{}{
 		"model":      modelID,
 		"created_at": time.Now().Format(time.RFC3339),
 		"response":   content,
-		"done":       true,
+		"done":       timing != nil,
+	}
+	if timing != nil {
+		response["done_reason"] = "stop"
+		response["total_duration"] = timing.TotalDuration.Nanoseconds()
+		response["load_duration"] = 0
+		response["eval_count"] = timing.EvalCount
+		response["eval_duration"] = timing.TotalDuration.Nanoseconds()
+	}
+
+	line, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// TransformEmbeddingsResponse transforms an embedding vector to Ollama's embeddings response format
+func (t *OllamaResponseTransformer) TransformEmbeddingsResponse(embedding []float64) ([]byte, error) {
+	if embedding == nil {
+		embedding = []float64{}
+	}
+	response := map[string]interface{}{
+		"embedding": embedding,
+	}
+
+	return json.Marshal(response)
+}
+
+// TransformEmbedResponse transforms one embedding per input to the response format of
+// Ollama's /api/embed
+func (t *OllamaResponseTransformer) TransformEmbedResponse(embeddings [][]float64, modelID string) ([]byte, error) {
+	vectors := make([][]float64, len(embeddings))
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			embedding = []float64{}
+		}
+		vectors[i] = embedding
+	}
+	response := map[string]interface{}{
+		"model":      modelID,
+		"embeddings": vectors,
+	}
+
+	return json.Marshal(response)
+}
+
+// OpenAIResponseTransformer transforms responses to match OpenAI's response formats
+type OpenAIResponseTransformer struct{}
+
+// NewOpenAIResponseTransformer creates a new instance of OpenAIResponseTransformer
+func NewOpenAIResponseTransformer() *OpenAIResponseTransformer {
+	return &OpenAIResponseTransformer{}
+}
+
+// TransformChatResponse transforms a chat result to OpenAI's chat completion format
+func (t *OpenAIResponseTransformer) TransformChatResponse(result *ChatResult, modelID string) ([]byte, error) {
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": result.Content,
+	}
+	if len(result.ToolCalls) > 0 {
+		message["tool_calls"] = result.ToolCalls
+	}
+	if result.Thinking != "" {
+		// OpenAI clients of reasoning models expect DeepSeek's field name
+		message["reasoning_content"] = result.Thinking
+	}
+
+	finishReason := result.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	response := map[string]interface{}{
+		"id":      newResponseID("chatcmpl"),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   modelID,
+		"choices": []map[string]interface{}{
+			{
+				"index":         0,
+				"message":       message,
+				"finish_reason": finishReason,
+			},
+		},
+	}
+	if result.Usage != nil {
+		response["usage"] = result.Usage
+	}
+	if result.SystemFingerprint != "" {
+		response["system_fingerprint"] = result.SystemFingerprint
+	}
+	if len(result.Citations) > 0 {
+		response["citations"] = result.Citations
 	}
 
 	return json.Marshal(response)
 }
 
-// CreateProvider creates an instance of the appropriate provider based on the provider name.
+// TransformCompletionResponse transforms a chat result to OpenAI's text completion format
+func (t *OpenAIResponseTransformer) TransformCompletionResponse(result *ChatResult, modelID string) ([]byte, error) {
+	finishReason := result.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	response := map[string]interface{}{
+		"id":      newResponseID("cmpl"),
+		"object":  "text_completion",
+		"created": time.Now().Unix(),
+		"model":   modelID,
+		"choices": []map[string]interface{}{
+			{
+				"text":          result.Content,
+				"index":         0,
+				"logprobs":      nil,
+				"finish_reason": finishReason,
+			},
+		},
+	}
+	if result.Usage != nil {
+		response["usage"] = result.Usage
+	}
+	if result.SystemFingerprint != "" {
+		response["system_fingerprint"] = result.SystemFingerprint
+	}
+
+	return json.Marshal(response)
+}
+
+// OpenAIStreamTransformer transforms streamed deltas to OpenAI's chat.completion.chunk
+// server-sent events. Every chunk of a stream shares the same ID and creation time.
+type OpenAIStreamTransformer struct {
+	id      string
+	created int64
+	started bool
+}
+
+// NewOpenAIStreamTransformer creates a transformer for a single stream
+func NewOpenAIStreamTransformer() *OpenAIStreamTransformer {
+	return &OpenAIStreamTransformer{id: newResponseID("chatcmpl"), created: time.Now().Unix()}
+}
+
+// TransformChatChunk transforms a single streamed delta to a "data:" event. The first delta
+// carries the assistant role; the final chunk of a stream should be sent with done set to
+// true and is followed by the [DONE] sentinel.
+func (t *OpenAIStreamTransformer) TransformChatChunk(content string, modelID string, done bool) ([]byte, error) {
+	delta := map[string]interface{}{}
+	if !done {
+		delta["content"] = content
+		if !t.started {
+			delta["role"] = "assistant"
+			t.started = true
+		}
+	}
+	var finishReason interface{}
+	if done {
+		finishReason = "stop"
+	}
+
+	event, err := json.Marshal(map[string]interface{}{
+		"id":      t.id,
+		"object":  "chat.completion.chunk",
+		"created": t.created,
+		"model":   modelID,
+		"choices": []map[string]interface{}{
+			{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	line := append(append([]byte("data: "), event...), '\n', '\n')
+	if done {
+		line = append(line, "data: [DONE]\n\n"...)
+	}
+	return line, nil
+}
+
+// ollamaStreamTransformer frames a stream as Ollama NDJSON lines encoded by encode, which
+// receives timing only for the final line
+type ollamaStreamTransformer struct {
+	encode func(content string, timing *StreamTiming) ([]byte, error)
+}
+
+// NewOllamaChatStreamTransformer creates a transformer for a stream of Ollama chat chunks
+// reporting modelID
+func NewOllamaChatStreamTransformer(modelID string) StreamTransformer {
+	t := NewOllamaResponseTransformer()
+	return &ollamaStreamTransformer{encode: func(content string, timing *StreamTiming) ([]byte, error) {
+		return t.TransformChatChunk(content, modelID, timing != nil)
+	}}
+}
+
+// NewOllamaGenerateStreamTransformer creates a transformer for a stream of Ollama generate
+// chunks reporting modelID
+func NewOllamaGenerateStreamTransformer(modelID string) StreamTransformer {
+	t := NewOllamaResponseTransformer()
+	return &ollamaStreamTransformer{encode: func(content string, timing *StreamTiming) ([]byte, error) {
+		return t.TransformGenerateChunk(content, modelID, timing)
+	}}
+}
+
+func (t *ollamaStreamTransformer) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (t *ollamaStreamTransformer) TransformDelta(content string) ([]byte, error) {
+	return t.encode(content, nil)
+}
+
+func (t *ollamaStreamTransformer) TransformFinal(timing *StreamTiming) ([]byte, error) {
+	if timing == nil {
+		timing = &StreamTiming{}
+	}
+	return t.encode("", timing)
+}
+
+// openAIChatStreamTransformer frames a stream as OpenAI chat.completion.chunk events reporting modelID
+type openAIChatStreamTransformer struct {
+	*OpenAIStreamTransformer
+	modelID string
+}
+
+// NewOpenAIChatStreamTransformer creates a transformer for a stream of OpenAI chat chunk
+// events reporting modelID
+func NewOpenAIChatStreamTransformer(modelID string) StreamTransformer {
+	return &openAIChatStreamTransformer{OpenAIStreamTransformer: NewOpenAIStreamTransformer(), modelID: modelID}
+}
+
+func (t *openAIChatStreamTransformer) ContentType() string {
+	return "text/event-stream"
+}
+
+func (t *openAIChatStreamTransformer) TransformDelta(content string) ([]byte, error) {
+	return t.TransformChatChunk(content, t.modelID, false)
+}
+
+// TransformFinal returns the chunk carrying the finish reason, followed by the [DONE] sentinel
+func (t *openAIChatStreamTransformer) TransformFinal(timing *StreamTiming) ([]byte, error) {
+	return t.TransformChatChunk("", t.modelID, true)
+}
+
+// newResponseID generates a random identifier with the given prefix, e.g. "cmpl-1a2b..."
+func newResponseID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s-%s", prefix, hex.EncodeToString(b))
+}
+
+// CreateProvider creates an instance of the appropriate provider based on the provider type.
 func CreateProvider(prov *models.Provider) ProviderInterface {
-	switch prov.Name {
+	p := newProvider(prov)
+	if p == nil {
+		return nil
+	}
+	return WithTransport(WithHeaders(p, prov.Headers), prov)
+}
+
+// newProvider creates the provider implementation for the provider type
+func newProvider(prov *models.Provider) ProviderInterface {
+	switch prov.ProviderType() {
 	case "openai":
 		return NewOpenAIProvider(prov.APIKey, prov.Host)
 	case "anthropic":
 		return NewAnthropicProvider(prov.APIKey, prov.Host)
 	case "ollama":
 		return NewOllamaProvider(prov.Host)
+	case "azure":
+		return newAzureProviderFromEnv(prov)
+	case "mistral":
+		return NewMistralProvider(prov.APIKey, prov.Host)
+	case "deepseek":
+		return NewDeepSeekProvider(prov.APIKey, prov.Host)
+	case "perplexity":
+		return NewPerplexityProvider(prov.APIKey, prov.Host)
+	case "bedrock":
+		return NewBedrockProvider(bedrockRegionFromEnv(), prov.Host)
+	case OpenAICompatibleType:
+		return newOpenAICompatibleProviderFromEnv(prov)
 	default:
-		log.Printf("Unknown provider: %s, cannot create instance", prov.Name)
+		log.Printf("Unknown provider type: %s, cannot create instance of %s", prov.ProviderType(), prov.Name)
 		return nil
 	}
 }
 
-// FetchModelsForProvider fetches available models from the provider's API and adds them to the database.
-func FetchModelsForProvider(store *storage.Storage, prov *models.Provider) {
+// ModelStore is the subset of storage needed to persist fetched models
+type ModelStore interface {
+	GetModelsByProviderID(providerID int) ([]models.Model, error)
+	AddModel(model *models.Model) error
+	UpdateModelListed(id int, listed bool) error
+}
+
+// ModelSync summarizes how a refresh changed a provider's stored models. Added includes
+// models listed again after a previous refresh deactivated them.
+type ModelSync struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Kept    int `json:"kept"`
+}
+
+// FetchModelsForProvider fetches available models from the provider's API and syncs them into
+// the database: new models are added and active models the provider no longer lists are
+// deactivated as unlisted. Unlisted models that are listed again are reactivated, while other
+// listed models keep their stored active flag, so models disabled by an administrator stay
+// disabled.
+func FetchModelsForProvider(ctx context.Context, store ModelStore, prov *models.Provider) (ModelSync, error) {
+	var summary ModelSync
 	log.Printf("Fetching models for provider: %s", prov.Name)
 
 	providerImpl := CreateProvider(prov)
 	if providerImpl == nil {
 		log.Printf("Failed to create provider instance for: %s", prov.Name)
-		return
+		return summary, fmt.Errorf("unsupported provider type: %s", prov.ProviderType())
 	}
 
-	modelsToAdd, err := providerImpl.GetModels()
+	fetched, err := providerImpl.GetModels(ctx)
 	if err != nil {
 		log.Printf("Failed to fetch models for %s: %v", prov.Name, err)
-		return
+		return summary, err
+	}
+
+	storedModels, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil {
+		log.Printf("Failed to load stored models for %s: %v", prov.Name, err)
+		return summary, err
+	}
+	stored := make(map[string]models.Model, len(storedModels))
+	for _, model := range storedModels {
+		stored[model.ModelID] = model
 	}
 
-	// Add fetched models to the database
-	for _, model := range modelsToAdd {
+	// Add fetched models that are not stored yet
+	listed := make(map[string]bool, len(fetched))
+	for _, model := range fetched {
+		if listed[model.ModelID] {
+			continue
+		}
+		listed[model.ModelID] = true
+		if existing, ok := stored[model.ModelID]; ok {
+			if !existing.Unlisted {
+				summary.Kept++
+				continue
+			}
+			if err := store.UpdateModelListed(existing.ID, true); err != nil {
+				log.Printf("Failed to reactivate model %s for provider %s: %v", existing.Name, prov.Name, err)
+				continue
+			}
+			log.Printf("Reactivated model %s for provider %s: listed again", existing.Name, prov.Name)
+			summary.Added++
+			continue
+		}
 		model.ProviderID = prov.ID
-		err = store.AddModel(&model)
-		if err != nil {
+		if err := store.AddModel(&model); err != nil {
 			log.Printf("Failed to add model %s for provider %s: %v", model.Name, prov.Name, err)
-		} else {
-			log.Printf("Added model %s with ID: %d for provider %s", model.Name, model.ID, prov.Name)
+			continue
+		}
+		log.Printf("Added model %s with ID: %d for provider %s", model.Name, model.ID, prov.Name)
+		summary.Added++
+	}
+
+	// Deactivate stored models the provider no longer offers
+	for _, model := range storedModels {
+		if listed[model.ModelID] || !model.IsActive {
+			continue
+		}
+		if err := store.UpdateModelListed(model.ID, false); err != nil {
+			log.Printf("Failed to deactivate model %s for provider %s: %v", model.Name, prov.Name, err)
+			continue
+		}
+		log.Printf("Deactivated model %s for provider %s: no longer listed", model.Name, prov.Name)
+		summary.Removed++
+	}
+
+	return summary, nil
+}
+
+// FetchModelsWithRetry fetches the models of a provider like FetchModelsForProvider, giving each
+// attempt timeout to finish. A failed fetch is retried up to retries times, waiting backoff
+// before the first retry and twice as long before each next one. It gives up when ctx is done.
+func FetchModelsWithRetry(ctx context.Context, store ModelStore, prov *models.Provider, timeout time.Duration, retries int, backoff time.Duration) (ModelSync, error) {
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		summary, err := FetchModelsForProvider(attemptCtx, store, prov)
+		cancel()
+		if err == nil || attempt > retries {
+			return summary, err
+		}
+
+		log.Printf("Retrying model fetch for %s in %s (attempt %d of %d)", prov.Name, backoff, attempt+1, retries+1)
+		select {
+		case <-ctx.Done():
+			return summary, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff *= 2
 	}
 }
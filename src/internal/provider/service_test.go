@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestProviderConstructorsDefaultHost(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"openai default", NewOpenAIProvider("key", "").Host, "https://api.openai.com"},
+		{"openai custom", NewOpenAIProvider("key", "https://example.openai.azure.com/").Host, "https://example.openai.azure.com"},
+		{"anthropic default", NewAnthropicProvider("key", "").Host, "https://api.anthropic.com"},
+		{"anthropic custom", NewAnthropicProvider("key", "http://localhost:8081").Host, "http://localhost:8081"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected host %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestChatReturnsPromptlyWhenContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client goes away or the test finishes
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	providers := map[string]ProviderInterface{
+		"openai":    NewOpenAIProvider("key", server.URL),
+		"anthropic": NewAnthropicProvider("key", server.URL),
+		"ollama":    NewOllamaProvider(server.URL),
+	}
+	messages := []models.Message{{Role: "user", Content: "Hi"}}
+
+	for name, p := range providers {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := p.Chat(ctx, "model", messages, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected Chat to return promptly after cancel, took %s", name, elapsed)
+		}
+		cancel()
+	}
+}
+
+// memoryModelStore keeps models in memory for model sync tests
+type memoryModelStore struct {
+	models []models.Model
+}
+
+func (s *memoryModelStore) GetModelsByProviderID(providerID int) ([]models.Model, error) {
+	return s.models, nil
+}
+
+func (s *memoryModelStore) AddModel(model *models.Model) error {
+	model.ID = len(s.models) + 1
+	s.models = append(s.models, *model)
+	return nil
+}
+
+func (s *memoryModelStore) UpdateModelListed(id int, listed bool) error {
+	return nil
+}
+
+func TestFetchModelsWithRetry(t *testing.T) {
+	var calls, failures atomic.Int32
+	failures.Store(2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"models":[{"name":"llama3"}]}`)
+	}))
+	defer server.Close()
+
+	prov := &models.Provider{ID: 1, Name: "ollama", Host: server.URL, IsActive: true}
+	store := &memoryModelStore{}
+	summary, err := FetchModelsWithRetry(context.Background(), store, prov, time.Second, 2, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if summary.Added != 1 || len(store.models) != 1 || calls.Load() != 3 {
+		t.Errorf("Expected one model added after 3 attempts, got %+v after %d attempts", summary, calls.Load())
+	}
+
+	calls.Store(0)
+	failures.Store(10)
+	if _, err := FetchModelsWithRetry(context.Background(), &memoryModelStore{}, prov, time.Second, 1, time.Millisecond); err == nil {
+		t.Error("Expected an error once the retries are exhausted")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 attempts with 1 retry, got %d", calls.Load())
+	}
+}
+
+func TestFetchModelsWithRetryTimesOutEachAttempt(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	prov := &models.Provider{ID: 1, Name: "ollama", Host: server.URL, IsActive: true}
+	start := time.Now()
+	_, err := FetchModelsWithRetry(context.Background(), &memoryModelStore{}, prov, 50*time.Millisecond, 1, time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline exceeded error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected two short attempts, took %s", elapsed)
+	}
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// errStreamDone is returned by an event handler to stop reading a stream without error
+var errStreamDone = errors.New("stream done")
+
+// StreamChunk represents a single incremental piece of a streamed chat response
+type StreamChunk struct {
+	Content string
+	// Usage is set on a chunk without content when the provider reports the stream's token usage
+	Usage *models.Usage
+}
+
+// readSSE reads a server-sent events stream and invokes handle for every event.
+// The event name is empty when the upstream only sends data lines.
+func readSSE(r io.Reader, handle func(event, data string) error) error {
+	err := scanSSE(r, handle)
+	if err == errStreamDone {
+		return nil
+	}
+	return err
+}
+
+// scanSSE splits the stream into events and passes them to handle
+func scanSSE(r io.Reader, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line terminates the current event
+		if line == "" {
+			if len(data) > 0 {
+				if err := handle(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data = nil
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, ":"):
+			// Comment line, ignore
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Flush a trailing event that was not followed by a blank line
+	if len(data) > 0 {
+		return handle(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
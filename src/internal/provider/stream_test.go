@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestOpenAIProvider_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" world\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewOpenAIProvider("test-key", server.URL)
+	var deltas []string
+	err := p.ChatStream(context.Background(), "gpt-4o", []models.Message{{Role: "user", Content: "Hi"}}, nil, func(chunk StreamChunk) error {
+		deltas = append(deltas, chunk.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(deltas, ""); got != "Hello world" {
+		t.Errorf("Expected 'Hello world', got %q", got)
+	}
+}
+
+func TestAnthropicProvider_ChatStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider("test-key", server.URL)
+	var deltas []string
+	err := p.ChatStream(context.Background(), "claude-3-haiku", []models.Message{{Role: "user", Content: "Hi"}}, nil, func(chunk StreamChunk) error {
+		deltas = append(deltas, chunk.Content)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected overloaded_error, got %v", err)
+	}
+	if len(deltas) != 1 || deltas[0] != "Hi" {
+		t.Errorf("Expected a single 'Hi' delta before the error, got %v", deltas)
+	}
+}
+
+func TestAnthropicProvider_ChatStreamRecordedBody(t *testing.T) {
+	// A stream as recorded from the Messages API, including the events that carry no text
+	recorded := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-haiku-20240307","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":25,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"!"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":3}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, recorded)
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider("test-key", server.URL)
+	var deltas []string
+	var usage *models.Usage
+	err := p.ChatStream(context.Background(), "claude-3-haiku", []models.Message{{Role: "user", Content: "Hi"}}, nil, func(chunk StreamChunk) error {
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if chunk.Content != "" {
+			deltas = append(deltas, chunk.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(deltas, ""); got != "Hello!" || len(deltas) != 2 {
+		t.Errorf("Expected the deltas 'Hello' and '!', got %q", deltas)
+	}
+	if usage == nil || usage.PromptTokens != 25 || usage.CompletionTokens != 3 || usage.TotalTokens != 28 {
+		t.Errorf("Expected usage of 25 prompt and 3 completion tokens, got %+v", usage)
+	}
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// Ways of combining a provider's system prompt with the system messages a client sends
+const (
+	// SystemPromptPrepend places the provider's prompt before the client's system messages
+	SystemPromptPrepend = "prepend"
+	// SystemPromptAppend places the provider's prompt after the client's system messages
+	SystemPromptAppend = "append"
+	// SystemPromptOverride replaces the client's system messages with the provider's prompt
+	SystemPromptOverride = "override"
+)
+
+// ValidSystemPromptMode reports whether mode is a known merge mode; empty means prepend
+func ValidSystemPromptMode(mode string) bool {
+	switch mode {
+	case "", SystemPromptPrepend, SystemPromptAppend, SystemPromptOverride:
+		return true
+	default:
+		return false
+	}
+}
+
+// systemPromptFromEnv reads {PREFIX}_SYSTEM_PROMPT and {PREFIX}_SYSTEM_PROMPT_MODE for the
+// provider enabled by enableEnvVar, ignoring unknown modes
+func systemPromptFromEnv(enableEnvVar string) (string, string) {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv(prefix + "_SYSTEM_PROMPT_MODE")))
+	if !ValidSystemPromptMode(mode) {
+		mode = ""
+	}
+	return os.Getenv(prefix + "_SYSTEM_PROMPT"), mode
+}
+
+// MergeSystemPrompt combines the provider's prompt with the client's system prompts according to mode
+func MergeSystemPrompt(prompt, mode string, client []string) string {
+	var parts []string
+	switch mode {
+	case SystemPromptOverride:
+		parts = []string{prompt}
+	case SystemPromptAppend:
+		parts = append(append(parts, client...), prompt)
+	default:
+		parts = append([]string{prompt}, client...)
+	}
+
+	var nonEmpty []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// ApplySystemPrompt merges the provider's prompt with the system messages at the start of the
+// conversation into a single leading system message, which providers with a dedicated system
+// field send there. Override also drops system messages later in the conversation.
+func ApplySystemPrompt(messages []models.Message, prompt, mode string) []models.Message {
+	if prompt == "" {
+		return messages
+	}
+
+	var client []string
+	leading := 0
+	for leading < len(messages) && messages[leading].Role == "system" {
+		client = append(client, messages[leading].Content)
+		leading++
+	}
+
+	merged := []models.Message{{Role: "system", Content: MergeSystemPrompt(prompt, mode, client)}}
+	for _, msg := range messages[leading:] {
+		if mode == SystemPromptOverride && msg.Role == "system" {
+			continue
+		}
+		merged = append(merged, msg)
+	}
+	return merged
+}
+
+// WithSystemPrompt makes the provider apply a system prompt to every Chat, ChatStream and
+// Generate call. Completion endpoints have no system role, so with a prompt configured
+// Generate is served through chat. An empty prompt leaves the provider unchanged.
+func WithSystemPrompt(p ProviderInterface, prompt, mode string) ProviderInterface {
+	if prompt == "" {
+		return p
+	}
+	return &systemPromptProvider{ProviderInterface: p, prompt: prompt, mode: mode}
+}
+
+// systemPromptProvider wraps a provider with an enforced system prompt
+type systemPromptProvider struct {
+	ProviderInterface
+	prompt string
+	mode   string
+}
+
+func (p *systemPromptProvider) Chat(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}) (*ChatResult, error) {
+	return p.ProviderInterface.Chat(ctx, modelID, ApplySystemPrompt(messages, p.prompt, p.mode), opts)
+}
+
+func (p *systemPromptProvider) ChatStream(ctx context.Context, modelID string, messages []models.Message, opts map[string]interface{}, onChunk func(StreamChunk) error) error {
+	return p.ProviderInterface.ChatStream(ctx, modelID, ApplySystemPrompt(messages, p.prompt, p.mode), opts, onChunk)
+}
+
+func (p *systemPromptProvider) Generate(ctx context.Context, modelID string, prompt string, opts map[string]interface{}) (*ChatResult, error) {
+	return p.Chat(ctx, modelID, []models.Message{{Role: "user", Content: prompt}}, opts)
+}
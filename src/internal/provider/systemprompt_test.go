@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestApplySystemPrompt(t *testing.T) {
+	withClientSystem := []models.Message{
+		{Role: "system", Content: "Answer in French."},
+		{Role: "user", Content: "Hi"},
+		{Role: "system", Content: "Be brief."},
+	}
+	tests := []struct {
+		name     string
+		mode     string
+		messages []models.Message
+		want     []models.Message
+	}{
+		{"no client system message", SystemPromptPrepend, []models.Message{{Role: "user", Content: "Hi"}}, []models.Message{
+			{Role: "system", Content: "Be safe."},
+			{Role: "user", Content: "Hi"},
+		}},
+		{"prepend by default", "", withClientSystem, []models.Message{
+			{Role: "system", Content: "Be safe.\n\nAnswer in French."},
+			{Role: "user", Content: "Hi"},
+			{Role: "system", Content: "Be brief."},
+		}},
+		{"prepend", SystemPromptPrepend, withClientSystem, []models.Message{
+			{Role: "system", Content: "Be safe.\n\nAnswer in French."},
+			{Role: "user", Content: "Hi"},
+			{Role: "system", Content: "Be brief."},
+		}},
+		{"append", SystemPromptAppend, withClientSystem, []models.Message{
+			{Role: "system", Content: "Answer in French.\n\nBe safe."},
+			{Role: "user", Content: "Hi"},
+			{Role: "system", Content: "Be brief."},
+		}},
+		{"override", SystemPromptOverride, withClientSystem, []models.Message{
+			{Role: "system", Content: "Be safe."},
+			{Role: "user", Content: "Hi"},
+		}},
+	}
+	for _, tt := range tests {
+		got := ApplySystemPrompt(tt.messages, "Be safe.", tt.mode)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i].Role != tt.want[i].Role || got[i].Content != tt.want[i].Content {
+				t.Errorf("%s: message %d: expected %+v, got %+v", tt.name, i, tt.want[i], got[i])
+			}
+		}
+	}
+
+	if got := ApplySystemPrompt(withClientSystem, "", SystemPromptOverride); len(got) != len(withClientSystem) {
+		t.Errorf("Expected no change without a system prompt, got %v", got)
+	}
+}
+
+func TestSystemPromptUsesAnthropicSystemField(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hello"}]}`))
+	}))
+	defer server.Close()
+
+	p := WithSystemPrompt(NewAnthropicProvider("test-key", server.URL), "Be safe.", SystemPromptAppend)
+	messages := []models.Message{{Role: "system", Content: "Answer in French."}, {Role: "user", Content: "Hi"}}
+	if _, err := p.Chat(context.Background(), "claude-3-haiku", messages, nil); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if payload["system"] != "Answer in French.\n\nBe safe." {
+		t.Errorf("Expected the merged prompt in the system field, got %v", payload["system"])
+	}
+	if sent, _ := payload["messages"].([]interface{}); len(sent) != 1 {
+		t.Errorf("Expected only the user message in messages, got %v", payload["messages"])
+	}
+
+	// Generate has no system role upstream, so it is served through chat
+	if _, err := p.Generate(context.Background(), "claude-3-haiku", "Hi", nil); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if payload["system"] != "Be safe." {
+		t.Errorf("Expected the system prompt on generate requests, got %v", payload["system"])
+	}
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeouts bound the phases of upstream requests. A zero duration means no limit.
+type Timeouts struct {
+	// Connect bounds dialing the upstream, or its proxy, and the TLS handshake
+	Connect time.Duration
+	// Request bounds a whole non-streaming request, and how long a stream may wait for the
+	// response headers. Streams have no overall limit, so slow but steady ones can finish;
+	// they end when the client disconnects.
+	Request time.Duration
+}
+
+// Default upstream timeouts, used until SetTimeouts is called
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultRequestTimeout = 5 * time.Minute
+)
+
+var (
+	timeoutsMu       sync.RWMutex
+	upstreamTimeouts = Timeouts{Connect: DefaultConnectTimeout, Request: DefaultRequestTimeout}
+)
+
+// SetTimeouts sets the timeouts of providers created from now on
+func SetTimeouts(timeouts Timeouts) {
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+	upstreamTimeouts = timeouts
+}
+
+// currentTimeouts returns the timeouts set by SetTimeouts
+func currentTimeouts() Timeouts {
+	timeoutsMu.RLock()
+	defer timeoutsMu.RUnlock()
+	return upstreamTimeouts
+}
+
+// applyTimeouts sets the connect and response header timeouts of a transport
+func applyTimeouts(transport *http.Transport, timeouts Timeouts) {
+	dialer := &net.Dialer{Timeout: timeouts.Connect, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeouts.Connect
+	transport.ResponseHeaderTimeout = timeouts.Request
+}
+
+// newClients returns the clients of a provider: one for requests bounded by the request
+// timeout and one for streams, which only the transport's timeouts and the context bound.
+// Both share a transport, so SetTransport must replace it on both.
+func newClients() (client *http.Client, streamClient *http.Client) {
+	key := baseTransportKey()
+	// Without a proxy or TLS settings the transport cannot fail to build
+	transport, _ := providerTransport(key)
+	return &http.Client{Transport: transport, Timeout: key.timeouts.Request}, &http.Client{Transport: transport}
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestStreamsOutliveRequestTimeoutButNotStalls(t *testing.T) {
+	SetTimeouts(Timeouts{Connect: time.Second, Request: 200 * time.Millisecond})
+	defer SetTimeouts(Timeouts{Connect: DefaultConnectTimeout, Request: DefaultRequestTimeout})
+
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never sends a byte until the test is over
+		<-release
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// Five tokens 100ms apart take longer than the request timeout
+		for i := 0; i < 5; i++ {
+			time.Sleep(100 * time.Millisecond)
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"t%d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer slow.Close()
+
+	messages := []models.Message{{Role: "user", Content: "Count"}}
+	var content strings.Builder
+	err := NewOpenAIProvider("key", slow.URL).ChatStream(context.Background(), "gpt-4o", messages, nil, func(chunk StreamChunk) error {
+		content.WriteString(chunk.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the slow stream to finish, got %v", err)
+	}
+	if content.String() != "t0 t1 t2 t3 t4 " {
+		t.Errorf("Expected every token of the slow stream, got %q", content.String())
+	}
+
+	start := time.Now()
+	err = NewOpenAIProvider("key", stalled.URL).ChatStream(context.Background(), "gpt-4o", messages, nil, func(StreamChunk) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected a stream that never starts to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the stalled stream to fail after the request timeout, took %s", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := NewOpenAIProvider("key", stalled.URL).Chat(context.Background(), "gpt-4o", messages, nil); err == nil {
+		t.Fatal("Expected a request that never answers to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the stalled request to fail after the request timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// TLSConfig builds the client TLS configuration of a provider's TLS settings, loading its CA
+// bundle and client certificate
+func TLSConfig(settings *models.ProviderTLS) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+	if settings.CAFile != "" {
+		bundle, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", settings.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		if settings.CertFile == "" || settings.KeyFile == "" {
+			return nil, errors.New("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// tlsFromEnv reads {PREFIX}_TLS_CA_FILE, {PREFIX}_TLS_CERT_FILE, {PREFIX}_TLS_KEY_FILE and
+// {PREFIX}_TLS_INSECURE_SKIP_VERIFY for the provider enabled by enableEnvVar (IS_{PREFIX}_ACTIVE).
+// It returns nil when none is set.
+func tlsFromEnv(enableEnvVar string) *models.ProviderTLS {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	settings := models.ProviderTLS{
+		CAFile:   strings.TrimSpace(os.Getenv(prefix + "_TLS_CA_FILE")),
+		CertFile: strings.TrimSpace(os.Getenv(prefix + "_TLS_CERT_FILE")),
+		KeyFile:  strings.TrimSpace(os.Getenv(prefix + "_TLS_KEY_FILE")),
+	}
+	settings.InsecureSkipVerify, _ = strconv.ParseBool(os.Getenv(prefix + "_TLS_INSECURE_SKIP_VERIFY"))
+	if settings == (models.ProviderTLS{}) {
+		return nil
+	}
+	return &settings
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// weatherTools returns an OpenAI-style tool definition as decoded from a request body
+func weatherTools() []interface{} {
+	var tools []interface{}
+	json.Unmarshal([]byte(`[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}]`), &tools)
+	return tools
+}
+
+func TestOpenAIProvider_ForwardsTools(t *testing.T) {
+	p := NewOpenAIProvider("test-key", "https://api.openai.com")
+	opts := FilterChatOptions(map[string]interface{}{"tools": weatherTools(), "tool_choice": "auto"})
+
+	payload := p.buildChatPayload("gpt-4o", nil, opts, false)
+	if _, ok := payload["tools"].([]interface{}); !ok {
+		t.Errorf("Expected tools to be forwarded unchanged, got %v", payload["tools"])
+	}
+	if payload["tool_choice"] != "auto" {
+		t.Errorf("Expected tool_choice auto, got %v", payload["tool_choice"])
+	}
+}
+
+func TestAnthropicProvider_MapsTools(t *testing.T) {
+	p := NewAnthropicProvider("test-key", "https://api.anthropic.com")
+	opts := map[string]interface{}{"tools": weatherTools(), "tool_choice": "required"}
+	messages := []models.Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "toolu_1", Type: "function", Function: models.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "Sunny"},
+	}
+
+	payload := p.buildChatPayload("claude-3-haiku", messages, opts, false)
+
+	tools, ok := payload["tools"].([]map[string]interface{})
+	if !ok || len(tools) != 1 {
+		t.Fatalf("Expected one Anthropic tool, got %v", payload["tools"])
+	}
+	if tools[0]["name"] != "get_weather" || tools[0]["input_schema"] == nil {
+		t.Errorf("Expected name and input_schema to be mapped, got %v", tools[0])
+	}
+	choice, _ := payload["tool_choice"].(map[string]interface{})
+	if choice["type"] != "any" {
+		t.Errorf("Expected tool_choice type any, got %v", payload["tool_choice"])
+	}
+
+	converted := payload["messages"].([]map[string]interface{})
+	if len(converted) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(converted))
+	}
+	toolUse := converted[1]["content"].([]map[string]interface{})[0]
+	if toolUse["type"] != "tool_use" || toolUse["name"] != "get_weather" {
+		t.Errorf("Expected tool_use block, got %v", toolUse)
+	}
+	toolResult := converted[2]["content"].([]map[string]interface{})[0]
+	if converted[2]["role"] != "user" || toolResult["type"] != "tool_result" || toolResult["tool_use_id"] != "toolu_1" {
+		t.Errorf("Expected tool_result block in a user message, got %v", converted[2])
+	}
+}
+
+func TestAnthropicProvider_ChatReturnsToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Checking"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"stop_reason":"tool_use","usage":{"input_tokens":5,"output_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider("test-key", server.URL)
+	result, err := p.Chat(context.Background(), "claude-3-haiku", []models.Message{{Role: "user", Content: "Weather in Paris?"}}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.FinishReason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %s", result.FinishReason)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("Expected a get_weather tool call, got %+v", result.ToolCalls)
+	}
+	if result.ToolCalls[0].Function.ArgumentsObject()["city"] != "Paris" {
+		t.Errorf("Expected city argument Paris, got %s", result.ToolCalls[0].Function.Arguments)
+	}
+}
+
+func TestTransformersPreserveToolCalls(t *testing.T) {
+	result := &ChatResult{
+		ToolCalls:    []models.ToolCall{{ID: "call_1", Type: "function", Function: models.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+		FinishReason: "tool_calls",
+	}
+
+	openAIBytes, err := NewOpenAIResponseTransformer().TransformChatResponse(result, "gpt-4o")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var openAIResp struct {
+		Choices []struct {
+			Message struct {
+				ToolCalls []models.ToolCall `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(openAIBytes, &openAIResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if openAIResp.Choices[0].FinishReason != "tool_calls" || len(openAIResp.Choices[0].Message.ToolCalls) != 1 {
+		t.Errorf("Expected tool_calls in OpenAI response, got %s", openAIBytes)
+	}
+
+	ollamaBytes, err := NewOllamaResponseTransformer().TransformChatResponse(result, "gpt-4o")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var ollamaResp struct {
+		Message struct {
+			ToolCalls []struct {
+				Function struct {
+					Name      string                 `json:"name"`
+					Arguments map[string]interface{} `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(ollamaBytes, &ollamaResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(ollamaResp.Message.ToolCalls) != 1 || ollamaResp.Message.ToolCalls[0].Function.Arguments["city"] != "Paris" {
+		t.Errorf("Expected tool call arguments as an object in Ollama response, got %s", ollamaBytes)
+	}
+}
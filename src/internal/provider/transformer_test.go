@@ -2,8 +2,11 @@ package provider
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/offbeat-studio/allama/internal/models"
 )
 
 func TestOllamaResponseTransformer_TransformChatResponse(t *testing.T) {
@@ -11,7 +14,7 @@ func TestOllamaResponseTransformer_TransformChatResponse(t *testing.T) {
 	content := "Hello, how can I help you today?"
 	modelID := "gpt-3.5-turbo"
 
-	responseBytes, err := transformer.TransformChatResponse(content, modelID)
+	responseBytes, err := transformer.TransformChatResponse(&ChatResult{Content: content}, modelID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -62,7 +65,7 @@ func TestOllamaResponseTransformer_TransformGenerateResponse(t *testing.T) {
 	content := "This is a generated response."
 	modelID := "claude-3-sonnet"
 
-	responseBytes, err := transformer.TransformGenerateResponse(content, modelID)
+	responseBytes, err := transformer.TransformGenerateResponse(&ChatResult{Content: content}, modelID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -97,3 +100,383 @@ func TestOllamaResponseTransformer_TransformGenerateResponse(t *testing.T) {
 		t.Errorf("Expected created_at to be a valid RFC3339 timestamp, got %s", createdAt)
 	}
 }
+
+func TestOllamaResponseTransformer_TransformChatChunk(t *testing.T) {
+	transformer := NewOllamaResponseTransformer()
+	modelID := "gpt-3.5-turbo"
+
+	line, err := transformer.TransformChatChunk("Hel", modelID, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if line[len(line)-1] != '\n' {
+		t.Errorf("Expected chunk to be newline terminated, got %q", line)
+	}
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(line, &chunk); err != nil {
+		t.Fatalf("Failed to unmarshal chunk: %v", err)
+	}
+	if chunk["done"] != false {
+		t.Errorf("Expected done to be false, got %v", chunk["done"])
+	}
+	message, ok := chunk["message"].(map[string]interface{})
+	if !ok || message["content"] != "Hel" {
+		t.Errorf("Expected message content 'Hel', got %v", chunk["message"])
+	}
+
+	final, err := transformer.TransformChatChunk("", modelID, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var finalChunk map[string]interface{}
+	if err := json.Unmarshal(final, &finalChunk); err != nil {
+		t.Fatalf("Failed to unmarshal final chunk: %v", err)
+	}
+	if finalChunk["done"] != true {
+		t.Errorf("Expected final chunk done to be true, got %v", finalChunk["done"])
+	}
+}
+
+func TestOpenAIStreamTransformer_TransformChatChunk(t *testing.T) {
+	transformer := NewOpenAIStreamTransformer()
+
+	var events []map[string]interface{}
+	for _, delta := range []string{"Hel", "lo"} {
+		line, err := transformer.TransformChatChunk(delta, "gpt-4o", false)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.HasPrefix(string(line), "data: ") || !strings.HasSuffix(string(line), "\n\n") {
+			t.Fatalf("Expected a server-sent event, got %q", line)
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(line[len("data: "):], &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	first := events[0]["choices"].([]interface{})[0].(map[string]interface{})
+	if delta := first["delta"].(map[string]interface{}); delta["role"] != "assistant" || delta["content"] != "Hel" {
+		t.Errorf("Expected the first delta to carry the role and content, got %v", delta)
+	}
+	second := events[1]["choices"].([]interface{})[0].(map[string]interface{})
+	if delta := second["delta"].(map[string]interface{}); delta["role"] != nil || delta["content"] != "lo" {
+		t.Errorf("Expected later deltas to carry only content, got %v", delta)
+	}
+	if events[0]["id"] != events[1]["id"] || events[0]["object"] != "chat.completion.chunk" {
+		t.Errorf("Expected chunks sharing one ID, got %v and %v", events[0], events[1])
+	}
+
+	final, err := transformer.TransformChatChunk("", "gpt-4o", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasSuffix(string(final), "\n\ndata: [DONE]\n\n") {
+		t.Errorf("Expected the final chunk to be followed by [DONE], got %q", final)
+	}
+	if !strings.Contains(string(final), `"finish_reason":"stop"`) {
+		t.Errorf("Expected the final chunk to carry the finish reason, got %q", final)
+	}
+}
+
+func TestOllamaResponseTransformer_TransformGenerateChunk(t *testing.T) {
+	transformer := NewOllamaResponseTransformer()
+
+	line, err := transformer.TransformGenerateChunk("Hel", "claude-3-haiku", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(line, &chunk); err != nil {
+		t.Fatalf("Failed to unmarshal chunk: %v", err)
+	}
+	if chunk["response"] != "Hel" || chunk["done"] != false {
+		t.Errorf("Expected an incremental response chunk, got %v", chunk)
+	}
+	if _, ok := chunk["total_duration"]; ok {
+		t.Errorf("Expected no timing fields before the final chunk, got %v", chunk)
+	}
+
+	final, err := transformer.TransformGenerateChunk("", "claude-3-haiku", &StreamTiming{TotalDuration: 1500 * time.Millisecond, EvalCount: 3})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var finalChunk map[string]interface{}
+	if err := json.Unmarshal(final, &finalChunk); err != nil {
+		t.Fatalf("Failed to unmarshal final chunk: %v", err)
+	}
+	if finalChunk["done"] != true || finalChunk["done_reason"] != "stop" {
+		t.Errorf("Expected a final done chunk, got %v", finalChunk)
+	}
+	if finalChunk["total_duration"] != float64(1500000000) || finalChunk["eval_count"] != float64(3) {
+		t.Errorf("Expected timing fields on the final chunk, got %v", finalChunk)
+	}
+}
+
+func TestOllamaResponseTransformer_TransformEmbeddingsResponse(t *testing.T) {
+	transformer := NewOllamaResponseTransformer()
+
+	responseBytes, err := transformer.TransformEmbeddingsResponse([]float64{0.1, -0.2, 0.3})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(response.Embedding) != 3 || response.Embedding[1] != -0.2 {
+		t.Errorf("Expected embedding [0.1 -0.2 0.3], got %v", response.Embedding)
+	}
+}
+
+func TestOllamaResponseTransformer_TransformEmbedResponse(t *testing.T) {
+	transformer := NewOllamaResponseTransformer()
+
+	responseBytes, err := transformer.TransformEmbedResponse([][]float64{{0.1, 0.2}, {0.3, 0.4}}, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response struct {
+		Model      string      `json:"model"`
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Model != "text-embedding-3-small" {
+		t.Errorf("Expected model text-embedding-3-small, got %s", response.Model)
+	}
+	if len(response.Embeddings) != 2 || response.Embeddings[1][0] != 0.3 {
+		t.Errorf("Expected embeddings [[0.1 0.2] [0.3 0.4]], got %v", response.Embeddings)
+	}
+}
+
+func TestOpenAIResponseTransformer_TransformCompletionResponse(t *testing.T) {
+	transformer := NewOpenAIResponseTransformer()
+	content := "Once upon a time"
+	modelID := "gpt-4o"
+
+	responseBytes, err := transformer.TransformCompletionResponse(&ChatResult{Content: content}, modelID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Text         string `json:"text"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Object != "text_completion" {
+		t.Errorf("Expected object text_completion, got %s", response.Object)
+	}
+	if response.Model != modelID {
+		t.Errorf("Expected model %s, got %s", modelID, response.Model)
+	}
+	if len(response.ID) <= len("cmpl-") || response.ID[:5] != "cmpl-" {
+		t.Errorf("Expected id with cmpl- prefix, got %s", response.ID)
+	}
+	if len(response.Choices) != 1 || response.Choices[0].Text != content {
+		t.Fatalf("Expected a single choice with text %q, got %+v", content, response.Choices)
+	}
+	if response.Choices[0].FinishReason != "stop" {
+		t.Errorf("Expected finish_reason stop by default, got %s", response.Choices[0].FinishReason)
+	}
+
+	responseBytes, err = transformer.TransformCompletionResponse(&ChatResult{Content: content, FinishReason: "length"}, modelID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected finish_reason length, got %s", response.Choices[0].FinishReason)
+	}
+}
+
+func TestTransformersIncludeUsage(t *testing.T) {
+	result := &ChatResult{
+		Content: "Hi there",
+		Usage:   &models.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+	}
+
+	ollamaBytes, err := NewOllamaResponseTransformer().TransformChatResponse(result, "claude-3-haiku")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var ollamaResp map[string]interface{}
+	if err := json.Unmarshal(ollamaBytes, &ollamaResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if ollamaResp["prompt_eval_count"] != 12.0 || ollamaResp["eval_count"] != 3.0 {
+		t.Errorf("Expected prompt_eval_count 12 and eval_count 3, got %v and %v", ollamaResp["prompt_eval_count"], ollamaResp["eval_count"])
+	}
+
+	openAIBytes, err := NewOpenAIResponseTransformer().TransformChatResponse(result, "claude-3-haiku")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var openAIResp struct {
+		Object  string       `json:"object"`
+		Usage   models.Usage `json:"usage"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(openAIBytes, &openAIResp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if openAIResp.Object != "chat.completion" {
+		t.Errorf("Expected object chat.completion, got %s", openAIResp.Object)
+	}
+	if openAIResp.Usage != *result.Usage {
+		t.Errorf("Expected usage %+v, got %+v", *result.Usage, openAIResp.Usage)
+	}
+	if len(openAIResp.Choices) != 1 || openAIResp.Choices[0].Message.Content != "Hi there" {
+		t.Errorf("Unexpected choices: %+v", openAIResp.Choices)
+	}
+
+	// Usage is optional and omitted when the provider did not report it
+	noUsageBytes, _ := NewOllamaResponseTransformer().TransformChatResponse(&ChatResult{Content: "Hi"}, "llama3")
+	var noUsageResp map[string]interface{}
+	json.Unmarshal(noUsageBytes, &noUsageResp)
+	if _, ok := noUsageResp["eval_count"]; ok {
+		t.Errorf("Expected eval_count to be omitted without usage")
+	}
+}
+
+// transformStream feeds deltas through a stream transformer and returns everything it emitted
+func transformStream(t *testing.T, transformer StreamTransformer, deltas []string, timing *StreamTiming) string {
+	t.Helper()
+	var out strings.Builder
+	for _, delta := range deltas {
+		chunk, err := transformer.TransformDelta(delta)
+		if err != nil {
+			t.Fatalf("TransformDelta(%q) failed: %v", delta, err)
+		}
+		out.Write(chunk)
+	}
+	final, err := transformer.TransformFinal(timing)
+	if err != nil {
+		t.Fatalf("TransformFinal failed: %v", err)
+	}
+	out.Write(final)
+	return out.String()
+}
+
+func TestOllamaChatStreamTransformer(t *testing.T) {
+	transformer := NewOllamaChatStreamTransformer("claude-3-haiku")
+	if transformer.ContentType() != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON, got %s", transformer.ContentType())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(transformStream(t, transformer, []string{"Hel", "lo", "!"}, &StreamTiming{}), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected three deltas and a final line, got %q", lines)
+	}
+	for i, want := range []string{"Hel", "lo", "!", ""} {
+		var chunk struct {
+			Model   string `json:"model"`
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			Done       bool   `json:"done"`
+			DoneReason string `json:"done_reason"`
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &chunk); err != nil {
+			t.Fatalf("Line %d is not JSON: %q", i, lines[i])
+		}
+		final := i == 3
+		if chunk.Model != "claude-3-haiku" || chunk.Message.Role != "assistant" || chunk.Message.Content != want || chunk.Done != final {
+			t.Errorf("Line %d: expected content %q and done %v, got %+v", i, want, final, chunk)
+		}
+		if final && chunk.DoneReason != "stop" {
+			t.Errorf("Expected the final line to carry the done reason, got %+v", chunk)
+		}
+	}
+}
+
+func TestOllamaGenerateStreamTransformer(t *testing.T) {
+	transformer := NewOllamaGenerateStreamTransformer("claude-3-haiku")
+	lines := strings.Split(strings.TrimSuffix(transformStream(t, transformer, []string{"Hel", "lo"}, &StreamTiming{TotalDuration: time.Second, EvalCount: 2}), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected two deltas and a final line, got %q", lines)
+	}
+	for i, want := range []string{"Hel", "lo", ""} {
+		var chunk map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &chunk); err != nil {
+			t.Fatalf("Line %d is not JSON: %q", i, lines[i])
+		}
+		final := i == 2
+		if chunk["response"] != want || chunk["done"] != final {
+			t.Errorf("Line %d: expected response %q and done %v, got %v", i, want, final, chunk)
+		}
+		if _, timed := chunk["eval_count"]; timed != final {
+			t.Errorf("Line %d: expected timing fields only on the final line, got %v", i, chunk)
+		}
+	}
+}
+
+func TestOpenAIChatStreamTransformer(t *testing.T) {
+	transformer := NewOpenAIChatStreamTransformer("gpt-4o")
+	if transformer.ContentType() != "text/event-stream" {
+		t.Errorf("Expected server-sent events, got %s", transformer.ContentType())
+	}
+
+	stream := transformStream(t, transformer, []string{"Hel", "lo"}, nil)
+	events := strings.Split(strings.TrimSuffix(stream, "\n\n"), "\n\n")
+	if len(events) != 4 || events[3] != "data: [DONE]" {
+		t.Fatalf("Expected two deltas, a final chunk and [DONE], got %q", events)
+	}
+
+	var ids []string
+	for i, want := range []struct {
+		role, content string
+		finish        interface{}
+	}{{"assistant", "Hel", nil}, {"", "lo", nil}, {"", "", "stop"}} {
+		var event struct {
+			ID      string `json:"id"`
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Role    string `json:"role"`
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason interface{} `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if !strings.HasPrefix(events[i], "data: ") {
+			t.Fatalf("Event %d is not a data event: %q", i, events[i])
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(events[i], "data: ")), &event); err != nil {
+			t.Fatalf("Event %d is not JSON: %q", i, events[i])
+		}
+		choice := event.Choices[0]
+		if event.Model != "gpt-4o" || choice.Delta.Role != want.role || choice.Delta.Content != want.content || choice.FinishReason != want.finish {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want, event)
+		}
+		ids = append(ids, event.ID)
+	}
+	if ids[0] == "" || ids[0] != ids[1] || ids[1] != ids[2] {
+		t.Errorf("Expected every chunk to share one ID, got %v", ids)
+	}
+}
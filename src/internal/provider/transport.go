@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// transportSetter is implemented by providers whose upstream requests can use a custom transport
+type transportSetter interface {
+	SetTransport(transport http.RoundTripper)
+}
+
+// transportKey identifies the transport of a proxy URL, TLS settings, timeouts and pool size
+type transportKey struct {
+	proxyURL string
+	tls      models.ProviderTLS
+	timeouts Timeouts
+	pool     ConnectionPool
+}
+
+// transports shares one transport, and with it one connection pool, per proxy URL, TLS settings,
+// timeouts and pool size. Provider instances created for every request, and instances of
+// different providers on the same host, thus reuse each other's connections.
+var transports sync.Map
+
+// baseTransportKey returns the key of the transport used without a proxy or TLS settings
+func baseTransportKey() transportKey {
+	return transportKey{timeouts: currentTimeouts(), pool: currentConnectionPool()}
+}
+
+// WithTransport sends the provider's upstream requests through prov's proxy and checks the
+// upstream certificate with prov's TLS settings, when the provider supports it. Without a proxy
+// the one from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables is used. When the
+// settings cannot be used, e.g. because a certificate file is missing, every request fails with
+// the reason instead of silently going out without them.
+func WithTransport(p ProviderInterface, prov *models.Provider) ProviderInterface {
+	key := baseTransportKey()
+	key.proxyURL = prov.Proxy
+	if prov.TLS != nil {
+		key.tls = *prov.TLS
+	}
+	if key.proxyURL == "" && key.tls == (models.ProviderTLS{}) {
+		return p
+	}
+	setter, ok := p.(transportSetter)
+	if !ok {
+		return p
+	}
+	transport, err := providerTransport(key)
+	if err != nil {
+		log.Printf("Failing requests to %s: %v", prov.Name, err)
+		transport = failingTransport{err: err}
+	}
+	setter.SetTransport(transport)
+	return p
+}
+
+// providerTransport returns a transport like http.DefaultTransport with the proxy, TLS settings,
+// timeouts and pool size of key. Transports that cannot be built are not cached, so fixed files are picked up.
+func providerTransport(key transportKey) (http.RoundTripper, error) {
+	if transport, ok := transports.Load(key); ok {
+		return transport.(http.RoundTripper), nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	applyTimeouts(transport, key.timeouts)
+	applyConnectionPool(transport, key.pool)
+	if key.proxyURL != "" {
+		u, err := ParseProxyURL(key.proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if key.tls != (models.ProviderTLS{}) {
+		tlsConfig, err := TLSConfig(&key.tls)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	actual, _ := transports.LoadOrStore(key, transport)
+	return actual.(http.RoundTripper), nil
+}
+
+// failingTransport fails every request with err
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// ParseProxyURL parses a proxy URL, which must be an http, https or socks5 URL with a host
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+		return nil, fmt.Errorf("proxy %q is not an http(s) or socks5 URL", RedactProxyURL(proxyURL))
+	}
+	return u, nil
+}
+
+// RedactProxyURL hides the password of a proxy URL so it can be logged or shown
+func RedactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return "(invalid)"
+	}
+	return u.Redacted()
+}
+
+// proxyFromEnv reads {PREFIX}_PROXY for the provider enabled by enableEnvVar (IS_{PREFIX}_ACTIVE)
+func proxyFromEnv(enableEnvVar string) string {
+	prefix := strings.TrimSuffix(strings.TrimPrefix(enableEnvVar, "IS_"), "_ACTIVE")
+	return strings.TrimSpace(os.Getenv(prefix + "_PROXY"))
+}
@@ -0,0 +1,231 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/storage"
+)
+
+// listAliases returns every model alias
+func (r *Router) listAliases(c *gin.Context) {
+	aliases, err := r.store.GetAliases()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve aliases")
+		return
+	}
+	if aliases == nil {
+		aliases = []models.Alias{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": aliases})
+}
+
+// upsertAlias creates an alias or replaces the target of an existing one
+func (r *Router) upsertAlias(c *gin.Context) {
+	var requestBody struct {
+		Alias    string `json:"alias" binding:"required"`
+		ModelID  string `json:"model_id" binding:"required"`
+		Provider string `json:"provider"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if requestBody.Provider != "" {
+		prov, err := r.store.GetProviderByName(requestBody.Provider)
+		if err != nil {
+			middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+			return
+		}
+		if prov == nil {
+			middleware.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Unknown provider %s", requestBody.Provider))
+			return
+		}
+	}
+
+	alias := &models.Alias{
+		Alias:    requestBody.Alias,
+		ModelID:  requestBody.ModelID,
+		Provider: requestBody.Provider,
+	}
+	if err := r.store.UpsertAlias(alias); err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to save alias")
+		return
+	}
+	c.JSON(http.StatusOK, alias)
+}
+
+// deleteAlias removes an alias; the name may contain slashes
+func (r *Router) deleteAlias(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("alias"), "/")
+	if err := r.store.DeleteAlias(name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			middleware.RespondError(c, http.StatusNotFound, "Alias not found")
+			return
+		}
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to delete alias")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// resolveModel applies any alias for the requested model. It returns the model ID to send
+// upstream and the active providers to try, in order; no providers means the model is unsupported.
+// With load balancing enabled the first provider is chosen among those of the highest priority.
+// A model no provider serves may be addressed as provider/model to route it to that provider,
+// and is otherwise routed to the configured default, if any.
+func (r *Router) resolveModel(requested string) (string, []*models.Provider, error) {
+	modelID, candidates, unknown, err := r.lookupModel(requested)
+	if err != nil || !unknown {
+		return modelID, candidates, err
+	}
+	return r.defaultRoute(modelID)
+}
+
+// lookupModel resolves the requested model like resolveModel, without the default route.
+// unknown reports that neither an alias nor a provider serves the model, so it may be routed
+// to a default instead.
+func (r *Router) lookupModel(requested string) (modelID string, candidates []*models.Provider, unknown bool, err error) {
+	modelID = requested
+	alias, err := r.store.GetAlias(requested)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if alias != nil {
+		modelID = alias.ModelID
+		if alias.Provider != "" {
+			prov, err := r.store.GetProviderByName(alias.Provider)
+			if err != nil {
+				return "", nil, false, err
+			}
+			if prov == nil || !prov.IsActive {
+				return modelID, nil, false, nil
+			}
+			return modelID, []*models.Provider{prov}, false, nil
+		}
+	}
+
+	candidates, err = r.store.GetProvidersForModel(modelID)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if len(candidates) == 0 {
+		prov, prefixedID, err := r.prefixedProvider(modelID)
+		if err != nil {
+			return "", nil, false, err
+		}
+		if prov != nil {
+			return prefixedID, []*models.Provider{prov}, false, nil
+		}
+		return modelID, nil, true, nil
+	}
+	return modelID, r.balancer.order(modelID, candidates), false, nil
+}
+
+// routeCandidates returns the model ID and the providers resolveModel picks for the requested
+// model, responding with an error when there are none
+func (r *Router) routeCandidates(c *gin.Context, handler string, requested string) (string, []*models.Provider, bool) {
+	modelID, candidates, err := r.resolveModel(requested)
+	if err != nil {
+		fmt.Printf("%s: provider lookup failed: %v\n", handler, err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Provider not found")
+		return "", nil, false
+	}
+	if len(candidates) == 0 {
+		fmt.Printf("%s: unsupported model\n", handler)
+		respondModelNotFound(c, requested)
+		return "", nil, false
+	}
+	return modelID, candidates, true
+}
+
+// primaryRoute returns the first provider routeCandidates picks for the requested model and
+// the model ID to send it
+func (r *Router) primaryRoute(c *gin.Context, handler string, requested string) (*models.Provider, string, bool) {
+	modelID, candidates, ok := r.routeCandidates(c, handler, requested)
+	if !ok {
+		return nil, "", false
+	}
+	return candidates[0], modelID, true
+}
+
+// defaultRoute routes a model no provider serves to the configured default. DEFAULT_PROVIDER
+// receives it under its own name, or as DEFAULT_MODEL when that is set too; DEFAULT_MODEL
+// alone is routed to the providers serving it. Without either, or when the default provider is
+// not active, there is no route and the model is reported as not found.
+func (r *Router) defaultRoute(modelID string) (string, []*models.Provider, error) {
+	if r.cfg.DefaultProvider == "" && r.cfg.DefaultModel == "" {
+		return modelID, nil, nil
+	}
+	upstreamID := modelID
+	if r.cfg.DefaultModel != "" {
+		upstreamID = r.cfg.DefaultModel
+	}
+
+	var candidates []*models.Provider
+	if r.cfg.DefaultProvider != "" {
+		prov, err := r.store.GetProviderByName(r.cfg.DefaultProvider)
+		if err != nil {
+			return "", nil, err
+		}
+		if prov == nil || !prov.IsActive {
+			fmt.Printf("defaultRoute: default provider %s is not active\n", r.cfg.DefaultProvider)
+			return modelID, nil, nil
+		}
+		candidates = []*models.Provider{prov}
+	} else {
+		served, err := r.store.GetProvidersForModel(upstreamID)
+		if err != nil {
+			return "", nil, err
+		}
+		candidates = r.balancer.order(upstreamID, served)
+	}
+	if len(candidates) == 0 {
+		fmt.Printf("defaultRoute: no provider serves default model %s\n", upstreamID)
+		return modelID, nil, nil
+	}
+	fmt.Printf("defaultRoute: routing unknown model %s to %s as %s\n", modelID, candidates[0].Name, upstreamID)
+	return upstreamID, candidates, nil
+}
+
+// prefixedProvider splits a provider/model name into the active provider named by the prefix
+// and the model ID after it. The provider is nil when the prefix names no active provider.
+func (r *Router) prefixedProvider(modelID string) (*models.Provider, string, error) {
+	name, prefixedID, ok := strings.Cut(modelID, "/")
+	if !ok || name == "" || prefixedID == "" {
+		return nil, "", nil
+	}
+	prov, err := r.store.GetProviderByName(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if prov == nil || !prov.IsActive {
+		return nil, "", nil
+	}
+	return prov, prefixedID, nil
+}
+
+// withModel rewrites the model field of a raw JSON request body, leaving it untouched
+// when the model is unchanged or the body cannot be parsed
+func withModel(body []byte, requested, modelID string) []byte {
+	if requested == modelID {
+		return body
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	payload["model"] = modelID
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
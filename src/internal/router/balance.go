@@ -0,0 +1,99 @@
+package router
+
+import (
+	"math/rand/v2"
+	"sort"
+	"sync"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// Load balancing strategies for providers of equal priority serving the same model
+const (
+	// balanceOff always tries providers in priority order, then in the order they were configured
+	balanceOff = ""
+	// balanceWeighted picks the first provider at random, in proportion to the weights
+	balanceWeighted = "weighted"
+	// balanceRoundRobin cycles through the providers, giving each as many turns as its weight
+	balanceRoundRobin = "round_robin"
+)
+
+// validBalanceStrategy reports whether strategy is a known load balancing strategy
+func validBalanceStrategy(strategy string) bool {
+	switch strategy {
+	case balanceOff, balanceWeighted, balanceRoundRobin:
+		return true
+	default:
+		return false
+	}
+}
+
+// balancer chooses which of the highest priority providers of a model is tried first
+type balancer struct {
+	strategy string
+	// intn returns a random number in [0, n) for weighted selection
+	intn func(n int) int
+
+	mu sync.Mutex
+	// turns counts the round-robin requests per model
+	turns map[string]int
+}
+
+// newBalancer creates a balancer using strategy
+func newBalancer(strategy string) *balancer {
+	return &balancer{strategy: strategy, intn: rand.IntN, turns: make(map[string]int)}
+}
+
+// providerWeight returns the weight of a provider, which is at least 1
+func providerWeight(p *models.Provider) int {
+	if p.Weight < 1 {
+		return 1
+	}
+	return p.Weight
+}
+
+// order returns the candidates with the chosen provider first, followed by the other providers
+// of the highest priority and then the lower priorities as fallbacks
+func (b *balancer) order(modelID string, candidates []*models.Provider) []*models.Provider {
+	if b.strategy == balanceOff || len(candidates) < 2 {
+		return candidates
+	}
+
+	sorted := append([]*models.Provider(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+	top := 1
+	for top < len(sorted) && sorted[top].Priority == sorted[0].Priority {
+		top++
+	}
+	if top == 1 {
+		return sorted
+	}
+
+	total := 0
+	for _, p := range sorted[:top] {
+		total += providerWeight(p)
+	}
+	var turn int
+	if b.strategy == balanceRoundRobin {
+		b.mu.Lock()
+		turn = b.turns[modelID] % total
+		b.turns[modelID]++
+		b.mu.Unlock()
+	} else {
+		turn = b.intn(total)
+	}
+
+	chosen := 0
+	for i, p := range sorted[:top] {
+		if turn < providerWeight(p) {
+			chosen = i
+			break
+		}
+		turn -= providerWeight(p)
+	}
+
+	ordered := make([]*models.Provider, 0, len(sorted))
+	ordered = append(ordered, sorted[chosen])
+	ordered = append(ordered, sorted[:chosen]...)
+	return append(ordered, sorted[chosen+1:]...)
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// modelCatalog caches the live model lists of providers so model listings do not query every
+// provider on every request. A zero TTL disables the cache.
+type modelCatalog struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[int]*catalogEntry
+}
+
+// catalogEntry is the cached live model list of a provider
+type catalogEntry struct {
+	models    []models.Model
+	fetchedAt time.Time
+	// refreshing is set while a background refresh is running
+	refreshing bool
+}
+
+// newModelCatalog creates a catalog caching model lists for ttl
+func newModelCatalog(ttl time.Duration) *modelCatalog {
+	return &modelCatalog{ttl: ttl, entries: make(map[int]*catalogEntry)}
+}
+
+// fetch returns the live models of the provider with the given ID. A cached list is served
+// while it is younger than the TTL; an older one is still served but refreshed in the
+// background. Without a cached list, or with refresh set, get is called directly and each
+// call gets timeout to answer. Failed fetches are not cached.
+func (m *modelCatalog) fetch(ctx context.Context, providerID int, timeout time.Duration, refresh bool, get func(ctx context.Context) ([]models.Model, error)) ([]models.Model, error) {
+	if m.ttl > 0 && !refresh {
+		m.mu.Lock()
+		entry, ok := m.entries[providerID]
+		if ok {
+			if time.Since(entry.fetchedAt) >= m.ttl && !entry.refreshing {
+				entry.refreshing = true
+				go m.refresh(providerID, entry, timeout, get)
+			}
+			m.mu.Unlock()
+			return entry.models, nil
+		}
+		m.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	live, err := get(ctx)
+	if err == nil {
+		m.store(providerID, live)
+	}
+	return live, err
+}
+
+// refresh re-fetches a stale entry in the background. The request that noticed the entry is
+// stale may already be finished, so the fetch does not use its context.
+func (m *modelCatalog) refresh(providerID int, entry *catalogEntry, timeout time.Duration, get func(ctx context.Context) ([]models.Model, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	live, err := get(ctx)
+
+	m.mu.Lock()
+	entry.refreshing = false
+	m.mu.Unlock()
+	if err != nil {
+		// The stale list keeps being served and the next listing tries again
+		return
+	}
+	m.store(providerID, live)
+}
+
+// store caches the live models of a provider
+func (m *modelCatalog) store(providerID int, live []models.Model) {
+	if m.ttl <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[providerID] = &catalogEntry{models: live, fetchedAt: time.Now()}
+}
+
+// invalidate drops the cached models of a provider, e.g. after its settings changed
+func (m *modelCatalog) invalidate(providerID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, providerID)
+}
+
+// wantsRefresh reports whether the refresh query parameter asks for live model lists
+func wantsRefresh(c *gin.Context) bool {
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	return refresh
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// flightGroup coalesces identical concurrent upstream requests: while one is running, the
+// same request from other clients waits for it and shares its result instead of reaching the
+// provider again
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[[sha256.Size]byte]*flight
+}
+
+// errFlightAborted is the result of a flight whose call panicked
+var errFlightAborted = errors.New("coalesced request did not complete")
+
+// flight is an upstream request that is running; result and err are set when done is closed
+type flight struct {
+	done   chan struct{}
+	result *provider.ChatResult
+	err    error
+}
+
+// newFlightGroup creates an empty flightGroup
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[[sha256.Size]byte]*flight)}
+}
+
+// do runs call, unless a request with the same key is already running, in which case it
+// waits for that request's result. When the running request fails because its own client went
+// away, or panics, a waiting request that is still wanted runs call itself.
+func (g *flightGroup) do(ctx context.Context, key [sha256.Size]byte, call func() (*provider.ChatResult, error)) (*provider.ChatResult, error) {
+	for {
+		g.mu.Lock()
+		f, running := g.flights[key]
+		if !running {
+			f = &flight{done: make(chan struct{}), err: errFlightAborted}
+			g.flights[key] = f
+		}
+		g.mu.Unlock()
+
+		if !running {
+			defer func() {
+				g.mu.Lock()
+				delete(g.flights, key)
+				g.mu.Unlock()
+				close(f.done)
+			}()
+			f.result, f.err = call()
+			return f.result, f.err
+		}
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if (errors.Is(f.err, context.Canceled) || f.err == errFlightAborted) && ctx.Err() == nil {
+			continue
+		}
+		return f.result, f.err
+	}
+}
+
+// coalescable reports whether identical requests with these options get the same answer, so
+// they can share one upstream call: the temperature is 0 or a seed is set. Other requests are
+// sampled, and clients sending the same one in parallel may want different answers.
+func coalescable(opts map[string]interface{}) bool {
+	if temperature, ok := opts["temperature"].(float64); ok && temperature == 0 {
+		return true
+	}
+	_, seeded := opts["seed"]
+	return seeded
+}
+
+// flightKey identifies an upstream request by what it asks and the providers that may serve it
+func flightKey(kind string, candidates []*models.Provider, modelID string, input interface{}, opts map[string]interface{}) ([sha256.Size]byte, error) {
+	names := make([]string, 0, len(candidates))
+	for _, prov := range candidates {
+		names = append(names, prov.Name)
+	}
+	// Maps are encoded with sorted keys, so equal options give equal keys
+	data, err := json.Marshal(map[string]interface{}{
+		"kind":      kind,
+		"providers": names,
+		"model":     modelID,
+		"input":     input,
+		"options":   opts,
+	})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// coalesced runs call through the router's flight group when the request is coalescable
+func (r *Router) coalesced(c *gin.Context, kind string, candidates []*models.Provider, modelID string, input interface{}, opts map[string]interface{}, call func() (*provider.ChatResult, error)) (*provider.ChatResult, error) {
+	if !coalescable(opts) {
+		return call()
+	}
+	key, err := flightKey(kind, candidates, modelID, input, opts)
+	if err != nil {
+		return call()
+	}
+	return r.flights.do(c.Request.Context(), key, call)
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/offbeat-studio/allama/internal/cost"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// Context strategies for chat histories that do not fit the model's context length
+const (
+	// contextOff sends histories as they are and leaves it to the provider to reject them
+	contextOff = ""
+	// contextDropOldest drops the oldest messages, keeping the system messages
+	contextDropOldest = "drop_oldest"
+	// contextError rejects histories that do not fit before calling the provider
+	contextError = "error"
+)
+
+// validContextStrategy reports whether strategy is a known context strategy
+func validContextStrategy(strategy string) bool {
+	switch strategy {
+	case contextOff, contextDropOldest, contextError:
+		return true
+	default:
+		return false
+	}
+}
+
+// messageOverheadTokens approximates what each message costs beyond its text, for its role and
+// the separators around it
+const messageOverheadTokens = 4
+
+// messageTokens estimates the tokens a message takes up in the context
+func messageTokens(msg models.Message) int {
+	tokens := messageOverheadTokens + cost.EstimateTokens(msg.Content)
+	for _, call := range msg.ToolCalls {
+		tokens += cost.EstimateTokens(call.Function.Name + call.Function.Arguments)
+	}
+	return tokens
+}
+
+// fitContext applies the context strategy to a chat history sent to prov. An Ollama client's
+// num_ctx option caps the context length. Histories for models whose context length is not
+// known are sent as they are. The tokens of max_tokens, or of the provider's default, are kept
+// free for the answer.
+func (r *Router) fitContext(prov *models.Provider, requested, modelID string, messages []models.Message, opts map[string]interface{}) ([]models.Message, *routeError) {
+	if r.contextStrategy == contextOff {
+		return messages, nil
+	}
+	var stored *models.ModelMetadata
+	if model := r.storedModel(prov.ID, modelID); model != nil {
+		stored = model.Metadata
+	}
+	contextLength := provider.KnownContextLength(prov.ProviderType(), modelID, stored)
+	if numCtx, ok := opts["num_ctx"].(float64); ok && (contextLength == 0 || int(numCtx) < contextLength) {
+		contextLength = int(numCtx)
+	}
+	if contextLength == 0 {
+		return messages, nil
+	}
+
+	reserved := prov.DefaultMaxTokens
+	if maxTokens, ok := opts["max_tokens"].(float64); ok {
+		reserved = int(maxTokens)
+	}
+	fitted, tokens := windowMessages(messages, contextLength-reserved)
+	if fitted == nil || (r.contextStrategy == contextError && len(fitted) < len(messages)) {
+		return nil, &routeError{http.StatusBadRequest, "context_length_exceeded", fmt.Sprintf("messages use about %d tokens, more than the %d available to model %s", tokens, contextLength-reserved, requested)}
+	}
+	if len(fitted) < len(messages) {
+		fmt.Printf("fitContext: dropped %d of %d messages to fit the %d token context of %s\n", len(messages)-len(fitted), len(messages), contextLength, requested)
+	}
+	return fitted, nil
+}
+
+// windowMessages keeps the system messages and as many of the most recent other messages as fit
+// in budget tokens, in their original order. Tool results whose call was dropped are dropped
+// too. It returns nil when the system messages and the last message do not fit, and the
+// estimated tokens of the whole history.
+func windowMessages(messages []models.Message, budget int) ([]models.Message, int) {
+	total := 0
+	used := 0
+	for _, msg := range messages {
+		tokens := messageTokens(msg)
+		total += tokens
+		if msg.Role == "system" {
+			used += tokens
+		}
+	}
+	if total <= budget || len(messages) == 0 {
+		return messages, total
+	}
+
+	// Walk back from the most recent message until the budget is spent
+	keep := make([]bool, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "system" {
+			continue
+		}
+		tokens := messageTokens(messages[i])
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		keep[i] = true
+	}
+	last := len(messages) - 1
+	if used > budget || (messages[last].Role != "system" && !keep[last]) {
+		return nil, total
+	}
+
+	fitted := make([]models.Message, 0, len(messages))
+	leading := true
+	for i, msg := range messages {
+		if msg.Role == "system" {
+			fitted = append(fitted, msg)
+			continue
+		}
+		if !keep[i] || leading && msg.Role == "tool" {
+			continue
+		}
+		leading = false
+		fitted = append(fitted, msg)
+	}
+	return fitted, total
+}
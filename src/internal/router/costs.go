@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/cost"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	dbutils "github.com/offbeat-studio/allama/utils"
+)
+
+// recordUsage prices a completed request and logs it as a structured cost entry. When the
+// provider reports no usage, token counts are estimated from the input and output text.
+func (r *Router) recordUsage(c *gin.Context, providerName, modelID, input, output string, usage *models.Usage) {
+	entry := cost.Usage{Provider: providerName, Model: modelID}
+	if usage != nil && (usage.PromptTokens > 0 || usage.CompletionTokens > 0) {
+		entry.PromptTokens = usage.PromptTokens
+		entry.CompletionTokens = usage.CompletionTokens
+	} else {
+		entry.PromptTokens = cost.EstimateTokens(input)
+		entry.CompletionTokens = cost.EstimateTokens(output)
+		entry.Estimated = true
+	}
+
+	amount := r.costs.Record(entry)
+	r.logger.Log(dbutils.INFO, middleware.GetRequestID(c), "cost", gin.H{
+		"provider":          entry.Provider,
+		"model":             entry.Model,
+		"prompt_tokens":     entry.PromptTokens,
+		"completion_tokens": entry.CompletionTokens,
+		"estimated":         entry.Estimated,
+		"cost":              amount,
+	})
+}
+
+// messagesText joins the text of the messages, for estimating their token count
+func messagesText(messages []models.Message) string {
+	texts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		texts = append(texts, msg.Content)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// listCosts returns the estimated spend per provider and model since startup
+func (r *Router) listCosts(c *gin.Context) {
+	totals := r.costs.Totals()
+	var total float64
+	for _, entry := range totals {
+		total += entry.Cost
+	}
+	c.JSON(http.StatusOK, gin.H{"data": totals, "total_cost": total})
+}
+
+// handleMetrics serves the cost and token counters and the provider and model counts in the
+// Prometheus text format
+func (r *Router) handleMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(http.StatusOK)
+	r.costs.WriteMetrics(c.Writer)
+	r.writeInventoryMetrics(c.Writer)
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// fallbackError reports that every candidate provider failed, keeping the last failure for status mapping
+type fallbackError struct {
+	message string
+	last    error
+}
+
+func (e *fallbackError) Error() string {
+	return e.message
+}
+
+func (e *fallbackError) Unwrap() error {
+	return e.last
+}
+
+// upstreamStatus maps a provider error to the status returned to the client.
+// Client errors reported upstream are passed through, except credential failures,
+// which are the proxy's configuration problem rather than the caller's. Undecodable
+// and empty responses are reported as a bad gateway too, while a response withheld by
+// the provider's content filter is blamed on the request.
+func upstreamStatus(err error) int {
+	var upstream *provider.UpstreamError
+	var decodeErr *provider.DecodeError
+	var emptyErr *provider.EmptyResponseError
+	switch {
+	case errors.Is(err, provider.ErrEmbeddingsUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, provider.ErrProviderBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &decodeErr):
+		return http.StatusBadGateway
+	case errors.As(err, &emptyErr):
+		if emptyErr.ContentFiltered() {
+			return http.StatusBadRequest
+		}
+		return http.StatusBadGateway
+	case errors.As(err, &upstream):
+		if upstream.StatusCode == http.StatusUnauthorized || upstream.StatusCode == http.StatusForbidden || upstream.StatusCode >= 500 {
+			return http.StatusBadGateway
+		}
+		if upstream.StatusCode >= 400 {
+			return upstream.StatusCode
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// handleNoRoute answers requests for unknown paths
+func handleNoRoute(c *gin.Context) {
+	middleware.RespondErrorCode(c, http.StatusNotFound, "not_found", fmt.Sprintf("path '%s' not found", c.Request.URL.Path))
+}
+
+// handleNoMethod answers requests using a method the path does not support
+func handleNoMethod(c *gin.Context) {
+	middleware.RespondErrorCode(c, http.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("method %s is not allowed for '%s'", c.Request.Method, c.Request.URL.Path))
+}
+
+// upstreamCode returns the error code of a provider error that clients can tell apart, or ""
+func upstreamCode(err error) string {
+	var emptyErr *provider.EmptyResponseError
+	if !errors.As(err, &emptyErr) {
+		return ""
+	}
+	if emptyErr.ContentFiltered() {
+		return "content_filter"
+	}
+	return "empty_response"
+}
+
+// respondUpstreamError aborts the request with the status mapped from a provider error
+func respondUpstreamError(c *gin.Context, err error) {
+	middleware.RespondErrorCode(c, upstreamStatus(err), upstreamCode(err), err.Error())
+}
+
+// respondModelNotFound aborts the request because no active provider serves the model
+func respondModelNotFound(c *gin.Context, model string) {
+	middleware.RespondErrorCode(c, http.StatusNotFound, "model_not_found", fmt.Sprintf("model '%s' not found", model))
+}
+
+// respondInvalidOption aborts the request because a chat option failed validation, naming the option
+func respondInvalidOption(c *gin.Context, err error) {
+	var optionErr *provider.OptionError
+	param := ""
+	if errors.As(err, &optionErr) {
+		param = optionErr.Option
+	}
+	middleware.RespondErrorParam(c, http.StatusBadRequest, "invalid_parameter", param, err.Error())
+}
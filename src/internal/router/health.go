@@ -0,0 +1,174 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// providerHealth is the probe result for a single provider
+type providerHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthRecord is the last probe result of a provider
+type healthRecord struct {
+	providerHealth
+	checkedAt time.Time
+}
+
+// healthCache keeps the last probe result of each provider by name
+type healthCache struct {
+	mu      sync.Mutex
+	records map[string]healthRecord
+}
+
+// newHealthCache creates an empty health cache
+func newHealthCache() *healthCache {
+	return &healthCache{records: make(map[string]healthRecord)}
+}
+
+// store records probe results
+func (h *healthCache) store(results []providerHealth) {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, result := range results {
+		h.records[result.Name] = healthRecord{providerHealth: result, checkedAt: now}
+	}
+}
+
+// last returns the last probe result of the named provider
+func (h *healthCache) last(name string) (healthRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	record, ok := h.records[name]
+	return record, ok
+}
+
+// probeProviders pings the providers concurrently and records the results
+func (r *Router) probeProviders(c *gin.Context, providers []*models.Provider) []providerHealth {
+	results := make([]providerHealth, len(providers))
+	var wg sync.WaitGroup
+	for i, prov := range providers {
+		results[i] = providerHealth{Name: prov.Name}
+		providerImpl := r.providerFor(c, prov)
+		if providerImpl == nil {
+			results[i].Status = "error"
+			results[i].Error = "unsupported provider"
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *providerHealth, providerImpl provider.ProviderInterface) {
+			defer wg.Done()
+			start := time.Now()
+			err := providerImpl.Ping(c.Request.Context())
+			result.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+				return
+			}
+			result.Status = "ok"
+		}(&results[i], providerImpl)
+	}
+	wg.Wait()
+	r.health.store(results)
+	return results
+}
+
+// healthProviders probes every active provider and reports ok, degraded or unhealthy
+func (r *Router) healthProviders(c *gin.Context) {
+	providers, err := r.store.GetActiveProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+
+	results := r.probeProviders(c, providers)
+	healthy := 0
+	for _, result := range results {
+		if result.Status == "ok" {
+			healthy++
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	switch {
+	case healthy == 0:
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	case healthy < len(results):
+		status = "degraded"
+	}
+
+	c.JSON(code, gin.H{
+		"status":    status,
+		"providers": results,
+	})
+}
+
+// providersStatus lists every configured provider with its last known health, for admin UIs.
+// Results of earlier probes are reused; ?refresh=true probes the active providers first.
+// API keys and credentials in hosts are never included.
+func (r *Router) providersStatus(c *gin.Context) {
+	providers, err := r.store.GetProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+	if wantsRefresh(c) {
+		var active []*models.Provider
+		for _, prov := range providers {
+			if prov.IsActive {
+				active = append(active, prov)
+			}
+		}
+		r.probeProviders(c, active)
+	}
+
+	data := make([]gin.H, 0, len(providers))
+	for _, prov := range providers {
+		status := gin.H{
+			"name":            prov.Name,
+			"type":            prov.ProviderType(),
+			"active":          prov.IsActive,
+			"host":            redactedHost(prov.Host),
+			"status":          "unknown",
+			"last_ping_ms":    nil,
+			"last_error":      nil,
+			"last_checked_at": nil,
+		}
+		if record, ok := r.health.last(prov.Name); ok {
+			status["status"] = record.Status
+			status["last_ping_ms"] = record.LatencyMs
+			status["last_checked_at"] = record.checkedAt.UTC().Format(time.RFC3339)
+			if record.Error != "" {
+				status["last_error"] = record.Error
+			}
+		}
+		data = append(data, status)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// redactedHost presents a provider host without the credentials or query parameters a URL
+// may carry
+func redactedHost(host string) string {
+	u, err := url.Parse(host)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.Redacted()
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// keepAlivePaths lists the Ollama endpoints that accept keep_alive. Its OpenAI-compatible
+// endpoints do not.
+var keepAlivePaths = map[string]bool{
+	"/api/chat":       true,
+	"/api/generate":   true,
+	"/api/embed":      true,
+	"/api/embeddings": true,
+}
+
+// keepAliveValue turns a configured keep_alive into the JSON value Ollama expects: a number of
+// seconds for numeric settings and a duration string otherwise. Empty settings give nil.
+func keepAliveValue(setting string) interface{} {
+	if setting == "" {
+		return nil
+	}
+	if seconds, err := strconv.ParseFloat(setting, 64); err == nil {
+		return seconds
+	}
+	return setting
+}
+
+// withKeepAlive sets keep_alive in a raw request body forwarded to Ollama when the client did
+// not set one. Other paths, and bodies that cannot be decoded, are left as they are.
+func withKeepAlive(body []byte, path string, keepAlive interface{}) []byte {
+	if keepAlive == nil || !keepAlivePaths[path] {
+		return body
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	if _, ok := payload["keep_alive"]; ok {
+		return body
+	}
+	payload["keep_alive"] = keepAlive
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
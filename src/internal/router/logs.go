@@ -0,0 +1,70 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	dbutils "github.com/offbeat-studio/allama/utils"
+)
+
+// Limits of the number of entries returned by the log endpoint
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// listLogs returns the latest entries of a day's request log, optionally filtered by level.
+// The date query parameter defaults to today, limit to 100 entries and level to all entries.
+func (r *Router) listLogs(c *gin.Context) {
+	if !r.logger.WritesToFile() {
+		middleware.RespondError(c, http.StatusNotFound, "Log files are disabled by LOG_OUTPUT")
+		return
+	}
+
+	date := c.DefaultQuery("date", time.Now().Format(time.DateOnly))
+	limit := defaultLogLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			middleware.RespondError(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxLogLimit)
+	}
+	level := dbutils.DEBUG
+	if value := c.Query("level"); value != "" {
+		parsed, err := dbutils.ParseLogLevel(value)
+		if err != nil {
+			middleware.RespondError(c, http.StatusBadRequest, "level must be DEBUG, INFO or ERROR")
+			return
+		}
+		level = parsed
+	}
+
+	entries, err := r.logger.ReadEntries(date, level, limit)
+	if errors.Is(err, dbutils.ErrInvalidLogDate) {
+		middleware.RespondError(c, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+		return
+	}
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to read logs")
+		return
+	}
+	for i := range entries {
+		entries[i].Data = withoutHeaders(entries[i].Data)
+	}
+	c.JSON(http.StatusOK, gin.H{"date": date, "data": entries})
+}
+
+// withoutHeaders drops the request headers from the data of a log entry. They may carry
+// credentials of other clients, including ones logged before headers were redacted.
+func withoutHeaders(data interface{}) interface{} {
+	if fields, ok := data.(map[string]interface{}); ok {
+		delete(fields, "headers")
+	}
+	return data
+}
@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// handleCopy handles the /api/copy endpoint. Copies of Ollama models are forwarded to Ollama.
+// Remote models cannot be copied, so the destination becomes an alias of the source model.
+// A destination that is already an alias or a served model is rejected, since the copy would
+// redirect every client's requests for it.
+func (r *Router) handleCopy(c *gin.Context) {
+	var requestBody struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		fmt.Printf("handleCopy: invalid request body: %v\n", err)
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if requestBody.Source == "" || requestBody.Destination == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "source and destination are required")
+		return
+	}
+
+	prov, modelID, ok := r.primaryRoute(c, "handleCopy", requestBody.Source)
+	if !ok {
+		return
+	}
+	if !r.copyDestinationFree(c, requestBody.Destination) {
+		return
+	}
+
+	if prov.ProviderType() == "ollama" {
+		body, _ := json.Marshal(gin.H{"source": modelID, "destination": requestBody.Destination})
+		r.forwardOllamaModelChange(c, prov, "/api/copy", body)
+		return
+	}
+
+	alias := &models.Alias{Alias: requestBody.Destination, ModelID: modelID, Provider: prov.Name, Copied: true}
+	if err := r.store.UpsertAlias(alias); err != nil {
+		fmt.Printf("handleCopy: failed to save alias: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to save alias")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// handleDelete handles the /api/delete endpoint. An alias created by copying a remote model
+// is removed without touching its target; other aliases are left to the admin API. Deletes of
+// Ollama models are forwarded to Ollama. Remote models are deactivated for every client, so
+// that requires the admin token, and they are no longer listed or routed to until reactivated
+// through the admin API.
+func (r *Router) handleDelete(c *gin.Context) {
+	var requestBody struct {
+		Model string `json:"model"`
+		Name  string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		fmt.Printf("handleDelete: invalid request body: %v\n", err)
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// Older Ollama clients send the model as "name"
+	requested := requestBody.Model
+	if requested == "" {
+		requested = requestBody.Name
+	}
+	if requested == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "model is required")
+		return
+	}
+
+	alias, err := r.store.GetAlias(requested)
+	if err != nil {
+		fmt.Printf("handleDelete: alias lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve aliases")
+		return
+	}
+	if alias != nil {
+		if !alias.Copied {
+			middleware.RespondError(c, http.StatusForbidden, fmt.Sprintf("Alias %s was not created by a copy, delete it through the admin API", requested))
+			return
+		}
+		if err := r.store.DeleteAlias(requested); err != nil {
+			fmt.Printf("handleDelete: failed to delete alias: %v\n", err)
+			middleware.RespondError(c, http.StatusInternalServerError, "Failed to delete alias")
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	prov, modelID, ok := r.primaryRoute(c, "handleDelete", requested)
+	if !ok {
+		return
+	}
+
+	if prov.ProviderType() == "ollama" {
+		body, _ := json.Marshal(gin.H{"model": modelID})
+		r.forwardOllamaModelChange(c, prov, "/api/delete", body)
+		return
+	}
+
+	if !middleware.HasAdminToken(c, r.cfg.AdminToken) {
+		middleware.RespondError(c, http.StatusForbidden, "Deleting a model served by a remote provider requires the admin token")
+		return
+	}
+	model := r.storedModel(prov.ID, modelID)
+	if model == nil {
+		respondModelNotFound(c, requested)
+		return
+	}
+	if err := r.store.UpdateModelActive(model.ID, false); err != nil {
+		fmt.Printf("handleDelete: failed to deactivate model: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to update model")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// syncOllamaModels brings the stored and cached models of an Ollama provider up to date after
+// its models changed. A failed sync is only logged, since the change itself succeeded.
+func (r *Router) syncOllamaModels(c *gin.Context, handler string, prov *models.Provider) {
+	r.catalog.invalidate(prov.ID)
+	if _, err := provider.FetchModelsForProvider(c.Request.Context(), r.store, prov); err != nil {
+		fmt.Printf("%s: failed to sync models of %s: %v\n", handler, prov.Name, err)
+	}
+}
+
+// copyDestinationFree reports whether no alias or provider uses the destination of a copy,
+// responding with an error when one does
+func (r *Router) copyDestinationFree(c *gin.Context, destination string) bool {
+	alias, err := r.store.GetAlias(destination)
+	if err != nil {
+		fmt.Printf("handleCopy: alias lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve aliases")
+		return false
+	}
+	if alias != nil {
+		middleware.RespondError(c, http.StatusConflict, fmt.Sprintf("model '%s' already exists", destination))
+		return false
+	}
+	served, err := r.store.GetProvidersForModel(destination)
+	if err != nil {
+		fmt.Printf("handleCopy: provider lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return false
+	}
+	if len(served) > 0 {
+		middleware.RespondError(c, http.StatusConflict, fmt.Sprintf("model '%s' already exists", destination))
+		return false
+	}
+	return true
+}
+
+// forwardOllamaModelChange forwards a request that changes Ollama's models and relays its
+// response, then syncs the provider's models when it succeeded
+func (r *Router) forwardOllamaModelChange(c *gin.Context, prov *models.Provider, path string, body []byte) {
+	headers := map[string]string{
+		"Content-Type":             "application/json",
+		middleware.RequestIDHeader: middleware.GetRequestID(c),
+	}
+	resp, err := ollamaClient(prov).ForwardResponse(c.Request.Context(), c.Request.Method, path, body, headers)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	if resp.StatusCode == http.StatusOK {
+		r.syncOllamaModels(c, "forwardOllamaModelChange", prov)
+	}
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+}
@@ -0,0 +1,419 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+	"github.com/offbeat-studio/allama/internal/storage"
+)
+
+// providerResponse presents a provider without exposing its API key or header values,
+// which may carry credentials
+func providerResponse(p *models.Provider) gin.H {
+	headerNames := make([]string, 0, len(p.Headers))
+	for name := range p.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	return gin.H{
+		"id":          p.ID,
+		"name":        p.Name,
+		"type":        p.ProviderType(),
+		"host":        redactedHost(p.Host),
+		"is_active":   p.IsActive,
+		"has_api_key": p.APIKey != "",
+		"headers":     headerNames,
+
+		"default_max_tokens": p.DefaultMaxTokens,
+		"system_prompt":      p.SystemPrompt,
+		"system_prompt_mode": p.SystemPromptMode,
+		"priority":           p.Priority,
+		"weight":             p.Weight,
+		"proxy":              redactedProxy(p.Proxy),
+		"tls":                p.TLS,
+	}
+}
+
+// redactedProxy presents a proxy URL without its password
+func redactedProxy(proxyURL string) string {
+	if proxyURL == "" {
+		return ""
+	}
+	return provider.RedactProxyURL(proxyURL)
+}
+
+// validProxy reports whether proxyURL is empty or a usable proxy URL, responding with an error otherwise
+func validProxy(c *gin.Context, proxyURL string) bool {
+	if proxyURL == "" {
+		return true
+	}
+	if _, err := provider.ParseProxyURL(proxyURL); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// validTLS reports whether settings are empty or usable TLS settings, responding with an error
+// otherwise. Disabling certificate verification is allowed but logged.
+func validTLS(c *gin.Context, name string, settings *models.ProviderTLS) bool {
+	if settings == nil {
+		return true
+	}
+	if _, err := provider.TLSConfig(settings); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if settings.InsecureSkipVerify {
+		fmt.Printf("WARNING: TLS certificate verification is disabled for the %s provider; its traffic can be intercepted\n", name)
+	}
+	return true
+}
+
+// idParam parses the :id route parameter
+func idParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// refreshModelsIfRequested refreshes the provider's model list when ?refresh_models=true is set.
+// When the refresh fails it responds with the mapped upstream status, noting that the provider
+// itself was saved, and returns false.
+func (r *Router) refreshModelsIfRequested(c *gin.Context, prov *models.Provider) bool {
+	if c.Query("refresh_models") != "true" || !prov.IsActive {
+		return true
+	}
+	if _, err := provider.FetchModelsForProvider(c.Request.Context(), r.store, prov); err != nil {
+		middleware.RespondError(c, upstreamStatus(err), fmt.Sprintf("Provider %s was saved, but refreshing its models failed: %v", prov.Name, err))
+		return false
+	}
+	r.catalog.invalidate(prov.ID)
+	return true
+}
+
+// listProviders returns every configured provider
+func (r *Router) listProviders(c *gin.Context) {
+	providers, err := r.store.GetProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+
+	data := make([]gin.H, 0, len(providers))
+	for _, p := range providers {
+		data = append(data, providerResponse(p))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// createProvider adds a new provider; its type defaults to its name
+func (r *Router) createProvider(c *gin.Context) {
+	var requestBody struct {
+		Name     string `json:"name" binding:"required"`
+		Type     string `json:"type"`
+		APIKey   string `json:"api_key"`
+		Host     string `json:"host" binding:"required"`
+		IsActive *bool  `json:"is_active"`
+
+		Headers          map[string]string   `json:"headers"`
+		DefaultMaxTokens int                 `json:"default_max_tokens"`
+		SystemPrompt     string              `json:"system_prompt"`
+		SystemPromptMode string              `json:"system_prompt_mode"`
+		Priority         int                 `json:"priority"`
+		Weight           *int                `json:"weight"`
+		Proxy            string              `json:"proxy"`
+		TLS              *models.ProviderTLS `json:"tls"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if requestBody.DefaultMaxTokens < 0 {
+		middleware.RespondError(c, http.StatusBadRequest, "default_max_tokens must not be negative")
+		return
+	}
+	if !provider.ValidSystemPromptMode(requestBody.SystemPromptMode) {
+		middleware.RespondError(c, http.StatusBadRequest, "system_prompt_mode must be prepend, append or override")
+		return
+	}
+	weight := 1
+	if requestBody.Weight != nil {
+		weight = *requestBody.Weight
+	}
+	if weight < 1 {
+		middleware.RespondError(c, http.StatusBadRequest, "weight must be at least 1")
+		return
+	}
+	if !validProxy(c, requestBody.Proxy) || !validTLS(c, requestBody.Name, requestBody.TLS) {
+		return
+	}
+
+	prov := &models.Provider{
+		Name:     requestBody.Name,
+		Type:     requestBody.Type,
+		APIKey:   requestBody.APIKey,
+		Host:     requestBody.Host,
+		IsActive: requestBody.IsActive == nil || *requestBody.IsActive,
+		Headers:  requestBody.Headers,
+
+		DefaultMaxTokens: requestBody.DefaultMaxTokens,
+		SystemPrompt:     requestBody.SystemPrompt,
+		SystemPromptMode: requestBody.SystemPromptMode,
+		Priority:         requestBody.Priority,
+		Weight:           weight,
+		Proxy:            requestBody.Proxy,
+		TLS:              requestBody.TLS,
+	}
+	if provider.CreateProvider(prov) == nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Unsupported provider")
+		return
+	}
+
+	existing, err := r.store.GetProviderByName(prov.Name)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+	if existing != nil {
+		middleware.RespondError(c, http.StatusConflict, "Provider already exists")
+		return
+	}
+
+	if err := r.store.AddProvider(prov); err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to create provider")
+		return
+	}
+	if !r.refreshModelsIfRequested(c, prov) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, providerResponse(prov))
+}
+
+// updateProvider changes the API key, host, active flag, custom headers, default max tokens,
+// system prompt, priority, weight, proxy or TLS settings of a provider
+func (r *Router) updateProvider(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		APIKey   *string `json:"api_key"`
+		Host     *string `json:"host"`
+		IsActive *bool   `json:"is_active"`
+
+		// Headers replaces the provider's custom headers when present
+		Headers map[string]string `json:"headers"`
+		// DefaultMaxTokens replaces the provider's default when present; zero clears it
+		DefaultMaxTokens *int    `json:"default_max_tokens"`
+		SystemPrompt     *string `json:"system_prompt"`
+		SystemPromptMode *string `json:"system_prompt_mode"`
+		Priority         *int    `json:"priority"`
+		Weight           *int    `json:"weight"`
+		// Proxy replaces the provider's proxy when present; an empty string uses the environment's
+		Proxy *string `json:"proxy"`
+		// TLS replaces the provider's TLS settings when present; an empty object clears them
+		TLS *models.ProviderTLS `json:"tls"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if requestBody.DefaultMaxTokens != nil && *requestBody.DefaultMaxTokens < 0 {
+		middleware.RespondError(c, http.StatusBadRequest, "default_max_tokens must not be negative")
+		return
+	}
+	if requestBody.SystemPromptMode != nil && !provider.ValidSystemPromptMode(*requestBody.SystemPromptMode) {
+		middleware.RespondError(c, http.StatusBadRequest, "system_prompt_mode must be prepend, append or override")
+		return
+	}
+	if requestBody.Weight != nil && *requestBody.Weight < 1 {
+		middleware.RespondError(c, http.StatusBadRequest, "weight must be at least 1")
+		return
+	}
+	if requestBody.Proxy != nil && !validProxy(c, *requestBody.Proxy) {
+		return
+	}
+
+	prov, err := r.store.GetProviderByID(id)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve provider")
+		return
+	}
+	if prov == nil {
+		middleware.RespondError(c, http.StatusNotFound, "Provider not found")
+		return
+	}
+
+	if requestBody.APIKey != nil {
+		prov.APIKey = *requestBody.APIKey
+	}
+	if requestBody.Host != nil {
+		prov.Host = *requestBody.Host
+	}
+	if requestBody.IsActive != nil {
+		prov.IsActive = *requestBody.IsActive
+	}
+	if requestBody.Headers != nil {
+		prov.Headers = requestBody.Headers
+	}
+	if requestBody.DefaultMaxTokens != nil {
+		prov.DefaultMaxTokens = *requestBody.DefaultMaxTokens
+	}
+	if requestBody.SystemPrompt != nil {
+		prov.SystemPrompt = *requestBody.SystemPrompt
+	}
+	if requestBody.SystemPromptMode != nil {
+		prov.SystemPromptMode = *requestBody.SystemPromptMode
+	}
+	if requestBody.Priority != nil {
+		prov.Priority = *requestBody.Priority
+	}
+	if requestBody.Weight != nil {
+		prov.Weight = *requestBody.Weight
+	}
+	if requestBody.Proxy != nil {
+		prov.Proxy = *requestBody.Proxy
+	}
+	if requestBody.TLS != nil {
+		if !validTLS(c, prov.Name, requestBody.TLS) {
+			return
+		}
+		prov.TLS = requestBody.TLS
+		if *prov.TLS == (models.ProviderTLS{}) {
+			prov.TLS = nil
+		}
+	}
+
+	if err := r.store.UpdateProvider(prov); err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to update provider")
+		return
+	}
+	r.catalog.invalidate(prov.ID)
+	if !r.refreshModelsIfRequested(c, prov) {
+		return
+	}
+
+	c.JSON(http.StatusOK, providerResponse(prov))
+}
+
+// deleteProvider removes a provider and its models
+func (r *Router) deleteProvider(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	prov, err := r.store.GetProviderByID(id)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve provider")
+		return
+	}
+	if prov == nil {
+		middleware.RespondError(c, http.StatusNotFound, "Provider not found")
+		return
+	}
+
+	if err := r.store.DeleteProvider(id); err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to delete provider")
+		return
+	}
+	r.catalog.invalidate(id)
+
+	c.Status(http.StatusNoContent)
+}
+
+// refreshProvider re-fetches a provider's model catalog and reports what changed
+func (r *Router) refreshProvider(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	prov, err := r.store.GetProviderByID(id)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve provider")
+		return
+	}
+	if prov == nil {
+		middleware.RespondError(c, http.StatusNotFound, "Provider not found")
+		return
+	}
+	if !prov.IsActive {
+		middleware.RespondError(c, http.StatusConflict, "Provider is not active")
+		return
+	}
+
+	summary, err := provider.FetchModelsForProvider(c.Request.Context(), r.store, prov)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	r.catalog.invalidate(prov.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      prov.ID,
+		"name":    prov.Name,
+		"models":  summary.Added + summary.Kept,
+		"added":   summary.Added,
+		"removed": summary.Removed,
+		"kept":    summary.Kept,
+	})
+}
+
+// updateModel enables or disables a stored model and sets its metadata overrides
+func (r *Router) updateModel(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		IsActive *bool `json:"is_active"`
+		// Metadata replaces the model's metadata overrides; an empty object removes them
+		Metadata *models.ModelMetadata `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil || (requestBody.IsActive == nil && requestBody.Metadata == nil) {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	response := gin.H{"id": id}
+	var err error
+	if requestBody.IsActive != nil {
+		err = r.store.UpdateModelActive(id, *requestBody.IsActive)
+		response["is_active"] = *requestBody.IsActive
+	}
+	if err == nil && requestBody.Metadata != nil {
+		metadata := requestBody.Metadata
+		if len(metadata.Capabilities) == 0 && metadata.ContextLength == 0 && metadata.Family == "" &&
+			metadata.ParameterSize == "" && metadata.QuantizationLevel == "" {
+			metadata = nil
+		}
+		err = r.store.UpdateModelMetadata(id, metadata)
+		response["metadata"] = metadata
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		middleware.RespondError(c, http.StatusNotFound, "Model not found")
+		return
+	}
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to update model")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// handlePull handles the /api/pull endpoint. Pulls for Ollama are forwarded with their
+// progress stream; a model unknown to every provider is pulled into the first active Ollama.
+// Models served by remote providers are always available, so their pull succeeds at once.
+func (r *Router) handlePull(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		fmt.Printf("handlePull: failed to read request body: %v\n", err)
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	var requestBody struct {
+		Model  string `json:"model"`
+		Name   string `json:"name"`
+		Stream *bool  `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		fmt.Printf("handlePull: invalid request body: %v\n", err)
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// Older Ollama clients send the model as "name"
+	requested := requestBody.Model
+	if requested == "" {
+		requested = requestBody.Name
+	}
+	if requested == "" {
+		middleware.RespondError(c, http.StatusBadRequest, "model is required")
+		return
+	}
+
+	prov, modelID, err := r.pullTarget(requested)
+	if err != nil {
+		fmt.Printf("handlePull: provider lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+	if prov == nil {
+		fmt.Println("handlePull: unsupported model")
+		respondModelNotFound(c, requested)
+		return
+	}
+
+	if prov.ProviderType() != "ollama" {
+		if requestBody.Stream == nil || *requestBody.Stream {
+			c.Data(http.StatusOK, "application/x-ndjson", []byte("{\"status\":\"success\"}\n"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+		return
+	}
+
+	r.forwardOllamaPull(c, prov, withModel(body, requested, modelID))
+}
+
+// pullTarget returns the provider a pull for the requested model goes to. A model unknown to
+// every provider is pulled into the first active Ollama, or without one goes to the default
+// route; the provider is nil when there is neither.
+func (r *Router) pullTarget(requested string) (*models.Provider, string, error) {
+	modelID, candidates, unknown, err := r.lookupModel(requested)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(candidates) > 0 {
+		return candidates[0], modelID, nil
+	}
+
+	providers, err := r.store.GetActiveProviders()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, prov := range providers {
+		if prov.ProviderType() == "ollama" {
+			return prov, requested, nil
+		}
+	}
+	if !unknown {
+		return nil, "", nil
+	}
+	modelID, candidates, err = r.defaultRoute(modelID)
+	if err != nil || len(candidates) == 0 {
+		return nil, "", err
+	}
+	return candidates[0], modelID, nil
+}
+
+// forwardOllamaPull streams Ollama's pull progress to the client as it arrives, then
+// refreshes the provider's stored models so the pulled model can be routed to
+func (r *Router) forwardOllamaPull(c *gin.Context, prov *models.Provider, body []byte) {
+	ollamaProvider := ollamaClient(prov)
+	headers := map[string]string{
+		"Content-Type":             "application/json",
+		middleware.RequestIDHeader: middleware.GetRequestID(c),
+	}
+
+	resp, err := ollamaProvider.ForwardStream(c.Request.Context(), http.MethodPost, "/api/pull", body, headers)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if !copyUpstreamStream(c, "forwardOllamaPull", resp) {
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		r.syncOllamaModels(c, "forwardOllamaPull", prov)
+	}
+}
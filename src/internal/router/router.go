@@ -6,130 +6,486 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/offbeat-studio/allama/internal/config"
+	"github.com/offbeat-studio/allama/internal/cost"
 	"github.com/offbeat-studio/allama/internal/middleware"
 	"github.com/offbeat-studio/allama/internal/models"
 	"github.com/offbeat-studio/allama/internal/provider"
+	"github.com/offbeat-studio/allama/internal/storage"
+	dbutils "github.com/offbeat-studio/allama/utils"
 )
 
 // StorageInterface defines the interface that storage must implement
 type StorageInterface interface {
 	GetActiveProviders() ([]*models.Provider, error)
 	GetProviderByName(name string) (*models.Provider, error)
+	GetProviderByID(id int) (*models.Provider, error)
+	GetProviders() ([]*models.Provider, error)
+	UpdateProvider(provider *models.Provider) error
+	DeleteProvider(id int) error
 	GetModelsByProviderID(providerID int) ([]models.Model, error)
+	GetProvidersForModel(modelID string) ([]*models.Provider, error)
 	AddProvider(provider *models.Provider) error
 	AddModel(model *models.Model) error
+	UpdateModelActive(id int, active bool) error
+	UpdateModelListed(id int, listed bool) error
+	UpdateModelMetadata(id int, metadata *models.ModelMetadata) error
 	GetActiveModels() ([]models.Model, error)
+	CountActiveProviders() (int, error)
+	CountModels(providerID int) (int, error)
+	CountActiveModels() (int, error)
+	GetAliases() ([]models.Alias, error)
+	GetAlias(alias string) (*models.Alias, error)
+	UpsertAlias(alias *models.Alias) error
+	DeleteAlias(alias string) error
 	Close() error
 	ResetDatabase(databasePath string) error
 }
 
+// Ensure the SQLite storage keeps satisfying StorageInterface
+var _ StorageInterface = (*storage.Storage)(nil)
+
 // Router handles API routing and provider redirection logic
 type Router struct {
 	cfg    *config.Config
 	store  StorageInterface
 	router *gin.Engine
+
+	// limiters cap concurrent upstream requests per provider
+	limiters provider.Limiters
+
+	// firstSeen records when models listed without a stored timestamp were first seen
+	firstSeenMu sync.Mutex
+	firstSeen   map[string]time.Time
+
+	// logger writes structured entries such as per-request cost
+	logger *dbutils.Logger
+	// costs keeps the estimated spend per provider and model
+	costs *cost.Tracker
+	// balancer spreads requests over providers of equal priority
+	balancer *balancer
+	// catalog caches the live model lists of providers
+	catalog *modelCatalog
+	// keepAlive is the keep_alive sent to Ollama when a client omits it, or nil
+	keepAlive interface{}
+	// contextStrategy handles chat histories that do not fit the model's context
+	contextStrategy string
+	// health keeps the last probe result of each provider
+	health *healthCache
+	// flights coalesces identical concurrent chat and generate requests
+	flights *flightGroup
 }
 
 // NewRouter creates a new instance of Router with provider configurations
 func NewRouter(cfg *config.Config, store StorageInterface, engine *gin.Engine) *Router {
+	prices, err := cost.ParseTable(cfg.ModelPrices)
+	if err != nil {
+		fmt.Printf("NewRouter: ignoring MODEL_PRICES: %v\n", err)
+		prices = cost.Table{}
+	}
+	strategy := cfg.LoadBalancing
+	if !validBalanceStrategy(strategy) {
+		fmt.Printf("NewRouter: ignoring unknown LOAD_BALANCING %q\n", strategy)
+		strategy = balanceOff
+	}
+	contextStrategy := cfg.ContextStrategy
+	if !validContextStrategy(contextStrategy) {
+		fmt.Printf("NewRouter: ignoring unknown CONTEXT_STRATEGY %q\n", contextStrategy)
+		contextStrategy = contextOff
+	}
+	keepAlive := keepAliveValue(cfg.OllamaKeepAlive)
+	if err := provider.ValidateKeepAlive(keepAlive); err != nil {
+		fmt.Printf("NewRouter: ignoring OLLAMA_DEFAULT_KEEP_ALIVE: %v\n", err)
+		keepAlive = nil
+	}
+
 	r := &Router{
 		cfg:    cfg,
 		store:  store,
 		router: engine,
+
+		limiters: provider.NewLimiters(provider.GetProviderConfigs(), cfg.ProviderQueueTimeout),
+		costs:    cost.NewTracker(prices),
+		balancer: newBalancer(strategy),
+		catalog:  newModelCatalog(cfg.ModelCacheTTL),
+
+		keepAlive:       keepAlive,
+		contextStrategy: contextStrategy,
+		health:          newHealthCache(),
+		flights:         newFlightGroup(),
+	}
+
+	// Only trusted proxies may set the client IP through X-Forwarded-For; gin trusts every
+	// proxy unless told otherwise
+	if err := engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fmt.Printf("NewRouter: ignoring TRUSTED_PROXIES: %v\n", err)
+		engine.SetTrustedProxies(nil)
 	}
 
-	logDir := "logs"
-	loggingMiddleware := middleware.LoggingMiddleware(logDir)
+	logDir := cfg.LogDir
+	if logDir == "" {
+		logDir = "logs"
+	}
+	r.logger = newRequestLogger(cfg, logDir)
+	loggingMiddleware := middleware.LoggingMiddleware(r.logger, middleware.LoggingOptions{
+		MaxBodyBytes: cfg.LogMaxBodyBytes,
+		SkipPaths:    logSkipPaths(cfg),
+		OmitBodies:   cfg.LogOmitBodies,
+	})
+	engine.Use(middleware.RoutePrefix(cfg.RoutePrefix))
+	engine.Use(middleware.RequestID())
+	engine.Use(middleware.CORS(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders))
+	// The body limit must wrap the body before the logging middleware buffers it
+	engine.Use(middleware.BodyLimit(cfg.MaxRequestBytes))
 	engine.Use(loggingMiddleware)
+	// Recovery runs after logging so the error response of a panicking handler is logged too
+	engine.Use(middleware.Recovery(r.logger))
+	engine.Use(middleware.APIKeyAuth(cfg.GatewayAPIKeys, healthPath(cfg)))
+	engine.Use(middleware.Idempotency(cfg.IdempotencyTTL))
 
 	return r
 }
 
+// healthPath returns the path of the health check, which defaults to /health
+func healthPath(cfg *config.Config) string {
+	if cfg.HealthPath == "" {
+		return "/health"
+	}
+	return cfg.HealthPath
+}
+
+// logSkipPaths returns the paths left out of the request log, which default to the health
+// check and the metrics endpoint
+func logSkipPaths(cfg *config.Config) []string {
+	if len(cfg.LogSkipPaths) > 0 {
+		return cfg.LogSkipPaths
+	}
+	return []string{healthPath(cfg), cfg.RoutePrefix + "/metrics"}
+}
+
+// newRequestLogger builds the request logger from the LOG_LEVEL and LOG_OUTPUT settings
+func newRequestLogger(cfg *config.Config, logDir string) *dbutils.Logger {
+	logger := dbutils.NewLogger(logDir)
+	if cfg.LogLevel != "" {
+		level, err := dbutils.ParseLogLevel(cfg.LogLevel)
+		if err != nil {
+			fmt.Printf("newRequestLogger: %v, using INFO\n", err)
+		}
+		logger.SetLevel(level)
+	}
+	if cfg.LogOutput != "" {
+		if err := logger.SetOutputs(cfg.LogOutput); err != nil {
+			fmt.Printf("newRequestLogger: %v, writing to log files\n", err)
+		}
+	}
+	if logger.WritesToFile() {
+		dbutils.EnsureLogDirExists(logDir)
+	}
+	return logger
+}
+
+// Close flushes and closes the request log
+func (r *Router) Close() error {
+	return r.logger.Close()
+}
+
+// SetupRoutes registers the API routes under the configured route prefix
 func (r *Router) SetupRoutes() {
+	base := r.router.Group(r.cfg.RoutePrefix)
+	base.GET("/health/providers", r.healthProviders)
+
 	// ollama API
-	r.router.GET("/api/tags", r.listTags)
-	r.router.POST("/api/show", r.showModelWithRawBody)
+	base.GET("/api/tags", r.listTags)
+	base.GET("/metrics", r.handleMetrics)
+	base.POST("/api/show", r.showModelWithRawBody)
 
 	// API version 1 group
-	v1 := r.router.Group("/api/v1")
+	v1 := base.Group("/api/v1")
 	v1.GET("/models", r.listModels)
 	v1.POST("/chat/completions", r.handleChat)
+	v1.POST("/chat/validate", r.handleValidateChat)
+	v1.POST("/embeddings", r.handleOpenAIEmbeddings)
+	v1.POST("/completions", r.handleCompletions)
+
+	// Provider management, protected by the admin token
+	admin := v1.Group("", middleware.AdminAuth(r.cfg.AdminToken))
+	admin.GET("/providers", r.listProviders)
+	admin.GET("/providers/status", r.providersStatus)
+	admin.POST("/providers", r.createProvider)
+	admin.PUT("/providers/:id", r.updateProvider)
+	admin.DELETE("/providers/:id", r.deleteProvider)
+	admin.POST("/providers/:id/refresh", r.refreshProvider)
+	admin.PUT("/models/:id", r.updateModel)
+	admin.GET("/aliases", r.listAliases)
+	admin.POST("/aliases", r.upsertAlias)
+	admin.DELETE("/aliases/*alias", r.deleteAlias)
+	admin.GET("/costs", r.listCosts)
+	admin.GET("/stats", r.handleStats)
+	admin.GET("/logs", r.listLogs)
 
 	// New endpoints
-	r.router.POST("/api/generate", r.handleGenerate)
-	r.router.POST("/api/chat", r.handleChat)
-	r.router.GET("/api/version", r.handleVersion)
+	base.POST("/api/generate", r.handleGenerate)
+	base.POST("/api/chat", r.handleChat)
+	base.GET("/api/version", r.handleVersion)
+	base.POST("/api/embeddings", r.handleEmbeddings)
+	base.POST("/api/embed", r.handleEmbed)
+	base.POST("/api/pull", r.handlePull)
+	base.POST("/api/copy", r.handleCopy)
+	base.DELETE("/api/delete", r.handleDelete)
+	base.GET("/api/ps", r.handlePs)
+	base.POST("/api/ps", r.handlePs)
+
+	// Unknown paths and methods get the same JSON errors as the rest of the API
+	r.router.HandleMethodNotAllowed = true
+	r.router.NoRoute(handleNoRoute)
+	r.router.NoMethod(handleNoMethod)
 }
 
-// listModels retrieves and aggregates models from all active providers and local database
-func (r *Router) listModels(c *gin.Context) {
-	providers, err := r.store.GetActiveProviders()
+// modelListWorkers caps how many providers are asked for their models at once
+const modelListWorkers = 8
+
+// modelListTimeout returns how long listing models waits for each provider, which defaults to 5s
+func (r *Router) modelListTimeout() time.Duration {
+	if r.cfg.ModelListTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return r.cfg.ModelListTimeout
+}
+
+// visibleModels returns the models of a provider that should be listed to clients, from the
+// list resolveModels picks. Any model disabled in the database is hidden unless the filter
+// includes inactive ones. The returned error reports why live models could not be listed.
+// When the stored models cannot be loaded none are listed, since there is no telling which
+// ones are disabled.
+func (r *Router) visibleModels(c *gin.Context, prov *models.Provider, filter modelFilter) ([]models.Model, error) {
+	includeInactive := filter.includeInactive
+	stored, err := r.store.GetModelsByProviderID(prov.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve providers"})
-		return
+		fmt.Printf("visibleModels: failed to load stored models for %s: %v\n", prov.Name, err)
+		return nil, fmt.Errorf("failed to load stored models: %w", err)
 	}
 
-	var allModels []interface{}
-	for _, prov := range providers {
-		providerImpl := provider.CreateProvider(prov)
-		if providerImpl == nil {
-			continue
+	list, live, liveErr := r.resolveModels(c, prov, stored, filter.refresh)
+	var visible []models.Model
+	if !live {
+		for _, model := range list {
+			if model.IsActive || includeInactive {
+				visible = append(visible, model)
+			}
 		}
+		return visible, liveErr
+	}
 
-		var models []interface{}
-		m, err := providerImpl.GetModels()
-		if err == nil {
-			for _, model := range m {
-				models = append(models, gin.H{
-					"id":       model.ModelID,
-					"object":   "model",
-					"created":  0,
-					"owned_by": prov.Name,
-				})
+	byID := make(map[string]models.Model, len(stored))
+	for _, model := range stored {
+		byID[model.ModelID] = model
+	}
+	for _, model := range list {
+		// Without a stored catalog there is nothing to filter against
+		storedModel, known := byID[model.ModelID]
+		if len(stored) == 0 || (known && (storedModel.IsActive || includeInactive)) {
+			model.CreatedAt, model.UpdatedAt = storedModel.CreatedAt, storedModel.UpdatedAt
+			// Metadata set by an administrator replaces what the provider reports
+			if storedModel.Metadata != nil {
+				model.Metadata = storedModel.Metadata
 			}
+			visible = append(visible, model)
 		}
+	}
+	return visible, nil
+}
 
-		if len(models) == 0 {
-			localModels, err := r.store.GetModelsByProviderID(prov.ID)
-			if err == nil {
-				for _, model := range localModels {
-					if model.IsActive {
-						models = append(models, gin.H{
-							"id":       model.ModelID,
-							"object":   "model",
-							"created":  0,
-							"owned_by": prov.Name,
-						})
-					}
+// resolveModels picks the model list of a provider: its live list, cached unless refresh is
+// set, whenever the provider answers, even with no models, and its stored models otherwise.
+// live reports whether the live list was picked; err reports why it could not be, and is nil
+// for a disabled provider, which is never queried.
+func (r *Router) resolveModels(c *gin.Context, prov *models.Provider, stored []models.Model, refresh bool) (list []models.Model, live bool, err error) {
+	providerImpl := r.providerFor(c, prov)
+	if providerImpl == nil {
+		return stored, false, fmt.Errorf("unsupported provider type %s", prov.ProviderType())
+	}
+	if !prov.IsActive {
+		return stored, false, nil
+	}
+	list, err = r.catalog.fetch(c.Request.Context(), prov.ID, r.modelListTimeout(), refresh, providerImpl.GetModels)
+	if err != nil {
+		return stored, false, err
+	}
+	return list, true, nil
+}
+
+// listedModel is a model offered by one or more providers, which are kept in priority order
+type listedModel struct {
+	ModelID   string
+	Providers []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Metadata describes the model as served by its first provider
+	Metadata models.ModelMetadata
+}
+
+// firstSeenAt returns when a model ID was first listed, for models without a stored timestamp
+func (r *Router) firstSeenAt(modelID string) time.Time {
+	r.firstSeenMu.Lock()
+	defer r.firstSeenMu.Unlock()
+	if r.firstSeen == nil {
+		r.firstSeen = make(map[string]time.Time)
+	}
+	seen, ok := r.firstSeen[modelID]
+	if !ok {
+		seen = time.Now().UTC()
+		r.firstSeen[modelID] = seen
+	}
+	return seen
+}
+
+// modelFilter narrows the aggregated model list
+type modelFilter struct {
+	// ownedBy keeps only the models of the named providers; empty keeps every provider
+	ownedBy map[string]bool
+	// includeInactive also lists disabled models and the stored models of disabled providers
+	includeInactive bool
+	// refresh fetches live model lists instead of using the cached ones
+	refresh bool
+}
+
+// parseModelFilter reads the owned_by, active and refresh query parameters. owned_by takes a
+// comma-separated list of provider names; active defaults to true.
+func parseModelFilter(c *gin.Context) (modelFilter, error) {
+	filter := modelFilter{refresh: wantsRefresh(c)}
+	for _, name := range strings.Split(c.Query("owned_by"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			if filter.ownedBy == nil {
+				filter.ownedBy = make(map[string]bool)
+			}
+			filter.ownedBy[name] = true
+		}
+	}
+	if active := c.Query("active"); active != "" {
+		activeOnly, err := strconv.ParseBool(active)
+		if err != nil {
+			return filter, fmt.Errorf("active must be true or false")
+		}
+		filter.includeInactive = !activeOnly
+	}
+	return filter, nil
+}
+
+// dedupedModels collapses the visible models of the providers matching the filter so each
+// model ID is listed once. A provider that fails to list its models does not fail the whole
+// listing; the failure is returned as a warning naming the provider instead. Providers are
+// queried in parallel, so a slow provider delays the listing by at most the model list timeout.
+func (r *Router) dedupedModels(c *gin.Context, filter modelFilter) ([]*listedModel, []string, error) {
+	providers, err := r.store.GetActiveProviders()
+	if filter.includeInactive {
+		providers, err = r.store.GetProviders()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var selected []*models.Provider
+	for _, prov := range providers {
+		if len(filter.ownedBy) == 0 || filter.ownedBy[strings.ToLower(prov.Name)] {
+			selected = append(selected, prov)
+		}
+	}
+
+	// Results are kept by index so the merge below follows provider priority
+	visibleByProvider := make([][]models.Model, len(selected))
+	errs := make([]error, len(selected))
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, modelListWorkers)
+	for i, prov := range selected {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			visibleByProvider[i], errs[i] = r.visibleModels(c, prov, filter)
+		}()
+	}
+	wg.Wait()
+
+	var listed []*listedModel
+	var warnings []string
+	byID := make(map[string]*listedModel)
+	for i, prov := range selected {
+		if err := errs[i]; err != nil {
+			fmt.Printf("dedupedModels: provider %s failed to list models: %v\n", prov.Name, err)
+			warnings = append(warnings, fmt.Sprintf("%s: failed to list models: %v", prov.Name, err))
+		}
+		for _, model := range visibleByProvider[i] {
+			entry, ok := byID[model.ModelID]
+			if !ok {
+				entry = &listedModel{
+					ModelID:   model.ModelID,
+					CreatedAt: model.CreatedAt,
+					UpdatedAt: model.UpdatedAt,
+					Metadata:  provider.ModelMetadataFor(prov.ProviderType(), model.ModelID, model.Metadata),
+				}
+				if entry.CreatedAt.IsZero() {
+					entry.CreatedAt = r.firstSeenAt(model.ModelID)
+					entry.UpdatedAt = entry.CreatedAt
 				}
+				byID[model.ModelID] = entry
+				listed = append(listed, entry)
+			}
+			if len(entry.Providers) == 0 || entry.Providers[len(entry.Providers)-1] != prov.Name {
+				entry.Providers = append(entry.Providers, prov.Name)
 			}
 		}
-		allModels = append(allModels, models...)
 	}
+	return listed, warnings, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
+// listModels retrieves and aggregates models from all active providers and local database,
+// optionally filtered with the owned_by and active query parameters
+func (r *Router) listModels(c *gin.Context) {
+	filter, err := parseModelFilter(c)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	listed, warnings, err := r.dedupedModels(c, filter)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+
+	// Providers are listed in priority order, so the first owner is the one requests go to
+	var allModels []interface{}
+	for _, model := range listed {
+		allModels = append(allModels, gin.H{
+			"id":       model.ModelID,
+			"object":   "model",
+			"created":  model.CreatedAt.Unix(),
+			"owned_by": strings.Join(model.Providers, ","),
+		})
+	}
+
+	response := gin.H{
 		"object": "list",
 		"data":   allModels,
-	})
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (r *Router) handleChat(c *gin.Context) {
-	defer func() {
-		if rec := recover(); rec != nil {
-			errMsg := fmt.Sprintf("panic recovered in handleChat: %v", rec)
-			fmt.Println(errMsg)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
-		}
-	}()
-
 	// Read raw body first
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		fmt.Printf("handleChat: failed to read request body: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
 	// Reset body for further reading
@@ -137,81 +493,127 @@ func (r *Router) handleChat(c *gin.Context) {
 
 	// Determine provider from model in raw body
 	var temp struct {
-		Model string `json:"model"`
+		Model     string           `json:"model"`
+		Messages  []models.Message `json:"messages"`
+		KeepAlive interface{}      `json:"keep_alive"`
 	}
 	if err := json.Unmarshal(body, &temp); err != nil {
 		fmt.Printf("handleChat: invalid request body: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	providerName := r.determineProviderFromModel(temp.Model)
-	if providerName == "" {
-		fmt.Println("handleChat: unsupported model")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported model"})
+	// Messages are checked for every provider, including the raw bodies forwarded to Ollama
+	if err := provider.ValidateMessages(temp.Messages); err != nil {
+		fmt.Printf("handleChat: invalid messages: %v\n", err)
+		respondInvalidOption(c, err)
 		return
 	}
-
-	prov, err := r.store.GetProviderByName(providerName)
-	if err != nil || prov == nil {
-		fmt.Printf("handleChat: provider not found: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Provider not found"})
+	// keep_alive only matters to Ollama, but is checked for every provider and ignored by the rest
+	if err := provider.ValidateKeepAlive(temp.KeepAlive); err != nil {
+		fmt.Printf("handleChat: invalid keep_alive: %v\n", err)
+		respondInvalidOption(c, err)
 		return
 	}
 
-	if providerName == "ollama" {
-		// Forward raw body directly to Ollama
-		r.forwardOllamaRequestWithBody(c, prov, "/api/chat", body)
+	modelID, candidates, err := r.resolveModel(temp.Model)
+	if err != nil {
+		fmt.Printf("handleChat: provider lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Provider not found")
+		return
+	}
+	if len(candidates) == 0 {
+		fmt.Println("handleChat: unsupported model")
+		respondModelNotFound(c, temp.Model)
 		return
 	}
 
-	// For other providers, unmarshal into struct
-	type Message struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
+	// The first candidate is the primary provider, the rest act as fallbacks
+	prov := candidates[0]
+	if prov.ProviderType() == "ollama" {
+		// Forward raw body directly to Ollama, using its OpenAI-compatible endpoint for the v1 group
+		path := "/api/chat"
+		if middleware.IsOpenAIRoute(c) {
+			path = "/v1/chat/completions"
+		}
+		r.forwardOllamaRequestWithBody(c, prov, path, withModel(body, temp.Model, modelID))
+		return
 	}
 
+	// For other providers, unmarshal into struct
 	var requestBody struct {
-		Model    string    `json:"model"`
-		Messages []Message `json:"messages"`
+		Model    string           `json:"model"`
+		Messages []models.Message `json:"messages"`
+		Stream   bool             `json:"stream"`
 	}
 
 	if err := json.Unmarshal(body, &requestBody); err != nil {
 		fmt.Printf("handleChat: invalid request body: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	providerImpl := provider.CreateProvider(prov)
-	if providerImpl == nil {
-		fmt.Println("handleChat: unsupported provider")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported provider"})
+	// Extract recognized sampling parameters from the raw body
+	var rawParams map[string]interface{}
+	if err := json.Unmarshal(body, &rawParams); err != nil {
+		fmt.Printf("handleChat: invalid request body: %v\n", err)
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	opts := provider.FilterChatOptions(rawParams)
+
+	if err := provider.ValidateChatOptions(opts); err != nil {
+		fmt.Printf("handleChat: invalid parameter: %v\n", err)
+		respondInvalidOption(c, err)
 		return
 	}
 
-	// Convert []Message to []map[string]string for providerImpl.Chat
-	messages := make([]map[string]string, len(requestBody.Messages))
-	for i, msg := range requestBody.Messages {
-		messages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
+	messages := requestBody.Messages
+	candidates, routeErr := filterChatCandidates(requestBody.Model, modelID, candidates, messages, opts)
+	if routeErr != nil {
+		fmt.Printf("handleChat: %s\n", routeErr.message)
+		routeErr.respond(c)
+		return
+	}
+	prov = candidates[0]
+
+	messages, routeErr = r.fitContext(prov, requestBody.Model, modelID, messages, opts)
+	if routeErr != nil {
+		fmt.Printf("handleChat: %s\n", routeErr.message)
+		routeErr.respond(c)
+		return
+	}
+
+	if requestBody.Stream {
+		// Streams cannot be retried once started, so they only use the primary provider
+		providerImpl := r.providerFor(c, prov)
+		if providerImpl == nil {
+			fmt.Println("handleChat: unsupported provider")
+			middleware.RespondError(c, http.StatusBadRequest, "Unsupported provider")
+			return
 		}
+		r.streamChat(c, prov.Name, providerImpl, requestBody.Model, modelID, messages, opts)
+		return
 	}
 
-	responseContent, err := providerImpl.Chat(requestBody.Model, messages)
+	result, err := r.chatWithFallback(c, candidates, modelID, messages, opts)
 
 	if err != nil {
 		fmt.Printf("handleChat: provider chat error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUpstreamError(c, err)
 		return
 	}
 
-	// Transform response to Ollama format for non-Ollama providers
-	transformer := provider.NewOllamaResponseTransformer()
-	transformedResponse, err := transformer.TransformChatResponse(responseContent, requestBody.Model)
+	// Transform response to the OpenAI format for the v1 group and to Ollama format otherwise
+	var transformedResponse []byte
+	if middleware.IsOpenAIRoute(c) {
+		transformedResponse, err = provider.NewOpenAIResponseTransformer().TransformChatResponse(result, requestBody.Model)
+	} else {
+		transformedResponse, err = provider.NewOllamaResponseTransformer().TransformChatResponse(result, requestBody.Model)
+	}
 	if err != nil {
 		fmt.Printf("handleChat: response transformation error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transform response"})
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to transform response")
 		return
 	}
 
@@ -219,60 +621,300 @@ func (r *Router) handleChat(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json", transformedResponse)
 }
 
+// providerFor creates the provider implementation for a request, tagged with its correlation ID,
+// applying the provider's default max_tokens and system prompt and bound by its concurrency limit
+func (r *Router) providerFor(c *gin.Context, prov *models.Provider) provider.ProviderInterface {
+	providerImpl := provider.CreateProvider(prov)
+	if providerImpl == nil {
+		return nil
+	}
+	providerImpl = provider.WithRequestID(providerImpl, middleware.GetRequestID(c))
+	providerImpl = provider.WithDefaultMaxTokens(providerImpl, prov.DefaultMaxTokens)
+	providerImpl = provider.WithSystemPrompt(providerImpl, prov.SystemPrompt, prov.SystemPromptMode)
+	return provider.WithLimiter(providerImpl, r.limiters.For(prov.Name))
+}
+
+// hasImages reports whether any message carries image parts
+func hasImages(messages []models.Message) bool {
+	for _, msg := range messages {
+		if msg.HasImages() {
+			return true
+		}
+	}
+	return false
+}
+
+// chatWithFallback sends a chat request to each candidate provider in order until one responds.
+// Identical concurrent requests with a deterministic answer share one upstream call.
+func (r *Router) chatWithFallback(c *gin.Context, candidates []*models.Provider, modelID string, messages []models.Message, opts map[string]interface{}) (*provider.ChatResult, error) {
+	return r.coalesced(c, "chat", candidates, modelID, messages, opts, func() (*provider.ChatResult, error) {
+		return r.withFallback(c, candidates, modelID, messagesText(messages), func(p provider.ProviderInterface) (*provider.ChatResult, error) {
+			return p.Chat(c.Request.Context(), modelID, messages, opts)
+		})
+	})
+}
+
+// generateWithFallback sends a prompt to each candidate provider in order until one responds.
+// Identical concurrent requests with a deterministic answer share one upstream call.
+func (r *Router) generateWithFallback(c *gin.Context, candidates []*models.Provider, modelID string, prompt string, opts map[string]interface{}) (*provider.ChatResult, error) {
+	return r.coalesced(c, "generate", candidates, modelID, prompt, opts, func() (*provider.ChatResult, error) {
+		return r.withFallback(c, candidates, modelID, prompt, func(p provider.ProviderInterface) (*provider.ChatResult, error) {
+			return p.Generate(c.Request.Context(), modelID, prompt, opts)
+		})
+	})
+}
+
+// withFallback tries each candidate provider in order until call returns a response, recording
+// the cost of the response against the provider that served it; input is the request text used
+// to estimate usage when the provider reports none. When every provider fails, the returned
+// error lists each provider that was tried.
+func (r *Router) withFallback(c *gin.Context, candidates []*models.Provider, modelID string, input string, call func(provider.ProviderInterface) (*provider.ChatResult, error)) (*provider.ChatResult, error) {
+	var result *provider.ChatResult
+	err := r.tryCandidates(c, candidates, modelID, func(prov *models.Provider, providerImpl provider.ProviderInterface) error {
+		var err error
+		if result, err = call(providerImpl); err != nil {
+			return err
+		}
+		r.recordUsage(c, prov.Name, modelID, input, result.Content, result.Usage)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// tryCandidates calls call with each candidate provider in order until it succeeds. When every
+// provider fails, the returned error lists each provider that was tried.
+func (r *Router) tryCandidates(c *gin.Context, candidates []*models.Provider, modelID string, call func(*models.Provider, provider.ProviderInterface) error) error {
+	var failures []string
+	var lastErr error
+	for _, prov := range candidates {
+		providerImpl := r.providerFor(c, prov)
+		if providerImpl == nil {
+			failures = append(failures, fmt.Sprintf("%s: unsupported provider", prov.Name))
+			continue
+		}
+
+		err := call(prov, providerImpl)
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("tryCandidates: provider %s failed for model %s: %v\n", prov.Name, modelID, err)
+		// A client that has gone away needs no further fallback attempts
+		if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", prov.Name, err))
+		lastErr = err
+	}
+	return &fallbackError{
+		message: fmt.Sprintf("all providers failed for model %s: %s", modelID, strings.Join(failures, "; ")),
+		last:    lastErr,
+	}
+}
+
+// streamChat relays a provider chat stream for modelID to the client, reporting the model
+// under the name the client requested. The v1 group gets OpenAI-style server-sent events,
+// other routes get Ollama-format NDJSON chunks.
+func (r *Router) streamChat(c *gin.Context, providerName string, providerImpl provider.ProviderInterface, requested, modelID string, messages []models.Message, opts map[string]interface{}) {
+	if middleware.IsOpenAIRoute(c) {
+		r.relayStream(c, "streamChat", providerName, providerImpl, modelID, messages, opts, streamFormat{
+			transformer: provider.NewOpenAIChatStreamTransformer(requested),
+			encodeError: openAIStreamError,
+		})
+		return
+	}
+
+	r.streamNDJSON(c, "streamChat", providerName, providerImpl, modelID, messages, opts, provider.NewOllamaChatStreamTransformer(requested))
+}
+
+// streamFormat describes how a relayed stream is framed for the client
+type streamFormat struct {
+	transformer provider.StreamTransformer
+	// encodeError frames an upstream failure that happens after the stream has started
+	encodeError func(err error) []byte
+}
+
+// ndjsonStreamError frames a stream failure as an Ollama-style NDJSON error line
+func ndjsonStreamError(err error) []byte {
+	line, _ := json.Marshal(gin.H{"error": err.Error()})
+	return append(line, '\n')
+}
+
+// openAIStreamError frames a stream failure as a server-sent event carrying OpenAI's error envelope
+func openAIStreamError(err error) []byte {
+	event, _ := json.Marshal(gin.H{"error": gin.H{"message": err.Error(), "type": "api_error", "param": nil, "code": nil}})
+	return append(append([]byte("data: "), event...), '\n', '\n')
+}
+
+// streamNDJSON relays a provider chat stream to the client as NDJSON chunks of an Ollama transformer
+func (r *Router) streamNDJSON(c *gin.Context, handler string, providerName string, providerImpl provider.ProviderInterface, modelID string, messages []models.Message, opts map[string]interface{}, transformer provider.StreamTransformer) {
+	r.relayStream(c, handler, providerName, providerImpl, modelID, messages, opts, streamFormat{
+		transformer: transformer,
+		encodeError: ndjsonStreamError,
+	})
+}
+
+// relayStream relays a provider chat stream to the client in the given format.
+// Streams carry no usage, so the cost of a completed stream is estimated from its text.
+func (r *Router) relayStream(c *gin.Context, handler string, providerName string, providerImpl provider.ProviderInterface, modelID string, messages []models.Message, opts map[string]interface{}, format streamFormat) {
+	ctx := c.Request.Context()
+	start := time.Now()
+	chunks := make(chan provider.StreamChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		errCh <- providerImpl.ChatStream(ctx, modelID, messages, opts, func(chunk provider.StreamChunk) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	// Wait for the provider to answer before committing the status, so a request it rejects
+	// gets an error response with the mapped status instead of an error inside a 200 stream
+	first, open := <-chunks
+	if !open {
+		err := <-errCh
+		if err != nil {
+			fmt.Printf("%s: provider stream error: %v\n", handler, err)
+			respondUpstreamError(c, err)
+			return
+		}
+		// The stream ended without content; leave the result for the relay loop below
+		errCh <- nil
+	}
+	held := true
+
+	evalCount := 0
+	var output strings.Builder
+	var usage *models.Usage
+	c.Header("Content-Type", format.transformer.ContentType())
+	c.Status(http.StatusOK)
+	c.Stream(func(w io.Writer) bool {
+		chunk, ok := first, open
+		if held {
+			held = false
+		} else {
+			chunk, ok = <-chunks
+		}
+		if !ok {
+			// The upstream stream has ended, either normally or with an error
+			if err := <-errCh; err != nil {
+				fmt.Printf("%s: provider stream error: %v\n", handler, err)
+				w.Write(format.encodeError(err))
+				return false
+			}
+			r.recordUsage(c, providerName, modelID, messagesText(messages), output.String(), usage)
+			if usage != nil && usage.CompletionTokens > 0 {
+				evalCount = usage.CompletionTokens
+			}
+			line, err := format.transformer.TransformFinal(&provider.StreamTiming{TotalDuration: time.Since(start), EvalCount: evalCount})
+			if err == nil {
+				w.Write(line)
+			}
+			return false
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if chunk.Content == "" {
+			// Usage reports carry nothing to relay
+			return true
+		}
+
+		evalCount++
+		output.WriteString(chunk.Content)
+		line, err := format.transformer.TransformDelta(chunk.Content)
+		if err != nil {
+			fmt.Printf("%s: chunk transformation error: %v\n", handler, err)
+			return false
+		}
+		w.Write(line)
+		return true
+	})
+}
+
 // handleGenerate processes generate requests and redirects to the appropriate provider
 func (r *Router) handleGenerate(c *gin.Context) {
 	var requestBody struct {
-		Model  string                 `json:"model"`
-		Prompt string                 `json:"prompt"`
-		Params map[string]interface{} `json:"parameters"`
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+		// Stream defaults to true, as in Ollama
+		Stream    *bool       `json:"stream"`
+		KeepAlive interface{} `json:"keep_alive"`
 	}
 
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
-
-	providerName := r.determineProviderFromModel(requestBody.Model)
-	if providerName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported model"})
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := provider.ValidateKeepAlive(requestBody.KeepAlive); err != nil {
+		respondInvalidOption(c, err)
 		return
 	}
 
-	prov, err := r.store.GetProviderByName(providerName)
-	if err != nil || prov == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Provider not found"})
+	modelID, candidates, err := r.resolveModel(requestBody.Model)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Provider not found")
+		return
+	}
+	if len(candidates) == 0 {
+		respondModelNotFound(c, requestBody.Model)
 		return
 	}
 
-	if providerName == "ollama" {
-		r.forwardOllamaRequest(c, prov, "/api/generate")
+	if candidates[0].ProviderType() == "ollama" {
+		r.forwardOllamaRequestWithBody(c, candidates[0], "/api/generate", withModel(body, requestBody.Model, modelID))
 		return
 	}
 
-	providerImpl := provider.CreateProvider(prov)
-	if providerImpl == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported provider"})
+	var rawParams map[string]interface{}
+	if err := json.Unmarshal(body, &rawParams); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	opts := provider.FilterChatOptions(rawParams)
+	if err := provider.ValidateChatOptions(opts); err != nil {
+		respondInvalidOption(c, err)
 		return
 	}
 
-	// Since providerImpl does not have Generate method, use Chat with prompt wrapped as message
-	responseContent, err := providerImpl.Chat(requestBody.Model, []map[string]string{
-		{
-			"role":    "user",
-			"content": requestBody.Prompt,
-		},
-	})
+	if requestBody.Stream == nil || *requestBody.Stream {
+		// Streams cannot be retried once started, so they only use the primary provider
+		providerImpl := r.providerFor(c, candidates[0])
+		if providerImpl == nil {
+			middleware.RespondError(c, http.StatusBadRequest, "Unsupported provider")
+			return
+		}
+		// Streaming goes through chat, with the prompt as a single user message
+		messages := []models.Message{{Role: "user", Content: requestBody.Prompt}}
+		r.streamNDJSON(c, "handleGenerate", candidates[0].Name, providerImpl, modelID, messages, opts, provider.NewOllamaGenerateStreamTransformer(requestBody.Model))
+		return
+	}
 
+	result, err := r.generateWithFallback(c, candidates, modelID, requestBody.Prompt, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUpstreamError(c, err)
 		return
 	}
 
 	// Transform response to Ollama generate format for non-Ollama providers
 	transformer := provider.NewOllamaResponseTransformer()
-	transformedResponse, err := transformer.TransformGenerateResponse(responseContent, requestBody.Model)
+	transformedResponse, err := transformer.TransformGenerateResponse(result, requestBody.Model)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transform response"})
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to transform response")
 		return
 	}
 
@@ -280,142 +922,501 @@ func (r *Router) handleGenerate(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json", transformedResponse)
 }
 
-// forwardOllamaRequest forwards a request directly to Ollama
-func (r *Router) forwardOllamaRequest(c *gin.Context, prov *models.Provider, path string) {
-	var body []byte
-	var err error
-	if c.Request.Body != nil {
-		body, err = io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
-			return
-		}
-		// Log the request body for debugging
-		fmt.Printf("forwardOllamaRequest: forwarding body: %s\n", string(body))
-		// Log headers for debugging
-		for key, values := range c.Request.Header {
-			fmt.Printf("forwardOllamaRequest: header %s: %v\n", key, values)
-		}
-		// Reset the request body so it can be read again if needed
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+// handleCompletions processes OpenAI-style text completion requests
+func (r *Router) handleCompletions(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
 	}
 
-	ollamaProvider := provider.NewOllamaProvider(prov.Host)
+	var requestBody struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
 
-	headers := make(map[string]string)
-	for key, values := range c.Request.Header {
-		if len(values) > 0 {
-			headers[key] = values[0]
-		}
+	var rawParams map[string]interface{}
+	if err := json.Unmarshal(body, &rawParams); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	opts := provider.FilterChatOptions(rawParams)
+	if err := provider.ValidateChatOptions(opts); err != nil {
+		respondInvalidOption(c, err)
+		return
 	}
 
-	responseBody, statusCode, err := ollamaProvider.ForwardRequest(c.Request.Method, path, body, headers)
+	modelID, candidates, err := r.resolveModel(requestBody.Model)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, http.StatusInternalServerError, "Provider not found")
+		return
+	}
+	if len(candidates) == 0 {
+		respondModelNotFound(c, requestBody.Model)
 		return
 	}
 
-	c.Header("Content-Type", "application/json")
-	c.Data(statusCode, "application/json", responseBody)
+	if candidates[0].ProviderType() == "ollama" {
+		// Ollama serves the OpenAI-compatible completions endpoint natively
+		r.forwardOllamaRequestWithBody(c, candidates[0], "/v1/completions", withModel(body, requestBody.Model, modelID))
+		return
+	}
+
+	result, err := r.generateWithFallback(c, candidates, modelID, requestBody.Prompt, opts)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	transformer := provider.NewOpenAIResponseTransformer()
+	transformedResponse, err := transformer.TransformCompletionResponse(result, requestBody.Model)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to transform response")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", transformedResponse)
 }
 
-// forwardOllamaRequestWithBody forwards a request with a specific body to Ollama
-func (r *Router) forwardOllamaRequestWithBody(c *gin.Context, prov *models.Provider, path string, body []byte) {
-	ollamaProvider := provider.NewOllamaProvider(prov.Host)
+// handleEmbeddings processes Ollama-style embeddings requests and redirects to the appropriate provider
+func (r *Router) handleEmbeddings(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
 
-	headers := make(map[string]string)
-	for key, values := range c.Request.Header {
-		if len(values) > 0 {
-			headers[key] = values[0]
-		}
+	var requestBody struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	modelID, candidates, ok := r.routeCandidates(c, "handleEmbeddings", requestBody.Model)
+	if !ok {
+		return
 	}
 
-	responseBody, statusCode, err := ollamaProvider.ForwardRequest(c.Request.Method, path, body, headers)
+	if candidates[0].ProviderType() == "ollama" {
+		r.forwardOllamaRequestWithBody(c, candidates[0], "/api/embeddings", withModel(body, requestBody.Model, modelID))
+		return
+	}
+
+	embeddings, err := r.embedWithFallback(c, candidates, modelID, []string{requestBody.Prompt})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUpstreamError(c, err)
 		return
 	}
 
-	c.Header("Content-Type", "application/json")
-	c.Data(statusCode, "application/json", responseBody)
+	transformer := provider.NewOllamaResponseTransformer()
+	transformedResponse, err := transformer.TransformEmbeddingsResponse(embeddings[0])
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to transform response")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", transformedResponse)
 }
 
-// determineProviderFromModel retrieves the provider name associated with a model ID from the database
-func (r *Router) determineProviderFromModel(modelID string) string {
-	if modelID == "" {
-		return ""
+// handleOpenAIEmbeddings processes OpenAI-style embeddings requests, accepting a single input or a batch
+func (r *Router) handleOpenAIEmbeddings(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
 	}
 
-	providers, err := r.store.GetActiveProviders()
+	var requestBody struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	inputs, ok := embeddingInputs(requestBody.Input)
+	if !ok {
+		middleware.RespondError(c, http.StatusBadRequest, "Input must be a string or an array of strings")
+		return
+	}
+
+	modelID, candidates, ok := r.routeCandidates(c, "handleOpenAIEmbeddings", requestBody.Model)
+	if !ok {
+		return
+	}
+
+	if candidates[0].ProviderType() == "ollama" {
+		// Ollama serves the OpenAI-compatible shape natively
+		r.forwardOllamaRequestWithBody(c, candidates[0], "/v1/embeddings", withModel(body, requestBody.Model, modelID))
+		return
+	}
+
+	embeddings, err := r.embedWithFallback(c, candidates, modelID, inputs)
 	if err != nil {
-		return ""
+		respondUpstreamError(c, err)
+		return
 	}
 
-	for _, prov := range providers {
-		models, err := r.store.GetModelsByProviderID(prov.ID)
+	data := make([]gin.H, 0, len(embeddings))
+	for i, embedding := range embeddings {
+		data = append(data, gin.H{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": embedding,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+		"model":  requestBody.Model,
+	})
+}
+
+// handleEmbed processes requests to newer Ollama's /api/embed, which accepts a single input
+// or a batch and returns one embedding per input
+func (r *Router) handleEmbed(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	var requestBody struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	inputs, ok := embeddingInputs(requestBody.Input)
+	if !ok {
+		middleware.RespondError(c, http.StatusBadRequest, "Input must be a string or an array of strings")
+		return
+	}
+
+	modelID, candidates, ok := r.routeCandidates(c, "handleEmbed", requestBody.Model)
+	if !ok {
+		return
+	}
+
+	if candidates[0].ProviderType() == "ollama" {
+		r.forwardOllamaRequestWithBody(c, candidates[0], "/api/embed", withModel(body, requestBody.Model, modelID))
+		return
+	}
+
+	embeddings, err := r.embedWithFallback(c, candidates, modelID, inputs)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	transformer := provider.NewOllamaResponseTransformer()
+	transformedResponse, err := transformer.TransformEmbedResponse(embeddings, requestBody.Model)
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to transform response")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", transformedResponse)
+}
+
+// embeddingInputs parses an embeddings input, which may be either a single string or a
+// non-empty array of strings
+func embeddingInputs(raw json.RawMessage) ([]string, bool) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, true
+	}
+	var inputs []string
+	if err := json.Unmarshal(raw, &inputs); err != nil || len(inputs) == 0 {
+		return nil, false
+	}
+	return inputs, true
+}
+
+// embedWithFallback embeds every input with each candidate provider in order until one
+// embeds them all
+func (r *Router) embedWithFallback(c *gin.Context, candidates []*models.Provider, modelID string, inputs []string) ([][]float64, error) {
+	var embeddings [][]float64
+	err := r.tryCandidates(c, candidates, modelID, func(_ *models.Provider, providerImpl provider.ProviderInterface) error {
+		var err error
+		embeddings, err = embedAll(c, providerImpl, modelID, inputs)
+		return err
+	})
+	return embeddings, err
+}
+
+// embedAll embeds every input in order, failing on the first error
+func embedAll(c *gin.Context, providerImpl provider.ProviderInterface, modelID string, inputs []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(inputs))
+	for _, input := range inputs {
+		embedding, err := providerImpl.Embeddings(c.Request.Context(), modelID, input)
 		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	return embeddings, nil
+}
+
+// strippedHeaders are never forwarded upstream: hop-by-hop headers only apply to the client's
+// connection, Authorization and X-Admin-Token carry gateway credentials, Host must name the
+// upstream rather than the gateway, and Content-Length and Accept-Encoding are set by the
+// HTTP client for the rewritten body and its transparent decompression
+var strippedHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Authorization":       true,
+	"X-Admin-Token":       true,
+	"Host":                true,
+	"Content-Length":      true,
+	"Accept-Encoding":     true,
+}
+
+// forwardedHeaders returns the client headers that are safe to pass on to an upstream provider,
+// dropping strippedHeaders and any header the client's Connection header marks as hop-by-hop
+func forwardedHeaders(header http.Header) map[string]string {
+	connectionHeaders := make(map[string]bool)
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			connectionHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+
+	headers := make(map[string]string)
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		if len(values) == 0 || strippedHeaders[key] || connectionHeaders[key] {
 			continue
 		}
-		for _, model := range models {
-			if model.ModelID == modelID {
-				return prov.Name
-			}
+		headers[key] = values[0]
+	}
+	return headers
+}
+
+// ollamaClient creates a client for forwarding raw requests to an Ollama provider
+func ollamaClient(prov *models.Provider) *provider.OllamaProvider {
+	ollamaProvider := provider.NewOllamaProvider(prov.Host)
+	ollamaProvider.SetHeaders(prov.Headers)
+	provider.WithTransport(ollamaProvider, prov)
+	return ollamaProvider
+}
+
+// streamingPaths lists the Ollama endpoints that can stream, with whether they stream when
+// the request does not set stream: Ollama's own API does, its OpenAI-compatible one does not
+var streamingPaths = map[string]bool{
+	"/api/chat":            true,
+	"/api/generate":        true,
+	"/v1/chat/completions": false,
+	"/v1/completions":      false,
+}
+
+// wantsStream reports whether a request forwarded to an Ollama path asks for a streamed response
+func wantsStream(path string, body []byte) bool {
+	streamByDefault, ok := streamingPaths[path]
+	if !ok {
+		return false
+	}
+	var request struct {
+		Stream *bool `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Stream == nil {
+		return streamByDefault
+	}
+	return *request.Stream
+}
+
+// strippedResponseHeaders are not copied from an upstream response: hop-by-hop headers and the
+// body framing belong to the upstream connection, and the gateway sets its own correlation ID
+// and CORS headers
+var strippedResponseHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Content-Length":    true,
+	"X-Request-Id":      true,
+}
+
+// copyResponseHeaders passes an upstream response's headers, including its Content-Type,
+// through to the client
+func copyResponseHeaders(c *gin.Context, header http.Header) {
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		if strippedResponseHeaders[key] || strings.HasPrefix(key, "Access-Control-") {
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
 		}
 	}
+}
 
-	return ""
+// copyUpstreamStream copies an upstream response to the client with its status and headers, flushing
+// after every read so streamed chunks are not held back. It reports whether the whole body
+// was relayed.
+func copyUpstreamStream(c *gin.Context, handler string, resp *http.Response) bool {
+	copyResponseHeaders(c, resp.Header)
+	c.Status(resp.StatusCode)
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := c.Writer.Write(buf[:n]); err != nil {
+				fmt.Printf("%s: client went away: %v\n", handler, err)
+				return false
+			}
+			c.Writer.Flush()
+		}
+		if readErr == io.EOF {
+			return true
+		}
+		if readErr != nil {
+			fmt.Printf("%s: upstream stream error: %v\n", handler, readErr)
+			return false
+		}
+	}
 }
 
-// listTags retrieves and aggregates model tags from all active providers, presenting them as Ollama models
-func (r *Router) listTags(c *gin.Context) {
-	providers, err := r.store.GetActiveProviders()
+// forwardOllamaRequestWithBody forwards a request with a specific body to Ollama and relays the
+// response with its status and headers. Streaming requests are not bound by the client timeout.
+func (r *Router) forwardOllamaRequestWithBody(c *gin.Context, prov *models.Provider, path string, body []byte) {
+	body = withSystemPrompt(body, path, prov)
+	body = withKeepAlive(body, path, r.keepAlive)
+	ollamaProvider := ollamaClient(prov)
+	forward := ollamaProvider.ForwardResponse
+	if wantsStream(path, body) {
+		forward = ollamaProvider.ForwardStream
+	}
+
+	resp, err := forward(c.Request.Context(), c.Request.Method, path, body, forwardedHeaders(c.Request.Header))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve providers"})
+		respondUpstreamError(c, err)
 		return
 	}
+	defer resp.Body.Close()
+	copyUpstreamStream(c, "forwardOllamaRequestWithBody", resp)
+}
 
-	var allModels []interface{}
+// withSystemPrompt applies the provider's system prompt to a raw chat or generate request body
+// forwarded to Ollama. Other requests, and bodies that cannot be decoded, are left as they are.
+func withSystemPrompt(body []byte, path string, prov *models.Provider) []byte {
+	if prov.SystemPrompt == "" {
+		return body
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
 
-	for _, prov := range providers {
-		providerImpl := provider.CreateProvider(prov)
-		if providerImpl == nil {
-			continue
+	switch path {
+	case "/api/chat", "/v1/chat/completions":
+		messages, _ := payload["messages"].([]interface{})
+		var client []string
+		leading := 0
+		for ; leading < len(messages); leading++ {
+			msg, _ := messages[leading].(map[string]interface{})
+			if msg == nil || msg["role"] != "system" {
+				break
+			}
+			client = append(client, messageText(msg["content"]))
 		}
 
-		var models []interface{}
-		m, err := providerImpl.GetModels()
-		if err == nil {
-			for _, model := range m {
-				models = append(models, gin.H{
-					"name":        model.ModelID,
-					"modified_at": "1970-01-01T00:00:00.000Z",
-					"size":        0,
-					"digest":      "",
-				})
+		merged := []interface{}{map[string]interface{}{
+			"role":    "system",
+			"content": provider.MergeSystemPrompt(prov.SystemPrompt, prov.SystemPromptMode, client),
+		}}
+		for _, msg := range messages[leading:] {
+			if m, ok := msg.(map[string]interface{}); ok && m["role"] == "system" && prov.SystemPromptMode == provider.SystemPromptOverride {
+				continue
 			}
+			merged = append(merged, msg)
 		}
+		payload["messages"] = merged
+	case "/api/generate":
+		var client []string
+		if system, ok := payload["system"].(string); ok {
+			client = append(client, system)
+		}
+		payload["system"] = provider.MergeSystemPrompt(prov.SystemPrompt, prov.SystemPromptMode, client)
+	default:
+		return body
+	}
 
-		if len(models) == 0 {
-			localModels, err := r.store.GetModelsByProviderID(prov.ID)
-			if err == nil {
-				for _, model := range localModels {
-					if model.IsActive {
-						models = append(models, gin.H{
-							"name":        model.ModelID,
-							"modified_at": "1970-01-01T00:00:00.000Z",
-							"size":        0,
-							"digest":      "",
-						})
-					}
-				}
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// messageText returns the text of a raw message content, which is a string or a list of content parts
+func messageText(content interface{}) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+	parts, _ := content.([]interface{})
+	var texts []string
+	for _, part := range parts {
+		if p, ok := part.(map[string]interface{}); ok && p["type"] == "text" {
+			if text, ok := p["text"].(string); ok {
+				texts = append(texts, text)
 			}
 		}
-		allModels = append(allModels, models...)
 	}
+	return strings.Join(texts, "\n")
+}
 
-	c.JSON(http.StatusOK, gin.H{
+// listTags retrieves and aggregates model tags from all active providers, presenting them as Ollama models
+func (r *Router) listTags(c *gin.Context) {
+	listed, warnings, err := r.dedupedModels(c, modelFilter{refresh: wantsRefresh(c)})
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+
+	// owned_by is not part of Ollama's format; Ollama clients ignore it
+	var allModels []interface{}
+	for _, model := range listed {
+		allModels = append(allModels, gin.H{
+			"name":        model.ModelID,
+			"model":       model.ModelID,
+			"modified_at": model.UpdatedAt.Format(time.RFC3339Nano),
+			"size":        0,
+			"digest":      "",
+			"details":     ollamaDetails(model.Metadata),
+			"owned_by":    strings.Join(model.Providers, ","),
+		})
+	}
+
+	response := gin.H{
 		"models": allModels,
-	})
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // showModelWithRawBody handles the /api/show endpoint by forwarding to Ollama
@@ -424,7 +1425,7 @@ func (r *Router) showModelWithRawBody(c *gin.Context) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		fmt.Printf("showModelWithRawBody: failed to read request body: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
 
@@ -434,60 +1435,163 @@ func (r *Router) showModelWithRawBody(c *gin.Context) {
 	}
 	if err := json.Unmarshal(body, &temp); err != nil {
 		fmt.Printf("showModelWithRawBody: invalid request body: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	providerName := r.determineProviderFromModel(temp.Name)
-	if providerName == "" {
-		fmt.Println("showModelWithRawBody: unsupported model")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported model"})
+	prov, modelID, ok := r.primaryRoute(c, "showModelWithRawBody", temp.Name)
+	if !ok {
 		return
 	}
 
-	prov, err := r.store.GetProviderByName(providerName)
-	if err != nil || prov == nil {
-		fmt.Printf("showModelWithRawBody: provider not found: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Provider not found"})
+	if prov.ProviderType() == "ollama" {
+		// Forward raw body directly to Ollama
+		r.forwardOllamaRequestWithBody(c, prov, "/api/show", withModel(body, temp.Name, modelID))
 		return
 	}
 
-	if providerName == "ollama" {
-		// Forward raw body directly to Ollama
-		r.forwardOllamaRequestWithBody(c, prov, "/api/show", body)
+	// For non-Ollama providers, return a response matching Ollama API format, describing the
+	// model with its stored metadata and the defaults known for it
+	var stored *models.ModelMetadata
+	if model := r.storedModel(prov.ID, modelID); model != nil {
+		stored = model.Metadata
+	}
+	metadata := provider.ModelMetadataFor(prov.ProviderType(), modelID, stored)
+	c.JSON(http.StatusOK, gin.H{
+		"license":      "",
+		"modelfile":    fmt.Sprintf("# Model: %s\n# Provider: %s", temp.Name, prov.Name),
+		"parameters":   "",
+		"template":     "",
+		"details":      ollamaDetails(metadata),
+		"model_info":   ollamaModelInfo(metadata),
+		"capabilities": metadata.Capabilities,
+	})
+}
+
+// ollamaModelInfo returns the model_info object Ollama reports for a model in /api/show. Its
+// context length is keyed by architecture, which is "general" when the family is unknown.
+func ollamaModelInfo(metadata models.ModelMetadata) gin.H {
+	architecture := metadata.Family
+	if architecture == "" {
+		architecture = "general"
+	}
+	return gin.H{
+		"general.architecture":           metadata.Family,
+		architecture + ".context_length": metadata.ContextLength,
+	}
+}
+
+// ollamaDetails returns the details object Ollama reports for a model in /api/tags and /api/show
+func ollamaDetails(metadata models.ModelMetadata) gin.H {
+	families := []string{}
+	if metadata.Family != "" {
+		families = append(families, metadata.Family)
+	}
+	return gin.H{
+		"parent_model":       "",
+		"format":             "",
+		"family":             metadata.Family,
+		"families":           families,
+		"parameter_size":     metadata.ParameterSize,
+		"quantization_level": metadata.QuantizationLevel,
+	}
+}
+
+// storedModel returns the stored model of a provider with the given model ID, or nil when
+// there is none
+func (r *Router) storedModel(providerID int, modelID string) *models.Model {
+	stored, err := r.store.GetModelsByProviderID(providerID)
+	if err != nil {
+		fmt.Printf("storedModel: failed to load models of provider %d: %v\n", providerID, err)
+		return nil
+	}
+	for i := range stored {
+		if stored[i].ModelID == modelID {
+			return &stored[i]
+		}
+	}
+	return nil
+}
+
+// remoteModelKeepAlive is how far in the future remote models are reported to expire by /api/ps.
+// Remote models are always available, so this only needs to look plausible to Ollama clients.
+const remoteModelKeepAlive = 24 * time.Hour
+
+// handlePs handles the /api/ps endpoint, listing running Ollama models and active remote models
+func (r *Router) handlePs(c *gin.Context) {
+	providers, err := r.store.GetActiveProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
 		return
 	}
 
-	// For non-Ollama providers, return a response matching Ollama API format
+	running := []interface{}{}
+	expiresAt := time.Now().Add(remoteModelKeepAlive).Format(time.RFC3339)
+
+	for _, prov := range providers {
+		if prov.ProviderType() == "ollama" {
+			// Ollama knows which of its models are actually loaded
+			ollamaProvider := ollamaClient(prov)
+			headers := map[string]string{middleware.RequestIDHeader: middleware.GetRequestID(c)}
+			responseBody, statusCode, err := ollamaProvider.ForwardRequest(c.Request.Context(), http.MethodGet, "/api/ps", nil, headers)
+			if err != nil || statusCode != http.StatusOK {
+				fmt.Printf("handlePs: failed to query Ollama: status %d, error %v\n", statusCode, err)
+				continue
+			}
+			var psResp struct {
+				Models []json.RawMessage `json:"models"`
+			}
+			if err := json.Unmarshal(responseBody, &psResp); err != nil {
+				fmt.Printf("handlePs: invalid Ollama response: %v\n", err)
+				continue
+			}
+			for _, m := range psResp.Models {
+				running = append(running, m)
+			}
+			continue
+		}
+
+		localModels, err := r.store.GetModelsByProviderID(prov.ID)
+		if err != nil {
+			continue
+		}
+		for _, model := range localModels {
+			if !model.IsActive {
+				continue
+			}
+			running = append(running, gin.H{
+				"name":   model.ModelID,
+				"model":  model.ModelID,
+				"size":   0,
+				"digest": "",
+				"details": gin.H{
+					"parent_model":       "",
+					"format":             "",
+					"family":             prov.Name,
+					"families":           []string{prov.Name},
+					"parameter_size":     "",
+					"quantization_level": "",
+				},
+				"expires_at": expiresAt,
+				"size_vram":  0,
+			})
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"license":    "",
-		"modelfile":  fmt.Sprintf("# Model: %s\n# Provider: %s", temp.Name, providerName),
-		"parameters": "",
-		"template":   "",
-		"details": gin.H{
-			"parent_model":       "",
-			"format":             "gguf",
-			"family":             "llama",
-			"families":           []string{"llama"},
-			"parameter_size":     "7B",
-			"quantization_level": "Q4_0",
-		},
-		"model_info": gin.H{
-			"general.architecture":       "llama",
-			"general.file_type":          2,
-			"general.parameter_count":    7000000000,
-			"llama.context_length":       128000,
-			"llama.embedding_length":     128000,
-			"llama.block_count":          32,
-			"llama.attention.head_count": 32,
-		},
-		"capabilities": []string{"completion", "tools"},
+		"models": running,
 	})
 }
 
-// handleVersion handles the /api/version endpoint
+// handleVersion handles the /api/version endpoint. version is the Ollama version reported to
+// clients, and allama_version the gateway's own build.
 func (r *Router) handleVersion(c *gin.Context) {
+	version := r.cfg.OllamaReportedVersion
+	if version == "" {
+		version = config.DefaultOllamaVersion
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"version": "0.1.0",
+		"version":        version,
+		"allama_version": config.Version,
 	})
 }
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+)
+
+// handleStats returns how many providers and models are configured
+func (r *Router) handleStats(c *gin.Context) {
+	activeProviders, err := r.store.CountActiveProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to count providers")
+		return
+	}
+	activeModels, err := r.store.CountActiveModels()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to count models")
+		return
+	}
+	providers, err := r.store.GetProviders()
+	if err != nil {
+		middleware.RespondError(c, http.StatusInternalServerError, "Failed to retrieve providers")
+		return
+	}
+
+	perProvider := make([]gin.H, 0, len(providers))
+	for _, p := range providers {
+		count, err := r.store.CountModels(p.ID)
+		if err != nil {
+			middleware.RespondError(c, http.StatusInternalServerError, "Failed to count models")
+			return
+		}
+		perProvider = append(perProvider, gin.H{
+			"id":        p.ID,
+			"name":      p.Name,
+			"is_active": p.IsActive,
+			"models":    count,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active_providers": activeProviders,
+		"active_models":    activeModels,
+		"providers":        perProvider,
+	})
+}
+
+// writeInventoryMetrics writes the numbers of active providers and models as Prometheus
+// gauges. A count that cannot be read is left out rather than failing the whole scrape.
+func (r *Router) writeInventoryMetrics(w io.Writer) {
+	if count, err := r.store.CountActiveProviders(); err != nil {
+		fmt.Printf("writeInventoryMetrics: counting providers: %v\n", err)
+	} else {
+		fmt.Fprintln(w, "# HELP allama_active_providers Active providers.")
+		fmt.Fprintln(w, "# TYPE allama_active_providers gauge")
+		fmt.Fprintf(w, "allama_active_providers %d\n", count)
+	}
+	if count, err := r.store.CountActiveModels(); err != nil {
+		fmt.Printf("writeInventoryMetrics: counting models: %v\n", err)
+	} else {
+		fmt.Fprintln(w, "# HELP allama_active_models Active models across all providers.")
+		fmt.Fprintln(w, "# TYPE allama_active_models gauge")
+		fmt.Fprintf(w, "allama_active_models %d\n", count)
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/provider"
+)
+
+// routeError explains why no candidate provider can serve a chat request
+type routeError struct {
+	status  int
+	code    string
+	message string
+}
+
+// respond aborts the request with the routing error
+func (e *routeError) respond(c *gin.Context) {
+	middleware.RespondErrorCode(c, e.status, e.code, e.message)
+}
+
+// filterChatCandidates narrows the providers serving a model, in order, to those that can honor
+// the request's image inputs and response format
+func filterChatCandidates(requested, modelID string, candidates []*models.Provider, messages []models.Message, opts map[string]interface{}) ([]*models.Provider, *routeError) {
+	// Image inputs are only routed to providers whose model advertises vision support
+	if hasImages(messages) {
+		var visionCandidates []*models.Provider
+		for _, candidate := range candidates {
+			if provider.SupportsVision(candidate.ProviderType(), modelID) {
+				visionCandidates = append(visionCandidates, candidate)
+			}
+		}
+		if len(visionCandidates) == 0 {
+			return nil, &routeError{http.StatusBadRequest, "", fmt.Sprintf("Model %s does not support image inputs", requested)}
+		}
+		candidates = visionCandidates
+	}
+
+	// A JSON schema is only routed to providers that can enforce it
+	var formatCandidates []*models.Provider
+	for _, candidate := range candidates {
+		if provider.SupportsResponseFormat(candidate.ProviderType(), opts) {
+			formatCandidates = append(formatCandidates, candidate)
+		}
+	}
+	if len(formatCandidates) == 0 {
+		return nil, &routeError{http.StatusBadRequest, "unsupported_response_format", fmt.Sprintf("Model %s does not support response_format json_schema; use json_object instead", requested)}
+	}
+	return formatCandidates, nil
+}
+
+// handleValidateChat routes a chat completion request without calling the upstream, so clients
+// can check a request before spending tokens. It reports the provider that would serve it, the
+// fallbacks, the model ID sent upstream and the normalized options.
+func (r *Router) handleValidateChat(c *gin.Context) {
+	var requestBody struct {
+		Model    string           `json:"model"`
+		Messages []models.Message `json:"messages"`
+		Stream   bool             `json:"stream"`
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	var rawParams map[string]interface{}
+	if err := json.Unmarshal(body, &requestBody); err != nil || json.Unmarshal(body, &rawParams) != nil {
+		middleware.RespondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if requestBody.Model == "" {
+		middleware.RespondErrorCode(c, http.StatusBadRequest, "missing_model", "model is required")
+		return
+	}
+	if len(requestBody.Messages) == 0 {
+		middleware.RespondErrorCode(c, http.StatusBadRequest, "missing_messages", "messages is required")
+		return
+	}
+	if err := provider.ValidateMessages(requestBody.Messages); err != nil {
+		respondInvalidOption(c, err)
+		return
+	}
+
+	opts := provider.FilterChatOptions(rawParams)
+	if err := provider.ValidateChatOptions(opts); err != nil {
+		respondInvalidOption(c, err)
+		return
+	}
+
+	modelID, candidates, err := r.resolveModel(requestBody.Model)
+	if err != nil {
+		fmt.Printf("handleValidateChat: provider lookup failed: %v\n", err)
+		middleware.RespondError(c, http.StatusInternalServerError, "Provider not found")
+		return
+	}
+	if len(candidates) == 0 {
+		// The request itself is what is wrong here, so unlike chat this is not a 404
+		middleware.RespondErrorCode(c, http.StatusBadRequest, "model_not_found", fmt.Sprintf("model '%s' not found", requestBody.Model))
+		return
+	}
+	// Ollama receives the raw request, so only other providers are filtered by capability
+	if candidates[0].ProviderType() != "ollama" {
+		var routeErr *routeError
+		candidates, routeErr = filterChatCandidates(requestBody.Model, modelID, candidates, requestBody.Messages, opts)
+		if routeErr != nil {
+			routeErr.respond(c)
+			return
+		}
+	}
+
+	// Streams only use the primary provider
+	fallbacks := []string{}
+	if !requestBody.Stream {
+		for _, candidate := range candidates[1:] {
+			fallbacks = append(fallbacks, candidate.Name)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":          true,
+		"model":          requestBody.Model,
+		"upstream_model": modelID,
+		"provider":       candidates[0].Name,
+		"provider_type":  candidates[0].ProviderType(),
+		"fallbacks":      fallbacks,
+		"stream":         requestBody.Stream,
+		"params":         opts,
+	})
+}
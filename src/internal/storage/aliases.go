@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// GetAliases retrieves all model aliases ordered by name
+func (s *Storage) GetAliases() ([]models.Alias, error) {
+	rows, err := s.db.Query("SELECT id, alias, model_id, provider, copied FROM aliases ORDER BY alias")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []models.Alias
+	for rows.Next() {
+		var a models.Alias
+		if err := rows.Scan(&a.ID, &a.Alias, &a.ModelID, &a.Provider, &a.Copied); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, nil
+}
+
+// GetAlias retrieves the alias for a model name, or nil when the name is not aliased
+func (s *Storage) GetAlias(alias string) (*models.Alias, error) {
+	a := &models.Alias{}
+	err := s.db.QueryRow(
+		"SELECT id, alias, model_id, provider, copied FROM aliases WHERE alias = ?",
+		alias,
+	).Scan(&a.ID, &a.Alias, &a.ModelID, &a.Provider, &a.Copied)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// UpsertAlias creates an alias or, if the name is already aliased, replaces its target
+func (s *Storage) UpsertAlias(alias *models.Alias) error {
+	existing, err := s.GetAlias(alias.Alias)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		alias.ID = existing.ID
+		_, err := s.db.Exec(
+			"UPDATE aliases SET model_id = ?, provider = ?, copied = ? WHERE id = ?",
+			alias.ModelID, alias.Provider, alias.Copied, alias.ID,
+		)
+		return err
+	}
+
+	id, err := s.db.insertID(
+		"INSERT INTO aliases (alias, model_id, provider, copied) VALUES (?, ?, ?, ?)",
+		alias.Alias, alias.ModelID, alias.Provider, alias.Copied,
+	)
+	if err != nil {
+		return err
+	}
+	alias.ID = id
+	return nil
+}
+
+// DeleteAlias removes an alias, returning ErrNotFound when the name is not aliased
+func (s *Storage) DeleteAlias(alias string) error {
+	result, err := s.db.Exec("DELETE FROM aliases WHERE alias = ?", alias)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+func TestAliasCRUD(t *testing.T) {
+	store := newTestStorage(t)
+
+	alias := &models.Alias{Alias: "gpt-4", ModelID: "gpt-4o", Copied: true}
+	if err := store.UpsertAlias(alias); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if alias.ID == 0 {
+		t.Error("Expected the alias ID to be set")
+	}
+	if copied, err := store.GetAlias("gpt-4"); err != nil || copied == nil || !copied.Copied {
+		t.Fatalf("Expected the alias to be marked as copied, got %+v (err %v)", copied, err)
+	}
+
+	// Upserting the same name replaces the target
+	if err := store.UpsertAlias(&models.Alias{Alias: "gpt-4", ModelID: "claude-3-5-sonnet", Provider: "anthropic"}); err != nil {
+		t.Fatalf("Failed to update alias: %v", err)
+	}
+	fetched, err := store.GetAlias("gpt-4")
+	if err != nil || fetched == nil {
+		t.Fatalf("Expected alias to be found, got %+v (err %v)", fetched, err)
+	}
+	if fetched.ID != alias.ID || fetched.ModelID != "claude-3-5-sonnet" || fetched.Provider != "anthropic" || fetched.Copied {
+		t.Errorf("Expected the alias to be updated in place, got %+v", fetched)
+	}
+
+	if missing, err := store.GetAlias("gpt-3"); err != nil || missing != nil {
+		t.Errorf("Expected no alias for gpt-3, got %+v (err %v)", missing, err)
+	}
+
+	aliases, err := store.GetAliases()
+	if err != nil || len(aliases) != 1 {
+		t.Fatalf("Expected one alias, got %+v (err %v)", aliases, err)
+	}
+
+	if err := store.DeleteAlias("gpt-4"); err != nil {
+		t.Fatalf("Failed to delete alias: %v", err)
+	}
+	if err := store.DeleteAlias("gpt-4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting a missing alias, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialect captures the SQL differences between the supported database drivers
+type dialect struct {
+	driver string
+	// numberedPlaceholders selects $1-style bind parameters instead of ?
+	numberedPlaceholders bool
+	// primaryKey is the column definition for an auto-incrementing integer ID
+	primaryKey string
+}
+
+var dialects = map[string]dialect{
+	"sqlite3": {
+		driver:     "sqlite3",
+		primaryKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	},
+	"postgres": {
+		driver:               "postgres",
+		numberedPlaceholders: true,
+		primaryKey:           "SERIAL PRIMARY KEY",
+	},
+}
+
+// dialectFor returns the dialect for a configured driver name
+func dialectFor(driver string) (dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return dialect{}, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	return d, nil
+}
+
+// rebind rewrites ? placeholders into the dialect's bind parameter style.
+// Queries in this package never contain a literal ? inside string constants.
+func (d dialect) rebind(query string) string {
+	if !d.numberedPlaceholders || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dbConn wraps a database handle and rewrites queries for its dialect
+type dbConn struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (c *dbConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(c.dialect.rebind(query), args...)
+}
+
+func (c *dbConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(c.dialect.rebind(query), args...)
+}
+
+func (c *dbConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(c.dialect.rebind(query), args...)
+}
+
+func (c *dbConn) Begin() (*dbTx, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &dbTx{Tx: tx, dialect: c.dialect}, nil
+}
+
+// insertID runs an INSERT and returns the ID of the new row. Postgres does not
+// support LastInsertId, so the ID is read back with RETURNING instead.
+func (c *dbConn) insertID(query string, args ...interface{}) (int, error) {
+	if c.dialect.driver == "postgres" {
+		var id int
+		err := c.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := c.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// dbTx wraps a transaction and rewrites queries for its dialect
+type dbTx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (t *dbTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *dbTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *dbTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), args...)
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+)
+
+// migration is a single, ordered schema change
+type migration struct {
+	version int
+	name    string
+	up      func(tx *dbTx) error
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Append new migrations to the end; never edit or reorder applied ones.
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "index models by model_id", migrateModelIDIndex},
+	{3, "add provider type", migrateProviderType},
+	{4, "create aliases", migrateAliases},
+	{5, "make models unique per provider", migrateUniqueProviderModels},
+	{6, "add provider headers", migrateProviderHeaders},
+	{7, "add model timestamps", migrateModelTimestamps},
+	{8, "add provider default max tokens", migrateProviderDefaultMaxTokens},
+	{9, "add provider system prompt", migrateProviderSystemPrompt},
+	{10, "add provider priority and weight", migrateProviderPriority},
+	{11, "add model metadata", migrateModelMetadata},
+	{12, "add provider proxy", migrateProviderProxy},
+	{13, "add provider TLS settings", migrateProviderTLS},
+	{14, "add model unlisted flag", migrateModelUnlisted},
+	{15, "add alias copied flag", migrateAliasCopied},
+}
+
+// migrate creates the schema_migrations table and applies any migrations not yet recorded in it
+func migrate(db *dbConn) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Applied database migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+// appliedMigrations returns the set of migration versions already recorded
+func appliedMigrations(db *dbConn) (map[int]bool, error) {
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs a migration and records it in a single transaction
+func applyMigration(db *dbConn, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateInitialSchema creates the providers and models tables. It tolerates databases
+// created before migrations were tracked, which already contain both tables.
+func migrateInitialSchema(tx *dbTx) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS providers (
+			id %s,
+			name TEXT NOT NULL,
+			api_key TEXT,
+			host TEXT,
+			is_active BOOLEAN DEFAULT true
+		);
+	`, tx.dialect.primaryKey))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS models (
+			id %s,
+			provider_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			model_id TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT true,
+			FOREIGN KEY (provider_id) REFERENCES providers(id)
+		);
+	`, tx.dialect.primaryKey))
+	return err
+}
+
+// migrateModelIDIndex indexes model lookups by model ID
+func migrateModelIDIndex(tx *dbTx) error {
+	_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_models_model_id ON models(model_id);")
+	return err
+}
+
+// migrateProviderType adds the provider type column, backfilled from the name since every
+// provider used to be named after its type, and makes provider names unique
+func migrateProviderType(tx *dbTx) error {
+	if _, err := tx.Exec("ALTER TABLE providers ADD COLUMN type TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE providers SET type = name WHERE type = ''"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_providers_name ON providers(name);")
+	return err
+}
+
+// migrateAliases creates the table mapping requested model names to their targets
+func migrateAliases(tx *dbTx) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS aliases (
+			id %s,
+			alias TEXT NOT NULL UNIQUE,
+			model_id TEXT NOT NULL,
+			provider TEXT NOT NULL DEFAULT ''
+		);
+	`, tx.dialect.primaryKey))
+	return err
+}
+
+// migrateUniqueProviderModels removes duplicate models left by older releases, which
+// appended on every refresh, and enforces one row per (provider_id, model_id)
+func migrateUniqueProviderModels(tx *dbTx) error {
+	_, err := tx.Exec("DELETE FROM models WHERE id NOT IN (SELECT MIN(id) FROM models GROUP BY provider_id, model_id)")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_models_provider_model ON models(provider_id, model_id);")
+	return err
+}
+
+// migrateProviderHeaders adds the JSON-encoded custom headers sent with every upstream request
+func migrateProviderHeaders(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE providers ADD COLUMN headers TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// migrateModelTimestamps records when models were stored and last changed. SQLite cannot add
+// a column defaulting to CURRENT_TIMESTAMP, so existing rows are backfilled instead.
+func migrateModelTimestamps(tx *dbTx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE models ADD COLUMN created_at TIMESTAMP",
+		"ALTER TABLE models ADD COLUMN updated_at TIMESTAMP",
+		"UPDATE models SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateProviderDefaultMaxTokens adds the max_tokens sent when a client does not set one
+func migrateProviderDefaultMaxTokens(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE providers ADD COLUMN default_max_tokens INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+// migrateProviderSystemPrompt adds the system prompt enforced on every chat request and how it
+// is merged with the client's system messages
+func migrateProviderSystemPrompt(tx *dbTx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE providers ADD COLUMN system_prompt TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE providers ADD COLUMN system_prompt_mode TEXT NOT NULL DEFAULT ''",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateProviderPriority adds the priority and weight used to choose among providers serving the same model
+func migrateProviderPriority(tx *dbTx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE providers ADD COLUMN priority INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE providers ADD COLUMN weight INTEGER NOT NULL DEFAULT 1",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateModelMetadata stores per-model metadata overrides as JSON, empty when there are none
+func migrateModelMetadata(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE models ADD COLUMN metadata TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// migrateProviderProxy adds the proxy upstream requests go through, empty for the environment's
+func migrateProviderProxy(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE providers ADD COLUMN proxy TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// migrateProviderTLS adds the JSON-encoded TLS settings, empty for the defaults
+func migrateProviderTLS(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE providers ADD COLUMN tls TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// migrateModelUnlisted adds the flag marking models deactivated because their provider
+// stopped listing them
+func migrateModelUnlisted(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE models ADD COLUMN unlisted BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
+
+// migrateAliasCopied adds the flag marking aliases created by copying a model
+func migrateAliasCopied(tx *dbTx) error {
+	_, err := tx.Exec("ALTER TABLE aliases ADD COLUMN copied BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/offbeat-studio/allama/internal/config"
+)
+
+func TestMigrationsAreRecordedOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allama.db")
+
+	for i := 0; i < 2; i++ {
+		store, err := NewStorage(&config.Config{DatabasePath: path})
+		if err != nil {
+			t.Fatalf("Failed to open storage (run %d): %v", i, err)
+		}
+
+		var count int
+		if err := store.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count); err != nil {
+			t.Fatalf("Failed to count migrations: %v", err)
+		}
+		if count != len(migrations) {
+			t.Errorf("Expected %d recorded migrations, got %d", len(migrations), count)
+		}
+		store.Close()
+	}
+}
+
+func TestMigrateUntrackedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allama.db")
+
+	// A database created before migrations existed has the tables and data but no schema_migrations
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE providers (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, api_key TEXT, host TEXT, is_active BOOLEAN DEFAULT true);
+		CREATE TABLE models (id INTEGER PRIMARY KEY AUTOINCREMENT, provider_id INTEGER NOT NULL, name TEXT NOT NULL, model_id TEXT NOT NULL, is_active BOOLEAN DEFAULT true);
+		INSERT INTO providers (name, api_key, host, is_active) VALUES ('openai', '', 'https://api.openai.com', true);
+		INSERT INTO models (provider_id, name, model_id, is_active) VALUES (1, 'gpt-4o', 'gpt-4o', true), (1, 'gpt-4o', 'gpt-4o', true);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	store, err := NewStorage(&config.Config{DatabasePath: path})
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	defer store.Close()
+
+	prov, err := store.GetProviderByName("openai")
+	if err != nil || prov == nil {
+		t.Fatalf("Expected existing provider to survive migration, got %+v (err %v)", prov, err)
+	}
+	if prov.Type != "openai" {
+		t.Errorf("Expected the provider type to be backfilled from its name, got %q", prov.Type)
+	}
+
+	modelList, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil || len(modelList) != 1 {
+		t.Errorf("Expected duplicate models to be collapsed into one, got %+v (err %v)", modelList, err)
+	}
+}
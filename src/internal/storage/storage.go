@@ -2,67 +2,142 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/offbeat-studio/allama/internal/config"
 	"github.com/offbeat-studio/allama/internal/models"
 )
 
+// ErrNotFound is returned when an update targets a row that does not exist
+var ErrNotFound = errors.New("not found")
+
 // Storage represents the database connection and operations
 type Storage struct {
-	db *sql.DB
+	db  *dbConn
+	cfg *config.Config
+
+	// modelProviders caches the providers serving each model ID. Entries are dropped when this
+	// process writes providers or models, and expire after providerCacheTTL so that writes by
+	// other instances sharing the database are picked up.
+	cacheMu        sync.RWMutex
+	cacheGen       uint64
+	modelProviders map[string]cachedProviders
 }
 
-// NewStorage initializes a new database connection and creates necessary tables
+// providerCacheTTL is how long a cached model lookup is used before the database is asked again
+const providerCacheTTL = 30 * time.Second
+
+// providerCacheSize caps the number of model IDs whose providers are cached
+const providerCacheSize = 1024
+
+// cachedProviders is a cached model lookup
+type cachedProviders struct {
+	providers []*models.Provider
+	expires   time.Time
+}
+
+// NewStorage initializes a new database connection and applies pending migrations
 func NewStorage(cfg *config.Config) (*Storage, error) {
-	db, err := sql.Open("sqlite3", cfg.DatabasePath)
+	db, err := openDB(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create tables if they don't exist
-	if err := createTables(db); err != nil {
+	// Bring the schema up to date
+	if err := migrate(db); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, cfg: cfg}, nil
 }
 
-// createTables sets up the database schema
-func createTables(db *sql.DB) error {
-	// Create providers table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS providers (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			api_key TEXT,
-			host TEXT,
-			is_active BOOLEAN DEFAULT true
-		);
-	`)
+// openDB opens the database configured by DATABASE_DRIVER. SQLite uses the
+// DATABASE_PATH file while other drivers connect to DATABASE_URL.
+func openDB(cfg *config.Config) (*dbConn, error) {
+	driver := cfg.DatabaseDriver
+	if driver == "" {
+		driver = "sqlite3"
+	}
+	d, err := dialectFor(driver)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	dsn := cfg.DatabaseURL
+	if driver == "sqlite3" {
+		dsn = cfg.DatabasePath
+		if err := ensureDatabaseDir(dsn); err != nil {
+			return nil, err
+		}
+		dsn = sqliteDSN(dsn)
 	}
 
-	// Create models table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS models (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			provider_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			model_id TEXT NOT NULL,
-			is_active BOOLEAN DEFAULT true,
-			FOREIGN KEY (provider_id) REFERENCES providers(id)
-		);
-	`)
+	db, err := sql.Open(d.driver, dsn)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if driver == "sqlite3" {
+		db.SetMaxOpenConns(sqliteMaxOpenConns)
+	}
+	return &dbConn{DB: db, dialect: d}, nil
+}
 
-	return nil
+// sqliteMaxOpenConns caps the SQLite connection pool. WAL lets readers proceed alongside the
+// single writer, and a small pool keeps writers from queueing on the busy timeout for long.
+const sqliteMaxOpenConns = 4
+
+// sqliteDSN adds the connection settings every SQLite connection needs under concurrent
+// requests: WAL so reads do not block on writes, a busy timeout so writers wait for the lock
+// instead of failing with "database is locked", and immediate transactions so a transaction
+// takes the write lock up front rather than failing when it upgrades from a read.
+func sqliteDSN(path string) string {
+	if path == ":memory:" {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+}
+
+// ensureDatabaseDir creates the parent directory of a SQLite database file and checks that the
+// database can be written there, so a bad DATABASE_PATH fails with a clear error at startup
+// rather than on the first write. In-memory databases and file: URIs are left to the driver.
+func ensureDatabaseDir(path string) error {
+	if path == "" || path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create database directory %s: %w", dir, err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("database file %s is not writable: %w", path, err)
+		}
+		return f.Close()
+	}
+	probe, err := os.CreateTemp(dir, ".allama-write-check-*")
+	if err != nil {
+		return fmt.Errorf("database directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
 }
 
 // Close closes the database connection
@@ -70,59 +145,147 @@ func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
-// ResetDatabase deletes the existing database file and recreates it with the initial schema
+// ResetDatabase deletes the existing database and recreates it with the current schema.
+// SQLite databases are removed from disk; other drivers have their tables dropped.
 func (s *Storage) ResetDatabase(databasePath string) error {
+	if s.db.dialect.driver != "sqlite3" {
+		if _, err := s.db.Exec("DROP TABLE IF EXISTS aliases, models, providers, schema_migrations"); err != nil {
+			return err
+		}
+		if err := migrate(s.db); err != nil {
+			return err
+		}
+		s.invalidateProviderCache()
+		return nil
+	}
+
 	// Close the current database connection
 	if err := s.Close(); err != nil {
 		return err
 	}
 
-	// Delete the database file if it exists
-	if _, err := os.Stat(databasePath); err == nil {
-		if err := os.Remove(databasePath); err != nil {
-			return err
+	// Delete the database file, and any WAL files left beside it, if they exist
+	for _, path := range []string{databasePath, databasePath + "-wal", databasePath + "-shm"} {
+		if _, err := os.Stat(path); err == nil {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
 		}
 	}
 
 	// Reopen a new database connection
-	db, err := sql.Open("sqlite3", databasePath)
+	cfg := *s.cfg
+	cfg.DatabasePath = databasePath
+	db, err := openDB(&cfg)
 	if err != nil {
 		return err
 	}
 
-	// Recreate the tables
-	if err := createTables(db); err != nil {
+	// Recreate the schema
+	if err := migrate(db); err != nil {
 		db.Close()
 		return err
 	}
 
 	// Update the storage instance with the new database connection
 	s.db = db
+	s.invalidateProviderCache()
 	return nil
 }
 
+// providerColumns lists the provider columns read by scanProvider, in order
+const providerColumns = "id, name, type, api_key, host, is_active, headers, default_max_tokens, system_prompt, system_prompt_mode, priority, weight, proxy, tls"
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProvider reads a provider selected with providerColumns
+func scanProvider(row rowScanner) (*models.Provider, error) {
+	p := &models.Provider{}
+	var headers, tlsSettings string
+	if err := row.Scan(&p.ID, &p.Name, &p.Type, &p.APIKey, &p.Host, &p.IsActive, &headers, &p.DefaultMaxTokens, &p.SystemPrompt, &p.SystemPromptMode, &p.Priority, &p.Weight, &p.Proxy, &tlsSettings); err != nil {
+		return nil, err
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &p.Headers); err != nil {
+			return nil, fmt.Errorf("invalid headers for provider %s: %w", p.Name, err)
+		}
+	}
+	if tlsSettings != "" {
+		if err := json.Unmarshal([]byte(tlsSettings), &p.TLS); err != nil {
+			return nil, fmt.Errorf("invalid TLS settings for provider %s: %w", p.Name, err)
+		}
+	}
+	return p, nil
+}
+
+// encodeTLS encodes TLS settings for storage, as an empty string when there are none
+func encodeTLS(settings *models.ProviderTLS) (string, error) {
+	if settings == nil || *settings == (models.ProviderTLS{}) {
+		return "", nil
+	}
+	encoded, err := json.Marshal(settings)
+	return string(encoded), err
+}
+
+// encodeHeaders encodes custom headers for storage, as an empty string when there are none
+func encodeHeaders(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(headers)
+	return string(encoded), err
+}
+
 // AddProvider adds a new provider to the database
 func (s *Storage) AddProvider(provider *models.Provider) error {
-	result, err := s.db.Exec(
-		"INSERT INTO providers (name, api_key, host, is_active) VALUES (?, ?, ?, ?)",
-		provider.Name, provider.APIKey, provider.Host, provider.IsActive,
+	headers, err := encodeHeaders(provider.Headers)
+	if err != nil {
+		return err
+	}
+	tlsSettings, err := encodeTLS(provider.TLS)
+	if err != nil {
+		return err
+	}
+	id, err := s.db.insertID(
+		"INSERT INTO providers (name, type, api_key, host, is_active, headers, default_max_tokens, system_prompt, system_prompt_mode, priority, weight, proxy, tls) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		provider.Name, provider.ProviderType(), provider.APIKey, provider.Host, provider.IsActive, headers, provider.DefaultMaxTokens,
+		provider.SystemPrompt, provider.SystemPromptMode, provider.Priority, provider.Weight, provider.Proxy, tlsSettings,
 	)
 	if err != nil {
 		return err
 	}
 
-	id, _ := result.LastInsertId()
-	provider.ID = int(id)
+	provider.ID = id
+	provider.Type = provider.ProviderType()
+	s.invalidateProviderCache()
 	return nil
 }
 
+// UpsertProvider inserts a provider or, if one with the same name already exists,
+// updates its type, API key, host, active flag, headers, default max tokens, system prompt,
+// priority, weight, proxy and TLS settings
+func (s *Storage) UpsertProvider(provider *models.Provider) error {
+	existing, err := s.GetProviderByName(provider.Name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return s.AddProvider(provider)
+	}
+
+	provider.ID = existing.ID
+	return s.UpdateProvider(provider)
+}
+
 // GetProviderByName retrieves a provider by its name
 func (s *Storage) GetProviderByName(name string) (*models.Provider, error) {
-	provider := &models.Provider{}
-	err := s.db.QueryRow(
-		"SELECT id, name, api_key, host, is_active FROM providers WHERE name = ?",
+	provider, err := scanProvider(s.db.QueryRow(
+		"SELECT "+providerColumns+" FROM providers WHERE name = ?",
 		name,
-	).Scan(&provider.ID, &provider.Name, &provider.APIKey, &provider.Host, &provider.IsActive)
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -132,9 +295,210 @@ func (s *Storage) GetProviderByName(name string) (*models.Provider, error) {
 	return provider, nil
 }
 
-// GetActiveProviders retrieves all active providers
+// GetProviderByID retrieves a provider by its ID
+func (s *Storage) GetProviderByID(id int) (*models.Provider, error) {
+	provider, err := scanProvider(s.db.QueryRow(
+		"SELECT "+providerColumns+" FROM providers WHERE id = ?",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// GetProviders retrieves all providers, active or not, in priority order
+func (s *Storage) GetProviders() ([]*models.Provider, error) {
+	rows, err := s.db.Query("SELECT " + providerColumns + " FROM providers ORDER BY priority DESC, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var providers []*models.Provider
+	for rows.Next() {
+		p, err := scanProvider(rows)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// UpdateProvider updates the type, API key, host, active flag, headers, default max tokens,
+// system prompt, priority, weight, proxy and TLS settings of an existing provider
+func (s *Storage) UpdateProvider(provider *models.Provider) error {
+	headers, err := encodeHeaders(provider.Headers)
+	if err != nil {
+		return err
+	}
+	tlsSettings, err := encodeTLS(provider.TLS)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		"UPDATE providers SET type = ?, api_key = ?, host = ?, is_active = ?, headers = ?, default_max_tokens = ?, system_prompt = ?, system_prompt_mode = ?, priority = ?, weight = ?, proxy = ?, tls = ? WHERE id = ?",
+		provider.ProviderType(), provider.APIKey, provider.Host, provider.IsActive, headers, provider.DefaultMaxTokens,
+		provider.SystemPrompt, provider.SystemPromptMode, provider.Priority, provider.Weight, provider.Proxy, tlsSettings, provider.ID,
+	)
+	if err != nil {
+		return err
+	}
+	provider.Type = provider.ProviderType()
+	s.invalidateProviderCache()
+	return nil
+}
+
+// DeleteProvider removes a provider and all of its models
+func (s *Storage) DeleteProvider(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM models WHERE provider_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM providers WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.invalidateProviderCache()
+	return nil
+}
+
+// GetProviderNameByModelID returns the name of the first active provider serving an active model,
+// or an empty string when no provider does. When several serve the model the one with the
+// highest priority wins, and among equal priorities the one configured first.
+func (s *Storage) GetProviderNameByModelID(modelID string) (string, error) {
+	providers, err := s.GetProvidersForModel(modelID)
+	if err != nil || len(providers) == 0 {
+		return "", err
+	}
+	return providers[0].Name, nil
+}
+
+// cachedProvidersFor returns copies of the cached providers serving a model, if there are any
+func (s *Storage) cachedProvidersFor(modelID string) ([]*models.Provider, bool) {
+	s.cacheMu.RLock()
+	entry, ok := s.modelProviders[modelID]
+	s.cacheMu.RUnlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return copyProviders(entry.providers), true
+}
+
+// cacheProviders caches the providers serving a model, unless the cache was invalidated since
+// generation gen, when the lookup started. Models no provider serves are not cached, so a model
+// added by another instance is found on the next request.
+func (s *Storage) cacheProviders(gen uint64, modelID string, providers []*models.Provider) {
+	if len(providers) == 0 {
+		return
+	}
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if gen != s.cacheGen {
+		return
+	}
+	if s.modelProviders == nil {
+		s.modelProviders = make(map[string]cachedProviders)
+	}
+	if len(s.modelProviders) >= providerCacheSize {
+		now := time.Now()
+		for id, entry := range s.modelProviders {
+			if now.After(entry.expires) {
+				delete(s.modelProviders, id)
+			}
+		}
+		// Still full: make room by dropping an arbitrary entry
+		for id := range s.modelProviders {
+			if len(s.modelProviders) < providerCacheSize {
+				break
+			}
+			delete(s.modelProviders, id)
+		}
+	}
+	s.modelProviders[modelID] = cachedProviders{
+		providers: copyProviders(providers),
+		expires:   time.Now().Add(providerCacheTTL),
+	}
+}
+
+// invalidateProviderCache drops cached model lookups
+func (s *Storage) invalidateProviderCache() {
+	s.cacheMu.Lock()
+	s.modelProviders = nil
+	s.cacheGen++
+	s.cacheMu.Unlock()
+}
+
+// copyProviders copies providers so callers cannot change the cached ones
+func copyProviders(providers []*models.Provider) []*models.Provider {
+	copies := make([]*models.Provider, len(providers))
+	for i, p := range providers {
+		c := *p
+		if p.Headers != nil {
+			c.Headers = make(map[string]string, len(p.Headers))
+			for k, v := range p.Headers {
+				c.Headers[k] = v
+			}
+		}
+		if p.TLS != nil {
+			tls := *p.TLS
+			c.TLS = &tls
+		}
+		copies[i] = &c
+	}
+	return copies
+}
+
+// GetActiveProviders retrieves all active providers in priority order
 func (s *Storage) GetActiveProviders() ([]*models.Provider, error) {
-	rows, err := s.db.Query("SELECT id, name, api_key, host, is_active FROM providers WHERE is_active = true")
+	rows, err := s.db.Query("SELECT " + providerColumns + " FROM providers WHERE is_active = true ORDER BY priority DESC, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var providers []*models.Provider
+	for rows.Next() {
+		p, err := scanProvider(rows)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// GetProvidersForModel retrieves the active providers serving an active model with the given ID,
+// ordered by descending priority and then by ID, so the first configured provider is tried first
+// among equal priorities. Results are cached; see modelProviders.
+func (s *Storage) GetProvidersForModel(modelID string) ([]*models.Provider, error) {
+	if providers, ok := s.cachedProvidersFor(modelID); ok {
+		return providers, nil
+	}
+	s.cacheMu.RLock()
+	gen := s.cacheGen
+	s.cacheMu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT p.id, p.name, p.type, p.api_key, p.host, p.is_active, p.headers, p.default_max_tokens,
+			p.system_prompt, p.system_prompt_mode, p.priority, p.weight, p.proxy, p.tls
+		FROM providers p
+		JOIN models m ON m.provider_id = p.id
+		WHERE m.model_id = ? AND m.is_active = true AND p.is_active = true
+		ORDER BY p.priority DESC, p.id`,
+		modelID,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -142,34 +506,79 @@ func (s *Storage) GetActiveProviders() ([]*models.Provider, error) {
 
 	var providers []*models.Provider
 	for rows.Next() {
-		p := &models.Provider{}
-		if err := rows.Scan(&p.ID, &p.Name, &p.APIKey, &p.Host, &p.IsActive); err != nil {
+		p, err := scanProvider(rows)
+		if err != nil {
 			return nil, err
 		}
 		providers = append(providers, p)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.cacheProviders(gen, modelID, providers)
 	return providers, nil
 }
 
-// AddModel adds a new model to the database
+// modelColumns lists the model columns read by scanModel, in order
+const modelColumns = "id, provider_id, name, model_id, is_active, unlisted, created_at, updated_at, metadata"
+
+// scanModel reads a model selected with modelColumns
+func scanModel(row rowScanner) (models.Model, error) {
+	var m models.Model
+	var createdAt, updatedAt sql.NullTime
+	var metadata string
+	if err := row.Scan(&m.ID, &m.ProviderID, &m.Name, &m.ModelID, &m.IsActive, &m.Unlisted, &createdAt, &updatedAt, &metadata); err != nil {
+		return m, err
+	}
+	m.CreatedAt = createdAt.Time
+	m.UpdatedAt = updatedAt.Time
+	if metadata != "" {
+		m.Metadata = &models.ModelMetadata{}
+		if err := json.Unmarshal([]byte(metadata), m.Metadata); err != nil {
+			return m, fmt.Errorf("invalid metadata for model %s: %w", m.ModelID, err)
+		}
+	}
+	return m, nil
+}
+
+// encodeMetadata encodes model metadata for storage, as an empty string when there is none
+func encodeMetadata(metadata *models.ModelMetadata) (string, error) {
+	if metadata == nil {
+		return "", nil
+	}
+	encoded, err := json.Marshal(metadata)
+	return string(encoded), err
+}
+
+// AddModel adds a new model to the database, stamping its creation time
 func (s *Storage) AddModel(model *models.Model) error {
-	result, err := s.db.Exec(
-		"INSERT INTO models (provider_id, name, model_id, is_active) VALUES (?, ?, ?, ?)",
-		model.ProviderID, model.Name, model.ModelID, model.IsActive,
+	now := time.Now().UTC()
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = now
+	}
+	model.UpdatedAt = now
+	metadata, err := encodeMetadata(model.Metadata)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.db.insertID(
+		"INSERT INTO models (provider_id, name, model_id, is_active, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		model.ProviderID, model.Name, model.ModelID, model.IsActive, model.CreatedAt, model.UpdatedAt, metadata,
 	)
 	if err != nil {
 		return err
 	}
 
-	id, _ := result.LastInsertId()
-	model.ID = int(id)
+	model.ID = id
+	s.invalidateProviderCache()
 	return nil
 }
 
 // GetModelsByProviderID retrieves all models for a specific provider
 func (s *Storage) GetModelsByProviderID(providerID int) ([]models.Model, error) {
 	rows, err := s.db.Query(
-		"SELECT id, provider_id, name, model_id, is_active FROM models WHERE provider_id = ?",
+		"SELECT "+modelColumns+" FROM models WHERE provider_id = ?",
 		providerID,
 	)
 	if err != nil {
@@ -179,8 +588,8 @@ func (s *Storage) GetModelsByProviderID(providerID int) ([]models.Model, error)
 
 	var modelsList []models.Model
 	for rows.Next() {
-		var m models.Model
-		if err := rows.Scan(&m.ID, &m.ProviderID, &m.Name, &m.ModelID, &m.IsActive); err != nil {
+		m, err := scanModel(rows)
+		if err != nil {
 			return nil, err
 		}
 		modelsList = append(modelsList, m)
@@ -188,9 +597,58 @@ func (s *Storage) GetModelsByProviderID(providerID int) ([]models.Model, error)
 	return modelsList, nil
 }
 
+// UpdateModelActive enables or disables a single model. The choice is kept when the provider
+// lists the model again, so it clears the unlisted flag.
+func (s *Storage) UpdateModelActive(id int, active bool) error {
+	return s.updateModelState(id, active, false)
+}
+
+// UpdateModelListed records whether a model's provider lists it: an unlisted model is
+// deactivated and flagged unlisted, and a listed one is active again
+func (s *Storage) UpdateModelListed(id int, listed bool) error {
+	return s.updateModelState(id, listed, !listed)
+}
+
+// updateModelState sets the active and unlisted flags of a single model
+func (s *Storage) updateModelState(id int, active, unlisted bool) error {
+	result, err := s.db.Exec("UPDATE models SET is_active = ?, unlisted = ?, updated_at = ? WHERE id = ?", active, unlisted, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	s.invalidateProviderCache()
+	return nil
+}
+
+// UpdateModelMetadata replaces the metadata overrides of a single model; nil removes them
+func (s *Storage) UpdateModelMetadata(id int, metadata *models.ModelMetadata) error {
+	encoded, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec("UPDATE models SET metadata = ?, updated_at = ? WHERE id = ?", encoded, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // GetActiveModels retrieves all active models
 func (s *Storage) GetActiveModels() ([]models.Model, error) {
-	rows, err := s.db.Query("SELECT id, provider_id, name, model_id, is_active FROM models WHERE is_active = true")
+	rows, err := s.db.Query("SELECT " + modelColumns + " FROM models WHERE is_active = true")
 	if err != nil {
 		return nil, err
 	}
@@ -198,11 +656,35 @@ func (s *Storage) GetActiveModels() ([]models.Model, error) {
 
 	var modelsList []models.Model
 	for rows.Next() {
-		var m models.Model
-		if err := rows.Scan(&m.ID, &m.ProviderID, &m.Name, &m.ModelID, &m.IsActive); err != nil {
+		m, err := scanModel(rows)
+		if err != nil {
 			return nil, err
 		}
 		modelsList = append(modelsList, m)
 	}
 	return modelsList, nil
 }
+
+// CountActiveProviders returns the number of active providers
+func (s *Storage) CountActiveProviders() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM providers WHERE is_active = true").Scan(&count)
+	return count, err
+}
+
+// CountModels returns the number of models of a provider, active or not
+func (s *Storage) CountModels(providerID int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM models WHERE provider_id = ?", providerID).Scan(&count)
+	return count, err
+}
+
+// CountActiveModels returns the number of active models across all active providers
+func (s *Storage) CountActiveModels() (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM models m
+		JOIN providers p ON p.id = m.provider_id
+		WHERE m.is_active = true AND p.is_active = true`).Scan(&count)
+	return count, err
+}
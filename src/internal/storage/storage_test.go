@@ -0,0 +1,721 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/offbeat-studio/allama/internal/config"
+	"github.com/offbeat-studio/allama/internal/models"
+)
+
+// newTestStorage creates a storage backed by a fresh database in a temporary directory
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "allama.db")}
+	store, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestProviderHostRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{
+		Name:     "openai",
+		APIKey:   "test-key",
+		Host:     "https://example.openai.azure.com",
+		IsActive: true,
+	}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+
+	fetched, err := store.GetProviderByName("openai")
+	if err != nil {
+		t.Fatalf("Failed to get provider: %v", err)
+	}
+	if fetched == nil {
+		t.Fatal("Expected provider to be found")
+	}
+	if fetched.Host != prov.Host {
+		t.Errorf("Expected host %s, got %s", prov.Host, fetched.Host)
+	}
+	if fetched.APIKey != prov.APIKey {
+		t.Errorf("Expected API key %s, got %s", prov.APIKey, fetched.APIKey)
+	}
+}
+
+func TestProviderHeadersRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{
+		Name:     "openrouter",
+		Type:     "openai",
+		Host:     "https://openrouter.ai/api",
+		IsActive: true,
+		Headers:  map[string]string{"HTTP-Referer": "https://allama.example", "X-Title": "Allama"},
+	}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+
+	fetched, err := store.GetProviderByID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get provider: %v", err)
+	}
+	if len(fetched.Headers) != 2 || fetched.Headers["X-Title"] != "Allama" {
+		t.Errorf("Expected headers to round-trip, got %v", fetched.Headers)
+	}
+
+	fetched.Headers = nil
+	if err := store.UpdateProvider(fetched); err != nil {
+		t.Fatalf("Failed to update provider: %v", err)
+	}
+	cleared, err := store.GetProviderByID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get provider: %v", err)
+	}
+	if cleared.Headers != nil {
+		t.Errorf("Expected headers to be cleared, got %v", cleared.Headers)
+	}
+}
+
+func TestProviderRequestDefaultsRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{
+		Name:     "anthropic",
+		Host:     "https://api.anthropic.com",
+		IsActive: true,
+
+		DefaultMaxTokens: 4096,
+		SystemPrompt:     "Be safe.",
+		SystemPromptMode: "override",
+		Proxy:            "http://proxy.internal:3128",
+		TLS:              &models.ProviderTLS{CAFile: "/etc/allama/ca.pem"},
+	}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	fetched, err := store.GetProviderByName("anthropic")
+	if err != nil {
+		t.Fatalf("Failed to get provider: %v", err)
+	}
+	if fetched.DefaultMaxTokens != 4096 {
+		t.Errorf("Expected the default max tokens to round-trip, got %d", fetched.DefaultMaxTokens)
+	}
+	if fetched.SystemPrompt != "Be safe." || fetched.SystemPromptMode != "override" {
+		t.Errorf("Expected the system prompt to round-trip, got %q (%s)", fetched.SystemPrompt, fetched.SystemPromptMode)
+	}
+	if fetched.Proxy != "http://proxy.internal:3128" {
+		t.Errorf("Expected the proxy to round-trip, got %q", fetched.Proxy)
+	}
+	if fetched.TLS == nil || fetched.TLS.CAFile != "/etc/allama/ca.pem" {
+		t.Errorf("Expected the TLS settings to round-trip, got %+v", fetched.TLS)
+	}
+
+	fetched.DefaultMaxTokens = 0
+	if err := store.UpdateProvider(fetched); err != nil {
+		t.Fatalf("Failed to update provider: %v", err)
+	}
+	cleared, err := store.GetProviderByID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get provider: %v", err)
+	}
+	if cleared.DefaultMaxTokens != 0 {
+		t.Errorf("Expected the default max tokens to be cleared, got %d", cleared.DefaultMaxTokens)
+	}
+}
+
+func TestMultipleProvidersOfSameType(t *testing.T) {
+	store := newTestStorage(t)
+
+	for _, prov := range []*models.Provider{
+		{Name: "openai", APIKey: "prod-key", Host: "https://api.openai.com", IsActive: true},
+		{Name: "openai-cheap", Type: "openai", APIKey: "cheap-key", Host: "https://cheap.example.com", IsActive: true},
+	} {
+		if err := store.AddProvider(prov); err != nil {
+			t.Fatalf("Failed to add provider %s: %v", prov.Name, err)
+		}
+	}
+
+	providers, err := store.GetProviders()
+	if err != nil {
+		t.Fatalf("Failed to list providers: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+	for _, prov := range providers {
+		if prov.Type != "openai" {
+			t.Errorf("Expected %s to have type openai, got %q", prov.Name, prov.Type)
+		}
+	}
+
+	if err := store.AddProvider(&models.Provider{Name: "openai-cheap", Type: "anthropic"}); err == nil {
+		t.Error("Expected a duplicate provider name to be rejected")
+	}
+}
+func TestGetProvidersForModel(t *testing.T) {
+	store := newTestStorage(t)
+
+	primary := &models.Provider{Name: "openai", IsActive: true}
+	backup := &models.Provider{Name: "anthropic", IsActive: true}
+	inactive := &models.Provider{Name: "ollama", IsActive: false}
+	for _, p := range []*models.Provider{primary, backup, inactive} {
+		if err := store.AddProvider(p); err != nil {
+			t.Fatalf("Failed to add provider: %v", err)
+		}
+		if err := store.AddModel(&models.Model{ProviderID: p.ID, Name: "shared", ModelID: "shared", IsActive: true}); err != nil {
+			t.Fatalf("Failed to add model: %v", err)
+		}
+	}
+
+	providers, err := store.GetProvidersForModel("shared")
+	if err != nil {
+		t.Fatalf("Failed to get providers: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 active providers, got %d", len(providers))
+	}
+	if providers[0].Name != "openai" || providers[1].Name != "anthropic" {
+		t.Errorf("Expected providers in configuration order, got %s, %s", providers[0].Name, providers[1].Name)
+	}
+
+	// A higher priority goes first regardless of configuration order
+	backup.Priority, backup.Weight = 10, 3
+	if err := store.UpdateProvider(backup); err != nil {
+		t.Fatalf("Failed to update provider: %v", err)
+	}
+	providers, err = store.GetProvidersForModel("shared")
+	if err != nil {
+		t.Fatalf("Failed to get providers: %v", err)
+	}
+	if providers[0].Name != "anthropic" || providers[0].Priority != 10 || providers[0].Weight != 3 {
+		t.Errorf("Expected the higher priority provider first with its weight, got %+v", providers[0])
+	}
+	if name, _ := store.GetProviderNameByModelID("shared"); name != "anthropic" {
+		t.Errorf("Expected the higher priority provider to serve the model, got %s", name)
+	}
+}
+
+func TestModelTimestamps(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	before := time.Now().Add(-time.Second)
+	model := &models.Model{ProviderID: prov.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true}
+	if err := store.AddModel(model); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+
+	stored, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("Expected 1 stored model, got %d (err %v)", len(stored), err)
+	}
+	if stored[0].CreatedAt.Before(before) || !stored[0].CreatedAt.Equal(stored[0].UpdatedAt) {
+		t.Errorf("Expected matching insert timestamps, got created %v updated %v", stored[0].CreatedAt, stored[0].UpdatedAt)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := store.UpdateModelActive(model.ID, false); err != nil {
+		t.Fatalf("Failed to update model: %v", err)
+	}
+	updated, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get models: %v", err)
+	}
+	if !updated[0].CreatedAt.Equal(stored[0].CreatedAt) || !updated[0].UpdatedAt.After(stored[0].UpdatedAt) {
+		t.Errorf("Expected only updated_at to advance, got created %v updated %v", updated[0].CreatedAt, updated[0].UpdatedAt)
+	}
+}
+
+func TestDeleteProviderRemovesModels(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	if err := store.AddModel(&models.Model{ProviderID: prov.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true}); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+
+	if err := store.DeleteProvider(prov.ID); err != nil {
+		t.Fatalf("Failed to delete provider: %v", err)
+	}
+
+	if p, err := store.GetProviderByID(prov.ID); err != nil || p != nil {
+		t.Errorf("Expected provider to be gone, got %+v (err %v)", p, err)
+	}
+	if ms, err := store.GetModelsByProviderID(prov.ID); err != nil || len(ms) != 0 {
+		t.Errorf("Expected models to be gone, got %+v (err %v)", ms, err)
+	}
+}
+
+func TestUpdateModelActive(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	model := &models.Model{ProviderID: prov.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true}
+	if err := store.AddModel(model); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+
+	if err := store.UpdateModelActive(model.ID, false); err != nil {
+		t.Fatalf("Failed to update model: %v", err)
+	}
+	active, err := store.GetActiveModels()
+	if err != nil {
+		t.Fatalf("Failed to get active models: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("Expected no active models, got %+v", active)
+	}
+
+	if err := store.UpdateModelActive(model.ID+1, true); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for unknown model, got %v", err)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	store := newTestStorage(t)
+
+	openai := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	anthropic := &models.Provider{Name: "anthropic", Host: "https://api.anthropic.com", IsActive: true}
+	mistral := &models.Provider{Name: "mistral", Host: "https://api.mistral.ai", IsActive: false}
+	groq := &models.Provider{Name: "groq", Host: "https://api.groq.com", IsActive: false}
+	for _, prov := range []*models.Provider{openai, anthropic, mistral, groq} {
+		if err := store.AddProvider(prov); err != nil {
+			t.Fatalf("Failed to add provider: %v", err)
+		}
+	}
+	for _, model := range []*models.Model{
+		{ProviderID: openai.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true},
+		{ProviderID: openai.ID, Name: "gpt-4.1", ModelID: "gpt-4.1", IsActive: true},
+		{ProviderID: openai.ID, Name: "gpt-3.5-turbo", ModelID: "gpt-3.5-turbo", IsActive: false},
+		{ProviderID: anthropic.ID, Name: "claude-sonnet-4", ModelID: "claude-sonnet-4", IsActive: true},
+		{ProviderID: groq.ID, Name: "llama-3.3-70b", ModelID: "llama-3.3-70b", IsActive: true},
+	} {
+		if err := store.AddModel(model); err != nil {
+			t.Fatalf("Failed to add model: %v", err)
+		}
+	}
+
+	if count, err := store.CountActiveProviders(); err != nil || count != 2 {
+		t.Errorf("Expected 2 active providers, got %d (err %v)", count, err)
+	}
+	if count, err := store.CountModels(openai.ID); err != nil || count != 3 {
+		t.Errorf("Expected 3 OpenAI models, got %d (err %v)", count, err)
+	}
+	if count, err := store.CountModels(mistral.ID); err != nil || count != 0 {
+		t.Errorf("Expected no Mistral models, got %d (err %v)", count, err)
+	}
+	if count, err := store.CountActiveModels(); err != nil || count != 3 {
+		t.Errorf("Expected 3 active models of active providers, got %d (err %v)", count, err)
+	}
+}
+
+func TestModelMetadataRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	plain := &models.Model{ProviderID: prov.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true}
+	if err := store.AddModel(plain); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+	tuned := &models.Model{ProviderID: prov.ID, Name: "ft:gpt-4o", ModelID: "ft:gpt-4o", IsActive: true,
+		Metadata: &models.ModelMetadata{Capabilities: []string{"completion"}, ContextLength: 65536}}
+	if err := store.AddModel(tuned); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+	if err := store.UpdateModelMetadata(plain.ID, &models.ModelMetadata{Family: "gpt"}); err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+
+	stored, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get models: %v", err)
+	}
+	byID := make(map[string]models.Model)
+	for _, m := range stored {
+		byID[m.ModelID] = m
+	}
+	if m := byID["gpt-4o"].Metadata; m == nil || m.Family != "gpt" {
+		t.Errorf("Expected updated metadata for gpt-4o, got %+v", m)
+	}
+	if m := byID["ft:gpt-4o"].Metadata; m == nil || m.ContextLength != 65536 || len(m.Capabilities) != 1 {
+		t.Errorf("Expected stored metadata for ft:gpt-4o, got %+v", m)
+	}
+
+	if err := store.UpdateModelMetadata(plain.ID, nil); err != nil {
+		t.Fatalf("Failed to clear metadata: %v", err)
+	}
+	stored, _ = store.GetModelsByProviderID(prov.ID)
+	for _, m := range stored {
+		if m.ID == plain.ID && m.Metadata != nil {
+			t.Errorf("Expected cleared metadata, got %+v", m.Metadata)
+		}
+	}
+	if err := store.UpdateModelMetadata(tuned.ID+1, nil); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for unknown model, got %v", err)
+	}
+}
+
+func TestUpdateModelListed(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "ollama", Type: "ollama", Host: "http://localhost:11434", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	model := &models.Model{ProviderID: prov.ID, Name: "llama3", ModelID: "llama3", IsActive: true}
+	if err := store.AddModel(model); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+	state := func() models.Model {
+		t.Helper()
+		stored, err := store.GetModelsByProviderID(prov.ID)
+		if err != nil || len(stored) != 1 {
+			t.Fatalf("Failed to get model: %v", err)
+		}
+		return stored[0]
+	}
+
+	if err := store.UpdateModelListed(model.ID, false); err != nil {
+		t.Fatalf("Failed to unlist model: %v", err)
+	}
+	if m := state(); m.IsActive || !m.Unlisted {
+		t.Errorf("Expected an inactive unlisted model, got %+v", m)
+	}
+	if err := store.UpdateModelListed(model.ID, true); err != nil {
+		t.Fatalf("Failed to list model: %v", err)
+	}
+	if m := state(); !m.IsActive || m.Unlisted {
+		t.Errorf("Expected the listed model to be active again, got %+v", m)
+	}
+
+	// An administrator's choice replaces the flag
+	store.UpdateModelListed(model.ID, false)
+	if err := store.UpdateModelActive(model.ID, false); err != nil {
+		t.Fatalf("Failed to disable model: %v", err)
+	}
+	if m := state(); m.IsActive || m.Unlisted {
+		t.Errorf("Expected a disabled model that is not unlisted, got %+v", m)
+	}
+	if err := store.UpdateModelListed(model.ID+1, true); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for unknown model, got %v", err)
+	}
+}
+
+func TestGetProviderNameByModelID(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+
+	// Look the model up before it exists, then make sure adding it is seen
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "" {
+		t.Fatalf("Expected no provider, got %q (err %v)", name, err)
+	}
+	if err := store.AddModel(&models.Model{ProviderID: prov.ID, Name: "gpt-4o", ModelID: "gpt-4o", IsActive: true}); err != nil {
+		t.Fatalf("Failed to add model: %v", err)
+	}
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "openai" {
+		t.Fatalf("Expected openai, got %q (err %v)", name, err)
+	}
+
+	prov.IsActive = false
+	if err := store.UpdateProvider(prov); err != nil {
+		t.Fatalf("Failed to update provider: %v", err)
+	}
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "" {
+		t.Errorf("Expected inactive provider to be skipped, got %q (err %v)", name, err)
+	}
+}
+
+func TestProviderCacheSeesWritesByOtherInstances(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "" {
+		t.Fatalf("Expected no provider, got %q (err %v)", name, err)
+	}
+
+	// Another instance adds the model, so this one's cache is not invalidated
+	if _, err := store.db.Exec("INSERT INTO models (provider_id, name, model_id, is_active) VALUES (?, ?, ?, ?)", prov.ID, "gpt-4o", "gpt-4o", true); err != nil {
+		t.Fatalf("Failed to insert model: %v", err)
+	}
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "openai" {
+		t.Fatalf("Expected a missing model not to be cached, got %q (err %v)", name, err)
+	}
+
+	// ... and then disables it, which is seen once the cached lookup expires
+	if _, err := store.db.Exec("UPDATE models SET is_active = ? WHERE model_id = ?", false, "gpt-4o"); err != nil {
+		t.Fatalf("Failed to disable model: %v", err)
+	}
+	if name, _ := store.GetProviderNameByModelID("gpt-4o"); name != "openai" {
+		t.Fatalf("Expected the cached provider before expiry, got %q", name)
+	}
+	store.cacheMu.Lock()
+	entry := store.modelProviders["gpt-4o"]
+	entry.expires = time.Now().Add(-time.Second)
+	store.modelProviders["gpt-4o"] = entry
+	store.cacheMu.Unlock()
+	if name, err := store.GetProviderNameByModelID("gpt-4o"); err != nil || name != "" {
+		t.Errorf("Expected the expired lookup to be refreshed, got %q (err %v)", name, err)
+	}
+}
+
+func TestProviderCacheIsBounded(t *testing.T) {
+	store := newTestStorage(t)
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true, Headers: map[string]string{"X-Title": "allama"}}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+	for i := 0; i < providerCacheSize+10; i++ {
+		modelID := fmt.Sprintf("model-%d", i)
+		if err := store.AddModel(&models.Model{ProviderID: prov.ID, Name: modelID, ModelID: modelID, IsActive: true}); err != nil {
+			t.Fatalf("Failed to add model: %v", err)
+		}
+	}
+	for i := 0; i < providerCacheSize+10; i++ {
+		if _, err := store.GetProvidersForModel(fmt.Sprintf("model-%d", i)); err != nil {
+			t.Fatalf("Lookup failed: %v", err)
+		}
+	}
+	store.cacheMu.RLock()
+	cached := len(store.modelProviders)
+	store.cacheMu.RUnlock()
+	if cached > providerCacheSize {
+		t.Errorf("Expected at most %d cached lookups, got %d", providerCacheSize, cached)
+	}
+
+	// Callers get copies, so changing one does not change the cache
+	providers, _ := store.GetProvidersForModel("model-0")
+	providers[0].APIKey = "changed"
+	providers[0].Headers["X-Title"] = "changed"
+	providers, _ = store.GetProvidersForModel("model-0")
+	if providers[0].APIKey == "changed" || providers[0].Headers["X-Title"] != "allama" {
+		t.Errorf("Expected the cached provider to be unchanged, got %+v", providers[0])
+	}
+}
+
+func TestGetProviderNameByModelIDPrefersFirstProvider(t *testing.T) {
+	store := newTestStorage(t)
+
+	first := &models.Provider{Name: "ollama", Type: "ollama", Host: "http://localhost:11434", IsActive: true}
+	second := &models.Provider{Name: "ollama-2", Type: "ollama", Host: "http://gpu:11434", IsActive: true}
+	for _, prov := range []*models.Provider{first, second} {
+		if err := store.AddProvider(prov); err != nil {
+			t.Fatalf("Failed to add provider: %v", err)
+		}
+	}
+	// Add the second provider's model first so insertion order cannot decide the tie
+	shared := &models.Model{ProviderID: first.ID, Name: "llama3", ModelID: "llama3", IsActive: true}
+	for _, model := range []*models.Model{
+		{ProviderID: second.ID, Name: "llama3", ModelID: "llama3", IsActive: true},
+		shared,
+	} {
+		if err := store.AddModel(model); err != nil {
+			t.Fatalf("Failed to add model: %v", err)
+		}
+	}
+
+	if name, err := store.GetProviderNameByModelID("llama3"); err != nil || name != "ollama" {
+		t.Fatalf("Expected the first provider to win the tie, got %q (err %v)", name, err)
+	}
+
+	if err := store.UpdateModelActive(shared.ID, false); err != nil {
+		t.Fatalf("Failed to disable model: %v", err)
+	}
+	if name, err := store.GetProviderNameByModelID("llama3"); err != nil || name != "ollama-2" {
+		t.Errorf("Expected a disabled model to be skipped, got %q (err %v)", name, err)
+	}
+}
+
+// seedCatalog adds providers*modelsPerProvider models and returns the ID of the last one
+func seedCatalog(b *testing.B, store *Storage, providers, modelsPerProvider int) string {
+	b.Helper()
+	var last string
+	for i := 0; i < providers; i++ {
+		prov := &models.Provider{Name: fmt.Sprintf("provider-%d", i), IsActive: true}
+		if err := store.AddProvider(prov); err != nil {
+			b.Fatalf("Failed to add provider: %v", err)
+		}
+		for j := 0; j < modelsPerProvider; j++ {
+			last = fmt.Sprintf("model-%d-%d", i, j)
+			if err := store.AddModel(&models.Model{ProviderID: prov.ID, Name: last, ModelID: last, IsActive: true}); err != nil {
+				b.Fatalf("Failed to add model: %v", err)
+			}
+		}
+	}
+	return last
+}
+
+func newBenchStorage(b *testing.B) *Storage {
+	b.Helper()
+	cfg := &config.Config{DatabasePath: filepath.Join(b.TempDir(), "allama.db")}
+	store, err := NewStorage(cfg)
+	if err != nil {
+		b.Fatalf("Failed to create storage: %v", err)
+	}
+	b.Cleanup(func() { store.Close() })
+	return store
+}
+
+// BenchmarkProviderLookupLoop measures the previous lookup, which scanned every provider's models
+func BenchmarkProviderLookupLoop(b *testing.B) {
+	store := newBenchStorage(b)
+	target := seedCatalog(b, store, 5, 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		providers, _ := store.GetActiveProviders()
+	search:
+		for _, prov := range providers {
+			ms, _ := store.GetModelsByProviderID(prov.ID)
+			for _, m := range ms {
+				if m.ModelID == target {
+					break search
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkGetProviderNameByModelID(b *testing.B) {
+	store := newBenchStorage(b)
+	target := seedCatalog(b, store, 5, 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetProviderNameByModelID(target); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetProviderNameByModelIDUncached(b *testing.B) {
+	store := newBenchStorage(b)
+	target := seedCatalog(b, store, 5, 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.invalidateProviderCache()
+		if _, err := store.GetProviderNameByModelID(target); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNewStorageCreatesDatabaseDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "nested", "allama.db")
+	store, err := NewStorage(&config.Config{DatabasePath: path})
+	if err != nil {
+		t.Fatalf("Failed to create storage in a nested directory: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.AddProvider(&models.Provider{Name: "openai", IsActive: true}); err != nil {
+		t.Fatalf("Failed to write to the new database: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected database file at %s: %v", path, err)
+	}
+}
+
+func TestNewStorageReportsUnwritableDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	dir := filepath.Join(t.TempDir(), "readonly")
+	if err := os.Mkdir(dir, 0555); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	_, err := NewStorage(&config.Config{DatabasePath: filepath.Join(dir, "allama.db")})
+	if err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Errorf("Expected a not writable error, got %v", err)
+	}
+}
+
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	store := newTestStorage(t)
+
+	var journalMode string
+	if err := store.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatalf("Failed to read journal mode: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("Expected WAL journal mode, got %q", journalMode)
+	}
+
+	prov := &models.Provider{Name: "openai", Host: "https://api.openai.com", IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+
+	const workers, ops = 16, 25
+	errs := make(chan error, workers*ops*2)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				modelID := fmt.Sprintf("model-%d-%d", w, i)
+				model := &models.Model{ProviderID: prov.ID, Name: modelID, ModelID: modelID, IsActive: true}
+				if err := store.AddModel(model); err != nil {
+					errs <- err
+					continue
+				}
+				if err := store.UpdateModelActive(model.ID, i%2 == 0); err != nil {
+					errs <- err
+				}
+				if _, err := store.GetActiveModels(); err != nil {
+					errs <- err
+				}
+				if _, err := store.GetProvidersForModel(modelID); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent access failed: %v", err)
+	}
+	stored, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get models: %v", err)
+	}
+	if len(stored) != workers*ops {
+		t.Errorf("Expected %d models, got %d", workers*ops, len(stored))
+	}
+}
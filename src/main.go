@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/offbeat-studio/allama/internal/config"
+	"github.com/offbeat-studio/allama/internal/middleware"
 	"github.com/offbeat-studio/allama/internal/models"
 	"github.com/offbeat-studio/allama/internal/provider"
 	"github.com/offbeat-studio/allama/internal/router"
@@ -25,6 +34,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	provider.SetTimeouts(provider.Timeouts{Connect: cfg.UpstreamConnectTimeout, Request: cfg.UpstreamTimeout})
+	provider.SetConnectionPool(provider.ConnectionPool{
+		MaxIdleConns:        cfg.UpstreamMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.UpstreamIdleConnTimeout,
+	})
 
 	// Initialize database storage
 	store, err := storage.NewStorage(cfg)
@@ -34,13 +49,17 @@ func main() {
 	defer store.Close()
 
 	// Initialize default data
-	initializeDefaultData(store, cfg)
+	enabled := initializeDefaultData(store, cfg)
 
 	// Initialize Gin router
 	ginRouter := gin.Default()
 
+	// Track in-flight requests so shutdown can drain them
+	inFlight := middleware.NewInFlight()
+	ginRouter.Use(inFlight.Middleware())
+
 	// Define a simple health check endpoint
-	ginRouter.GET("/health", func(c *gin.Context) {
+	ginRouter.GET(cfg.HealthPath, func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status": "ok",
 		})
@@ -50,46 +69,156 @@ func main() {
 	apiRouter := router.NewRouter(cfg, store, ginRouter)
 	apiRouter.SetupRoutes()
 
-	// Start the server
+	// Start the server and drain in-flight requests on SIGINT or SIGTERM
 	serverAddr := ":" + cfg.Port
-	if err := ginRouter.Run(serverAddr); err != nil {
+	listener, err := net.Listen("tcp", serverAddr)
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	log.Printf("Listening and serving HTTP on %s", serverAddr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Model lists are fetched while the server already serves requests; the stored models of
+	// a provider are used until its fetch completes. Shutdown waits for the fetches to stop.
+	fetches := fetchModelsInBackground(ctx, store, enabled, cfg)
+	defer fetches.Wait()
+
+	server := &http.Server{Handler: ginRouter}
+	if err := serve(ctx, server, listener, inFlight, cfg.ShutdownTimeout); err != nil {
+		log.Printf("Server stopped: %v", err)
+	}
+	if err := apiRouter.Close(); err != nil {
+		log.Printf("Failed to flush request logs: %v", err)
+	}
+	log.Println("Closing storage")
+}
+
+// serve runs the server until ctx is cancelled, then stops accepting connections and gives
+// in-flight requests up to grace to finish before forcibly closing the remaining ones
+func serve(ctx context.Context, server *http.Server, listener net.Listener, inFlight *middleware.InFlight, grace time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	draining := inFlight.Count()
+	log.Printf("Shutting down, draining %d in-flight requests (grace period %s)", draining, grace)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		remaining := inFlight.Count()
+		server.Close()
+		log.Printf("Drained %d in-flight requests, %d did not finish in time", draining-remaining, remaining)
+		return fmt.Errorf("shutdown grace period expired: %w", err)
+	}
+
+	log.Printf("Drained %d in-flight requests", draining)
+	return nil
 }
 
-// initializeDefaultData deletes the existing database and inserts default data into the database.
-func initializeDefaultData(store *storage.Storage, cfg *config.Config) {
+// initializeDefaultData optionally resets the database and upserts the configured providers,
+// returning the enabled ones.
+func initializeDefaultData(store *storage.Storage, cfg *config.Config) []*models.Provider {
 	log.Println("Initializing default data...")
 
-	// Reset the database to ensure a clean state on each run
-	if err := store.ResetDatabase(cfg.DatabasePath); err != nil {
-		log.Printf("Failed to reset database: %v", err)
-	} else {
-		log.Println("Database reset successful")
+	// Only wipe the database when explicitly requested
+	if cfg.ResetDBOnStart {
+		if err := store.ResetDatabase(cfg.DatabasePath); err != nil {
+			log.Printf("Failed to reset database: %v", err)
+		} else {
+			log.Println("Database reset successful")
+		}
 	}
 
 	// Get provider configurations
 	providers := provider.GetProviderConfigs()
+	var enabled []*models.Provider
 
 	// Iterate over provider configurations to initialize enabled providers
 	for _, p := range providers {
-		if enable := os.Getenv(p.EnableEnvVar); enable == "true" {
-			prov := &models.Provider{
-				Name:     p.Name,
-				APIKey:   os.Getenv(p.ApiKeyEnvVar),
-				Host:     p.Host,
-				IsActive: true,
-			}
-			err := store.AddProvider(prov)
-			if err != nil {
-				log.Printf("Failed to add %s provider: %v", p.Name, err)
-			} else {
-				log.Printf("Added %s provider with ID: %d", p.Name, prov.ID)
-				// Fetch available models from provider API
-				provider.FetchModelsForProvider(store, prov)
-			}
-		} else {
+		if enable := os.Getenv(p.EnableEnvVar); enable != "true" {
 			log.Printf("%s provider not enabled (%s is not set to 'true')", p.Name, p.EnableEnvVar)
+			deactivateProvider(store, p.Name)
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			log.Printf("Warning: skipping %v", err)
+			deactivateProvider(store, p.Name)
+			continue
+		}
+
+		prov := &models.Provider{
+			Name:     p.Name,
+			Type:     p.Type,
+			APIKey:   os.Getenv(p.ApiKeyEnvVar),
+			Host:     p.Host,
+			IsActive: true,
+			Headers:  p.Headers,
+
+			DefaultMaxTokens: p.DefaultMaxTokens,
+			SystemPrompt:     p.SystemPrompt,
+			SystemPromptMode: p.SystemPromptMode,
+			Priority:         p.Priority,
+			Weight:           p.Weight,
+			Proxy:            p.Proxy,
+			TLS:              p.TLS,
+		}
+		if p.TLS != nil && p.TLS.InsecureSkipVerify {
+			log.Printf("WARNING: TLS certificate verification is disabled for the %s provider; its traffic can be intercepted", p.Name)
+		}
+		err := store.UpsertProvider(prov)
+		if err != nil {
+			log.Printf("Failed to add %s provider: %v", p.Name, err)
+			continue
 		}
+		log.Printf("Upserted %s provider with ID: %d", p.Name, prov.ID)
+		enabled = append(enabled, prov)
+	}
+	return enabled
+}
+
+// modelFetchBackoff is the wait before the first retry of a failed model fetch at startup
+const modelFetchBackoff = time.Second
+
+// fetchModelsInBackground fetches the models of every provider concurrently, retrying failed
+// fetches, and logs when each provider's catalog is available
+func fetchModelsInBackground(ctx context.Context, store *storage.Storage, providers []*models.Provider, cfg *config.Config) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for _, prov := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// A provider that cannot be reached is only reported and stays enabled, as a local
+			// Ollama may simply not be started yet
+			summary, err := provider.FetchModelsWithRetry(ctx, store, prov, cfg.ModelFetchTimeout, cfg.ModelFetchRetries, modelFetchBackoff)
+			if err != nil {
+				log.Printf("Warning: models of %s provider are unavailable: %v", prov.Name, err)
+				return
+			}
+			log.Printf("Models of %s provider are available: %d added, %d kept, %d removed", prov.Name, summary.Added, summary.Kept, summary.Removed)
+		}()
+	}
+	return &wg
+}
+
+// deactivateProvider disables a provider persisted by a previous run
+func deactivateProvider(store *storage.Storage, name string) {
+	existing, err := store.GetProviderByName(name)
+	if err != nil || existing == nil || !existing.IsActive {
+		return
+	}
+	existing.IsActive = false
+	if err := store.UpsertProvider(existing); err != nil {
+		log.Printf("Failed to deactivate %s provider: %v", name, err)
 	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/offbeat-studio/allama/internal/config"
+	"github.com/offbeat-studio/allama/internal/middleware"
+	"github.com/offbeat-studio/allama/internal/models"
+	"github.com/offbeat-studio/allama/internal/storage"
+)
+
+// startServe runs serve on a random port with a handler that takes delay to respond
+func startServe(t *testing.T, delay, grace time.Duration) (string, context.CancelFunc, <-chan error, chan struct{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	inFlight := middleware.NewInFlight()
+	engine.Use(inFlight.Middleware())
+
+	started := make(chan struct{}, 1)
+	engine.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, &http.Server{Handler: engine}, listener, inFlight, grace)
+	}()
+	return "http://" + listener.Addr().String(), cancel, done, started
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	url, cancel, done, started := startServe(t, 200*time.Millisecond, 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-responses
+	if res.err != nil || res.body != "done" {
+		t.Errorf("Expected the in-flight request to complete, got %q (err %v)", res.body, res.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, err := http.Get(url + "/slow"); err == nil {
+		t.Error("Expected new connections to be refused after shutdown")
+	}
+}
+
+func TestServeGracePeriodExpires(t *testing.T) {
+	url, cancel, done, started := startServe(t, 2*time.Second, 50*time.Millisecond)
+
+	go http.Get(url + "/slow")
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "grace period expired") {
+			t.Errorf("Expected the grace period to expire, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected serve to return once the grace period expired")
+	}
+}
+
+func TestFetchModelsInBackgroundRetriesOllama(t *testing.T) {
+	// The Ollama server is still starting when the first fetch is made
+	var calls atomic.Int32
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[{"name":"llama3","model":"llama3"}]}`))
+	}))
+	defer ollama.Close()
+
+	cfg := &config.Config{DatabasePath: filepath.Join(t.TempDir(), "allama.db"), ModelFetchTimeout: time.Second, ModelFetchRetries: 1}
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+	prov := &models.Provider{Name: "ollama", Type: "ollama", Host: ollama.URL, IsActive: true}
+	if err := store.AddProvider(prov); err != nil {
+		t.Fatalf("Failed to add provider: %v", err)
+	}
+
+	fetchModelsInBackground(context.Background(), store, []*models.Provider{prov}, cfg).Wait()
+
+	stored, err := store.GetModelsByProviderID(prov.ID)
+	if err != nil {
+		t.Fatalf("Failed to get models: %v", err)
+	}
+	if len(stored) != 1 || stored[0].ModelID != "llama3" {
+		t.Errorf("Expected the retried fetch to store llama3, got %+v", stored)
+	}
+}
@@ -1,11 +1,15 @@
 package dbutils
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,81 +17,291 @@ import (
 type LogLevel string
 
 const (
+	// DEBUG level
+	DEBUG LogLevel = "DEBUG"
 	// INFO level
 	INFO LogLevel = "INFO"
 	// ERROR level
 	ERROR LogLevel = "ERROR"
 )
 
+// levelRank orders levels from most to least verbose
+var levelRank = map[LogLevel]int{
+	DEBUG: 0,
+	INFO:  1,
+	ERROR: 2,
+}
+
+// ParseLogLevel converts a case-insensitive level name into a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(strings.TrimSpace(name)))
+	if _, ok := levelRank[level]; !ok {
+		return INFO, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
 // LogEntry represents a single log entry
 type LogEntry struct {
 	Timestamp string      `json:"timestamp"`
 	Level     LogLevel    `json:"level"`
+	RequestID string      `json:"request_id,omitempty"`
 	Message   string      `json:"message"`
 	Data      interface{} `json:"data,omitempty"`
 }
 
-// Logger struct
+// logDateLayout is the date format of daily log file names
+const logDateLayout = "2006-01-02"
+
+// logFlushInterval is how long file entries may stay buffered before they are written
+const logFlushInterval = time.Second
+
+// Logger writes JSON log entries to daily files and standard streams. The current day's file
+// is kept open and written through a buffer that is flushed periodically and on Close.
 type Logger struct {
-	logDir string
+	logDir   string
+	minLevel LogLevel
+	toFile   bool
+	streams  []io.Writer
+
+	// mu serializes writes so concurrent entries never interleave
+	mu sync.Mutex
+	// file is the open log file for fileDate, written through buf
+	file       *os.File
+	fileDate   string
+	buf        *bufio.Writer
+	flushTimer *time.Timer
 }
 
-// NewLogger creates a new logger instance
+// NewLogger creates a new logger instance that writes INFO and above to daily files in logDir
 func NewLogger(logDir string) *Logger {
-	return &Logger{logDir: logDir}
+	return &Logger{logDir: logDir, minLevel: INFO, toFile: true}
+}
+
+// SetLevel sets the minimum level an entry needs to be written
+func (l *Logger) SetLevel(level LogLevel) {
+	l.minLevel = level
+}
+
+// SetOutputs selects where entries are written from a comma-separated list of
+// "file", "stdout" and "stderr"
+func (l *Logger) SetOutputs(spec string) error {
+	toFile := false
+	var streams []io.Writer
+	for _, output := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(output)) {
+		case "file":
+			toFile = true
+		case "stdout":
+			streams = append(streams, os.Stdout)
+		case "stderr":
+			streams = append(streams, os.Stderr)
+		case "":
+		default:
+			return fmt.Errorf("unknown log output %q", output)
+		}
+	}
+	if !toFile && len(streams) == 0 {
+		return fmt.Errorf("no log output selected in %q", spec)
+	}
+
+	l.toFile = toFile
+	l.streams = streams
+	return nil
 }
 
-// Log writes a log entry to a daily log file
-func (l *Logger) Log(level LogLevel, message string, data interface{}) error {
+// WritesToFile reports whether the logger writes daily log files
+func (l *Logger) WritesToFile() bool {
+	return l.toFile
+}
+
+// Log writes a log entry to the configured outputs when its level meets the threshold
+func (l *Logger) Log(level LogLevel, requestID, message string, data interface{}) error {
+	if levelRank[level] < levelRank[l.minLevel] {
+		return nil
+	}
+
 	now := time.Now()
-	logFileName := fmt.Sprintf("%s/allama-%s.log", l.logDir, now.Format("2006-01-02"))
 	entry := LogEntry{
 		Timestamp: now.Format(time.RFC3339),
 		Level:     level,
+		RequestID: requestID,
 		Message:   message,
 		Data:      data,
 	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding log entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, stream := range l.streams {
+		if _, err := stream.Write(line); err != nil {
+			return fmt.Errorf("error writing log entry: %w", err)
+		}
+	}
+	if !l.toFile {
+		return nil
+	}
+
+	if err := l.openFile(now.Format(logDateLayout)); err != nil {
+		return err
+	}
+	if _, err := l.buf.Write(line); err != nil {
+		return fmt.Errorf("error writing log entry: %w", err)
+	}
+	if l.flushTimer == nil {
+		l.flushTimer = time.AfterFunc(logFlushInterval, func() { l.Flush() })
+	}
+	return nil
+}
+
+// openFile makes the log file of date the current one, closing the previous day's file.
+// The caller must hold l.mu.
+func (l *Logger) openFile(date string) error {
+	if l.file != nil && l.fileDate == date {
+		return nil
+	}
+	if err := l.closeFile(); err != nil {
+		return err
+	}
 
-	logFile, err := os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logFileName := l.fileName(date)
+	file, err := os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("error opening log file: %w", err)
 	}
-	defer logFile.Close()
+	l.file, l.fileDate = file, date
+	l.buf = bufio.NewWriter(file)
+	return nil
+}
 
-	encoder := json.NewEncoder(logFile)
-	if err := encoder.Encode(entry); err != nil {
-		return fmt.Errorf("error encoding log entry: %w", err)
-	}
+// fileName returns the path of the log file of date
+func (l *Logger) fileName(date string) string {
+	return fmt.Sprintf("%s/allama-%s.log", l.logDir, date)
+}
 
+// flush writes buffered entries to the log file. The caller must hold l.mu.
+func (l *Logger) flush() error {
+	if l.flushTimer != nil {
+		l.flushTimer.Stop()
+		l.flushTimer = nil
+	}
+	if l.buf == nil {
+		return nil
+	}
+	if err := l.buf.Flush(); err != nil {
+		return fmt.Errorf("error flushing log file: %w", err)
+	}
 	return nil
 }
 
+// closeFile flushes and closes the open log file, if any. The caller must hold l.mu.
+func (l *Logger) closeFile() error {
+	if l.file == nil {
+		return nil
+	}
+	flushErr := l.flush()
+	closeErr := l.file.Close()
+	l.file, l.buf, l.fileDate = nil, nil, ""
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Flush writes any buffered entries to the log file
+func (l *Logger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+// Close flushes buffered entries and closes the log file. Entries logged afterwards
+// reopen the file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeFile()
+}
+
 // LogRequest logs request details
-func (l *Logger) LogRequest(method, path string, headers map[string][]string, body interface{}) error {
+func (l *Logger) LogRequest(requestID, method, path, clientIP string, headers map[string][]string, body interface{}) error {
 	data := map[string]interface{}{
-		"method":  method,
-		"path":    path,
-		"headers": headers,
-		"body":    body,
+		"method":   method,
+		"path":     path,
+		"clientIP": clientIP,
+		"headers":  headers,
+		"body":     body,
 	}
-	return l.Log(INFO, "Request", data)
+	return l.Log(INFO, requestID, "Request", data)
 }
 
 // LogResponse logs response details
-func (l *Logger) LogResponse(statusCode int, body interface{}) error {
+func (l *Logger) LogResponse(requestID string, statusCode int, body interface{}) error {
 	data := map[string]interface{}{
 		"statusCode": statusCode,
 		"body":       body,
 	}
-	return l.Log(INFO, "Response", data)
+	return l.Log(INFO, requestID, "Response", data)
 }
 
 // LogError logs error details
-func (l *Logger) LogError(message string, err error) error {
+func (l *Logger) LogError(requestID, message string, err error) error {
 	data := map[string]interface{}{
 		"error": err.Error(),
 	}
-	return l.Log(ERROR, message, data)
+	return l.Log(ERROR, requestID, message, data)
+}
+
+// ErrInvalidLogDate is returned for dates that are not in the YYYY-MM-DD format
+var ErrInvalidLogDate = errors.New("date must be in YYYY-MM-DD format")
+
+// ReadEntries returns the last limit entries of the log file of date (YYYY-MM-DD) whose level
+// is at least minLevel, oldest first. Lines that are not log entries are skipped, and a day
+// without a log file has no entries. Only dates that name a real day are accepted, so the
+// date cannot point outside the log directory.
+func (l *Logger) ReadEntries(date string, minLevel LogLevel, limit int) ([]LogEntry, error) {
+	day, err := time.Parse(logDateLayout, date)
+	if err != nil || day.Format(logDateLayout) != date {
+		return nil, ErrInvalidLogDate
+	}
+
+	// Entries of the current day may still be buffered
+	if err := l.Flush(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(l.fileName(date))
+	if errors.Is(err, os.ErrNotExist) {
+		return []LogEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+	defer file.Close()
+
+	entries := []LogEntry{}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		var entry LogEntry
+		if len(line) > 0 && json.Unmarshal(line, &entry) == nil && levelRank[entry.Level] >= levelRank[minLevel] {
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) > limit {
+				entries = entries[1:]
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading log file: %w", err)
+		}
+	}
+	return entries, nil
 }
 
 // EnsureLogDirExists checks if the log directory exists and creates it if not
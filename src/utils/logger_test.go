@@ -0,0 +1,141 @@
+package dbutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerLevelThreshold(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewLogger(logDir)
+	logger.SetLevel(ERROR)
+
+	logger.Log(INFO, "req-1", "Request", nil)
+	logger.Log(DEBUG, "req-1", "Details", nil)
+	logger.Log(ERROR, "req-1", "Upstream failed", nil)
+	logger.Close()
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Upstream failed") {
+		t.Errorf("Expected only the ERROR entry, got %q", string(data))
+	}
+}
+
+func TestLoggerStdoutOnlyWritesNoFiles(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewLogger(logDir)
+	if err := logger.SetOutputs("stderr"); err != nil {
+		t.Fatalf("Failed to set outputs: %v", err)
+	}
+
+	logger.Log(INFO, "", "Request", nil)
+	logger.Close()
+
+	if files, _ := filepath.Glob(filepath.Join(logDir, "*.log")); len(files) != 0 {
+		t.Errorf("Expected no log files, got %v", files)
+	}
+}
+
+func TestParseLogLevelAndOutputs(t *testing.T) {
+	if level, err := ParseLogLevel("debug"); err != nil || level != DEBUG {
+		t.Errorf("Expected DEBUG, got %v (err %v)", level, err)
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if err := NewLogger("").SetOutputs("file,syslog"); err == nil {
+		t.Error("Expected an error for an unknown output")
+	}
+}
+
+func TestLoggerConcurrentWrites(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewLogger(logDir)
+
+	const writers, entries = 32, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < entries; j++ {
+				logger.Log(INFO, fmt.Sprintf("req-%d-%d", i, j), "Request", map[string]string{"body": strings.Repeat("x", 512)})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != writers*entries {
+		t.Fatalf("Expected %d entries, got %d", writers*entries, len(lines))
+	}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected every line to be valid JSON, got %q: %v", line, err)
+		}
+		seen[entry.RequestID] = true
+	}
+	if len(seen) != writers*entries {
+		t.Errorf("Expected every entry exactly once, got %d distinct", len(seen))
+	}
+}
+
+func TestReadEntriesFiltersByLevelAndKeepsLatest(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewLogger(logDir)
+	defer logger.Close()
+
+	for i := 0; i < 5; i++ {
+		logger.Log(INFO, fmt.Sprintf("req-%d", i), "Request", nil)
+		logger.Log(ERROR, fmt.Sprintf("req-%d", i), "Upstream failed", map[string]interface{}{"attempt": i})
+	}
+	today := time.Now().Format("2006-01-02")
+
+	entries, err := logger.ReadEntries(today, ERROR, 2)
+	if err != nil {
+		t.Fatalf("Failed to read entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].RequestID != "req-3" || entries[1].RequestID != "req-4" {
+		t.Fatalf("Expected the last two ERROR entries, got %+v", entries)
+	}
+	if entries[1].Level != ERROR || entries[1].Data.(map[string]interface{})["attempt"] != float64(4) {
+		t.Errorf("Expected the entry data to be decoded, got %+v", entries[1])
+	}
+
+	if entries, err := logger.ReadEntries(today, INFO, 0); err != nil || len(entries) != 10 {
+		t.Errorf("Expected every entry without a limit, got %d (err %v)", len(entries), err)
+	}
+	if entries, err := logger.ReadEntries("2001-01-01", INFO, 10); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries for a day without a log, got %+v (err %v)", entries, err)
+	}
+}
+
+func TestReadEntriesRejectsPathsAsDates(t *testing.T) {
+	logger := NewLogger(t.TempDir())
+	for _, date := range []string{"../../etc/passwd", "2024-01-01/../../secret", "2024-13-01", "2024-1-1", ""} {
+		if _, err := logger.ReadEntries(date, INFO, 10); err != ErrInvalidLogDate {
+			t.Errorf("%q: expected ErrInvalidLogDate, got %v", date, err)
+		}
+	}
+}